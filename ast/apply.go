@@ -0,0 +1,158 @@
+package ast
+
+// A Cursor describes the node being visited by Apply and lets the visitor
+// replace it.
+type Cursor struct {
+	node   Node
+	parent Node
+}
+
+// Node returns the current node.
+func (c *Cursor) Node() Node { return c.node }
+
+// Parent returns the node containing the current node, or nil for the root.
+func (c *Cursor) Parent() Node { return c.parent }
+
+// Replace replaces the current node with n. The replacement must fit the
+// field it is stored in: an Expression for an expression, a *BlockStatement
+// for a block and so on; Apply panics otherwise.
+func (c *Cursor) Replace(n Node) { c.node = n }
+
+// ApplyFunc is called by Apply for every node.
+type ApplyFunc func(*Cursor) bool
+
+// Apply traverses node depth-first and returns it, possibly replaced, much
+// like golang.org/x/tools/go/ast/astutil.Apply.
+//
+// pre is called for each node before its children. If it returns false the
+// children and post are skipped for that node. post is called after the
+// children. If it returns false the traversal stops. Either may be nil.
+// Children are visited after any replacement made by pre, and nodes are
+// modified in place; Clone the tree first to keep the original.
+func Apply(node Node, pre, post ApplyFunc) Node {
+	a := &applier{pre: pre, post: post}
+	return a.apply(nil, node)
+}
+
+type applier struct {
+	pre, post ApplyFunc
+	aborted   bool
+}
+
+func (a *applier) apply(parent, node Node) Node {
+	if isNil(node) || a.aborted {
+		return node
+	}
+
+	c := &Cursor{node: node, parent: parent}
+	if a.pre != nil && !a.pre(c) {
+		return c.node
+	}
+
+	switch n := c.node.(type) {
+	case *Program:
+		a.statements(n, n.Statements)
+	case *LetStatement:
+		n.Name = a.identifier(n, n.Name)
+		n.Value = a.expression(n, n.Value)
+	case *ReturnStatement:
+		n.ReturnValue = a.expression(n, n.ReturnValue)
+	case *DeferStatement:
+		n.Call = a.expression(n, n.Call).(*CallExpression)
+	case *ExpressionStatement:
+		n.Expression = a.expression(n, n.Expression)
+	case *BlockStatement:
+		a.statements(n, n.Statements)
+	case *PrefixExpression:
+		n.Right = a.expression(n, n.Right)
+	case *AssignExpression:
+		n.Name = a.identifier(n, n.Name)
+		n.Value = a.expression(n, n.Value)
+	case *InfixExpression:
+		n.Left = a.expression(n, n.Left)
+		n.Right = a.expression(n, n.Right)
+	case *IfExpression:
+		n.Condition = a.expression(n, n.Condition)
+		n.Consequence = a.block(n, n.Consequence)
+		n.Alternative = a.block(n, n.Alternative)
+	case *WhileExpression:
+		n.Condition = a.expression(n, n.Condition)
+		n.Body = a.block(n, n.Body)
+	case *ForExpression:
+		n.Init = a.statement(n, n.Init)
+		n.Condition = a.expression(n, n.Condition)
+		n.Update = a.expression(n, n.Update)
+		n.Body = a.block(n, n.Body)
+	case *ForInExpression:
+		n.Variable = a.identifier(n, n.Variable)
+		n.Iterable = a.expression(n, n.Iterable)
+		n.Body = a.block(n, n.Body)
+	case *FunctionLiteral:
+		for i, param := range n.Parameters {
+			n.Parameters[i] = a.identifier(n, param)
+		}
+		n.Body = a.block(n, n.Body)
+	case *CallExpression:
+		n.Function = a.expression(n, n.Function)
+		a.expressions(n, n.Arguments)
+	case *ArrayLiteral:
+		a.expressions(n, n.Elements)
+	case *IndexExpression:
+		n.Left = a.expression(n, n.Left)
+		n.Index = a.expression(n, n.Index)
+	case *MemberExpression:
+		n.Left = a.expression(n, n.Left)
+		n.Property = a.identifier(n, n.Property)
+	case *HashLiteral:
+		pairs := make(map[Expression]Expression, len(n.Pairs))
+		for _, key := range n.Keys() {
+			pairs[a.expression(n, key)] = a.expression(n, n.Pairs[key])
+		}
+		n.Pairs = pairs
+	}
+
+	if a.post != nil && !a.aborted && !a.post(c) {
+		a.aborted = true
+	}
+	return c.node
+}
+
+func (a *applier) expression(parent Node, exp Expression) Expression {
+	if exp == nil {
+		return nil
+	}
+	return a.apply(parent, exp).(Expression)
+}
+
+func (a *applier) expressions(parent Node, exps []Expression) {
+	for i, exp := range exps {
+		exps[i] = a.expression(parent, exp)
+	}
+}
+
+func (a *applier) statements(parent Node, stmts []Statement) {
+	for i, stmt := range stmts {
+		stmts[i] = a.statement(parent, stmt)
+	}
+}
+
+func (a *applier) statement(parent Node, stmt Statement) Statement {
+	if stmt == nil {
+		return nil
+	}
+	return a.apply(parent, stmt).(Statement)
+}
+
+func (a *applier) identifier(parent Node, ident *Identifier) *Identifier {
+	if ident == nil {
+		return nil
+	}
+	return a.apply(parent, ident).(*Identifier)
+}
+
+func (a *applier) block(parent Node, block *BlockStatement) *BlockStatement {
+	if block == nil {
+		return nil
+	}
+	return a.apply(parent, block).(*BlockStatement)
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/rock619/monkey/token"
 )
@@ -11,6 +12,9 @@ import (
 type Node interface {
 	TokenLiteral() string
 	String() string
+	// Pos returns the position of the node's token: the first token of a
+	// statement or literal, and the operator of an infix expression.
+	Pos() token.Position
 }
 
 type Statement interface {
@@ -34,6 +38,13 @@ func (p *Program) TokenLiteral() string {
 	return p.Statements[0].TokenLiteral()
 }
 
+func (p *Program) Pos() token.Position {
+	if len(p.Statements) == 0 {
+		return token.Position{}
+	}
+	return p.Statements[0].Pos()
+}
+
 func (p *Program) String() string {
 	var out bytes.Buffer
 	for _, s := range p.Statements {
@@ -54,6 +65,10 @@ func (ls *LetStatement) TokenLiteral() string {
 	return ls.Token.Literal
 }
 
+func (ls *LetStatement) Pos() token.Position {
+	return ls.Token.Pos
+}
+
 func (ls *LetStatement) String() string {
 	var out bytes.Buffer
 
@@ -81,6 +96,10 @@ func (i *Identifier) TokenLiteral() string {
 	return i.Token.Literal
 }
 
+func (i *Identifier) Pos() token.Position {
+	return i.Token.Pos
+}
+
 func (i *Identifier) String() string {
 	return i.Value
 }
@@ -96,6 +115,10 @@ func (rs *ReturnStatement) TokenLiteral() string {
 	return rs.Token.Literal
 }
 
+func (rs *ReturnStatement) Pos() token.Position {
+	return rs.Token.Pos
+}
+
 func (rs *ReturnStatement) String() string {
 	var out bytes.Buffer
 
@@ -110,6 +133,20 @@ func (rs *ReturnStatement) String() string {
 	return out.String()
 }
 
+// DeferStatement is defer f(x);, which calls f with the current value of x
+// when the enclosing function returns.
+type DeferStatement struct {
+	Token token.Token
+	Call  *CallExpression
+}
+
+func (ds *DeferStatement) statementNode()       {}
+func (ds *DeferStatement) TokenLiteral() string { return ds.Token.Literal }
+func (ds *DeferStatement) Pos() token.Position  { return ds.Token.Pos }
+func (ds *DeferStatement) String() string {
+	return ds.TokenLiteral() + " " + ds.Call.String() + ";"
+}
+
 type ExpressionStatement struct {
 	Token      token.Token
 	Expression Expression
@@ -121,6 +158,10 @@ func (es *ExpressionStatement) TokenLiteral() string {
 	return es.Token.Literal
 }
 
+func (es *ExpressionStatement) Pos() token.Position {
+	return es.Token.Pos
+}
+
 func (es *ExpressionStatement) String() string {
 	if es.Expression == nil {
 		return ""
@@ -139,10 +180,52 @@ func (il *IntegerLiteral) TokenLiteral() string {
 	return il.Token.Literal
 }
 
+func (il *IntegerLiteral) Pos() token.Position {
+	return il.Token.Pos
+}
+
 func (il *IntegerLiteral) String() string {
 	return il.Token.Literal
 }
 
+type FloatLiteral struct {
+	Token token.Token
+	Value float64
+}
+
+func (fl *FloatLiteral) expressionNode() {}
+
+func (fl *FloatLiteral) TokenLiteral() string {
+	return fl.Token.Literal
+}
+
+func (fl *FloatLiteral) Pos() token.Position {
+	return fl.Token.Pos
+}
+
+func (fl *FloatLiteral) String() string {
+	return fl.Token.Literal
+}
+
+type DurationLiteral struct {
+	Token token.Token
+	Value time.Duration
+}
+
+func (dl *DurationLiteral) expressionNode() {}
+
+func (dl *DurationLiteral) TokenLiteral() string {
+	return dl.Token.Literal
+}
+
+func (dl *DurationLiteral) Pos() token.Position {
+	return dl.Token.Pos
+}
+
+func (dl *DurationLiteral) String() string {
+	return dl.Token.Literal
+}
+
 type PrefixExpression struct {
 	Token    token.Token
 	Operator string
@@ -155,6 +238,10 @@ func (pe *PrefixExpression) TokenLiteral() string {
 	return pe.Token.Literal
 }
 
+func (pe *PrefixExpression) Pos() token.Position {
+	return pe.Token.Pos
+}
+
 func (pe *PrefixExpression) String() string {
 	var out bytes.Buffer
 
@@ -179,6 +266,10 @@ func (ie *InfixExpression) TokenLiteral() string {
 	return ie.Token.Literal
 }
 
+func (ie *InfixExpression) Pos() token.Position {
+	return ie.Token.Pos
+}
+
 func (ie *InfixExpression) String() string {
 	var out bytes.Buffer
 
@@ -198,6 +289,10 @@ func (b *Boolean) TokenLiteral() string {
 	return b.Token.Literal
 }
 
+func (b *Boolean) Pos() token.Position {
+	return b.Token.Pos
+}
+
 func (b *Boolean) String() string {
 	return b.Token.Literal
 }
@@ -215,6 +310,10 @@ func (ie *IfExpression) TokenLiteral() string {
 	return ie.Token.Literal
 }
 
+func (ie *IfExpression) Pos() token.Position {
+	return ie.Token.Pos
+}
+
 func (ie *IfExpression) String() string {
 	var out bytes.Buffer
 
@@ -227,6 +326,88 @@ func (ie *IfExpression) String() string {
 	return out.String()
 }
 
+type WhileExpression struct {
+	Token     token.Token // "while"
+	Condition Expression
+	Body      *BlockStatement
+}
+
+func (we *WhileExpression) expressionNode() {}
+
+func (we *WhileExpression) TokenLiteral() string {
+	return we.Token.Literal
+}
+
+func (we *WhileExpression) Pos() token.Position {
+	return we.Token.Pos
+}
+
+func (we *WhileExpression) String() string {
+	return fmt.Sprintf("while %s %s", we.Condition, we.Body)
+}
+
+// ForExpression is a C-style loop. Init, Condition and Update may be nil.
+type ForExpression struct {
+	Token     token.Token // "for"
+	Init      Statement   // LetStatement or ExpressionStatement
+	Condition Expression
+	Update    Expression
+	Body      *BlockStatement
+}
+
+func (fe *ForExpression) expressionNode() {}
+
+func (fe *ForExpression) TokenLiteral() string {
+	return fe.Token.Literal
+}
+
+func (fe *ForExpression) Pos() token.Position {
+	return fe.Token.Pos
+}
+
+func (fe *ForExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("for (")
+	if fe.Init != nil {
+		out.WriteString(strings.TrimSuffix(fe.Init.String(), ";"))
+	}
+	out.WriteString("; ")
+	if fe.Condition != nil {
+		out.WriteString(fe.Condition.String())
+	}
+	out.WriteString("; ")
+	if fe.Update != nil {
+		out.WriteString(fe.Update.String())
+	}
+	fmt.Fprintf(&out, ") %s", fe.Body)
+
+	return out.String()
+}
+
+// ForInExpression runs Body for every element of an array, character of a
+// string or key of a hash.
+type ForInExpression struct {
+	Token    token.Token // "for"
+	Variable *Identifier
+	Iterable Expression
+	Body     *BlockStatement
+}
+
+func (fe *ForInExpression) expressionNode() {}
+
+func (fe *ForInExpression) TokenLiteral() string {
+	return fe.Token.Literal
+}
+
+func (fe *ForInExpression) Pos() token.Position {
+	return fe.Token.Pos
+}
+
+func (fe *ForInExpression) String() string {
+	return fmt.Sprintf("for (%s in %s) %s", fe.Variable, fe.Iterable, fe.Body)
+}
+
 type BlockStatement struct {
 	Token      token.Token // {
 	Statements []Statement
@@ -238,6 +419,10 @@ func (bs *BlockStatement) TokenLiteral() string {
 	return bs.Token.Literal
 }
 
+func (bs *BlockStatement) Pos() token.Position {
+	return bs.Token.Pos
+}
+
 func (bs *BlockStatement) String() string {
 	var out bytes.Buffer
 
@@ -260,6 +445,10 @@ func (fl *FunctionLiteral) TokenLiteral() string {
 	return fl.Token.Literal
 }
 
+func (fl *FunctionLiteral) Pos() token.Position {
+	return fl.Token.Pos
+}
+
 func (fl *FunctionLiteral) String() string {
 	var out bytes.Buffer
 
@@ -285,6 +474,10 @@ func (ce *CallExpression) TokenLiteral() string {
 	return ce.Token.Literal
 }
 
+func (ce *CallExpression) Pos() token.Position {
+	return ce.Token.Pos
+}
+
 func (ce *CallExpression) String() string {
 	var out bytes.Buffer
 
@@ -304,6 +497,7 @@ type StringLiteral struct {
 
 func (sl *StringLiteral) expressionNode()      {}
 func (sl *StringLiteral) TokenLiteral() string { return sl.Token.Literal }
+func (sl *StringLiteral) Pos() token.Position  { return sl.Token.Pos }
 func (sl *StringLiteral) String() string       { return sl.Token.Literal }
 
 type ArrayLiteral struct {
@@ -313,6 +507,7 @@ type ArrayLiteral struct {
 
 func (al *ArrayLiteral) expressionNode()      {}
 func (al *ArrayLiteral) TokenLiteral() string { return al.Token.Literal }
+func (al *ArrayLiteral) Pos() token.Position  { return al.Token.Pos }
 func (al *ArrayLiteral) String() string {
 	var out bytes.Buffer
 
@@ -336,6 +531,7 @@ type IndexExpression struct {
 
 func (ie *IndexExpression) expressionNode()      {}
 func (ie *IndexExpression) TokenLiteral() string { return ie.Token.Literal }
+func (ie *IndexExpression) Pos() token.Position  { return ie.Token.Pos }
 func (ie *IndexExpression) String() string {
 	var out bytes.Buffer
 
@@ -348,6 +544,21 @@ func (ie *IndexExpression) String() string {
 	return out.String()
 }
 
+// MemberExpression is value.member, which reads a member of a hash or of a
+// host value.
+type MemberExpression struct {
+	Token    token.Token // '.' トークン
+	Left     Expression
+	Property *Identifier
+}
+
+func (me *MemberExpression) expressionNode()      {}
+func (me *MemberExpression) TokenLiteral() string { return me.Token.Literal }
+func (me *MemberExpression) Pos() token.Position  { return me.Token.Pos }
+func (me *MemberExpression) String() string {
+	return "(" + me.Left.String() + "." + me.Property.String() + ")"
+}
+
 type HashLiteral struct {
 	Token token.Token // '{' トークン
 	Pairs map[Expression]Expression
@@ -355,12 +566,13 @@ type HashLiteral struct {
 
 func (hl *HashLiteral) expressionNode()      {}
 func (hl *HashLiteral) TokenLiteral() string { return hl.Token.Literal }
+func (hl *HashLiteral) Pos() token.Position  { return hl.Token.Pos }
 func (hl *HashLiteral) String() string {
 	var out bytes.Buffer
 
 	pairs := []string{}
-	for key, value := range hl.Pairs {
-		pairs = append(pairs, key.String()+":"+value.String())
+	for _, key := range hl.Keys() {
+		pairs = append(pairs, key.String()+":"+hl.Pairs[key].String())
 	}
 
 	out.WriteString("{")
@@ -369,3 +581,19 @@ func (hl *HashLiteral) String() string {
 
 	return out.String()
 }
+
+// AssignExpression assigns a new value to an existing variable. Its value
+// is the assigned value. Closures capture variables rather than values, so
+// every closure that can see the variable sees the assignment.
+type AssignExpression struct {
+	Token token.Token // '='
+	Name  *Identifier
+	Value Expression
+}
+
+func (ae *AssignExpression) expressionNode()      {}
+func (ae *AssignExpression) TokenLiteral() string { return ae.Token.Literal }
+func (ae *AssignExpression) Pos() token.Position  { return ae.Token.Pos }
+func (ae *AssignExpression) String() string {
+	return fmt.Sprintf("(%s = %s)", ae.Name, ae.Value)
+}
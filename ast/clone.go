@@ -0,0 +1,180 @@
+package ast
+
+import "reflect"
+
+// Clone returns a deep copy of node that shares no nodes with the
+// original, so either can be modified without affecting the other.
+func Clone(node Node) Node {
+	if isNil(node) {
+		return node
+	}
+
+	switch node := node.(type) {
+	case *Program:
+		return &Program{Statements: cloneStatements(node.Statements)}
+	case *LetStatement:
+		return &LetStatement{
+			Token: node.Token,
+			Name:  cloneIdentifier(node.Name),
+			Value: cloneExpression(node.Value),
+		}
+	case *ReturnStatement:
+		return &ReturnStatement{Token: node.Token, ReturnValue: cloneExpression(node.ReturnValue)}
+	case *DeferStatement:
+		call, _ := cloneExpression(node.Call).(*CallExpression)
+		return &DeferStatement{Token: node.Token, Call: call}
+	case *ExpressionStatement:
+		return &ExpressionStatement{Token: node.Token, Expression: cloneExpression(node.Expression)}
+	case *BlockStatement:
+		return cloneBlock(node)
+	case *Identifier:
+		return cloneIdentifier(node)
+	case *IntegerLiteral:
+		c := *node
+		return &c
+	case *FloatLiteral:
+		c := *node
+		return &c
+	case *DurationLiteral:
+		c := *node
+		return &c
+	case *Boolean:
+		c := *node
+		return &c
+	case *StringLiteral:
+		c := *node
+		return &c
+	case *PrefixExpression:
+		return &PrefixExpression{
+			Token:    node.Token,
+			Operator: node.Operator,
+			Right:    cloneExpression(node.Right),
+		}
+	case *AssignExpression:
+		return &AssignExpression{
+			Token: node.Token,
+			Name:  cloneIdentifier(node.Name),
+			Value: cloneExpression(node.Value),
+		}
+	case *InfixExpression:
+		return &InfixExpression{
+			Token:    node.Token,
+			Left:     cloneExpression(node.Left),
+			Operator: node.Operator,
+			Right:    cloneExpression(node.Right),
+		}
+	case *IfExpression:
+		return &IfExpression{
+			Token:       node.Token,
+			Condition:   cloneExpression(node.Condition),
+			Consequence: cloneBlock(node.Consequence),
+			Alternative: cloneBlock(node.Alternative),
+		}
+	case *WhileExpression:
+		return &WhileExpression{
+			Token:     node.Token,
+			Condition: cloneExpression(node.Condition),
+			Body:      cloneBlock(node.Body),
+		}
+	case *ForExpression:
+		init, _ := Clone(node.Init).(Statement)
+		return &ForExpression{
+			Token:     node.Token,
+			Init:      init,
+			Condition: cloneExpression(node.Condition),
+			Update:    cloneExpression(node.Update),
+			Body:      cloneBlock(node.Body),
+		}
+	case *ForInExpression:
+		return &ForInExpression{
+			Token:    node.Token,
+			Variable: cloneIdentifier(node.Variable),
+			Iterable: cloneExpression(node.Iterable),
+			Body:     cloneBlock(node.Body),
+		}
+	case *FunctionLiteral:
+		params := make([]*Identifier, len(node.Parameters))
+		for i, param := range node.Parameters {
+			params[i] = cloneIdentifier(param)
+		}
+		return &FunctionLiteral{Token: node.Token, Parameters: params, Body: cloneBlock(node.Body)}
+	case *CallExpression:
+		return &CallExpression{
+			Token:     node.Token,
+			Function:  cloneExpression(node.Function),
+			Arguments: cloneExpressions(node.Arguments),
+		}
+	case *ArrayLiteral:
+		return &ArrayLiteral{Token: node.Token, Elements: cloneExpressions(node.Elements)}
+	case *IndexExpression:
+		return &IndexExpression{
+			Token: node.Token,
+			Left:  cloneExpression(node.Left),
+			Index: cloneExpression(node.Index),
+		}
+	case *MemberExpression:
+		return &MemberExpression{
+			Token:    node.Token,
+			Left:     cloneExpression(node.Left),
+			Property: cloneIdentifier(node.Property),
+		}
+	case *HashLiteral:
+		pairs := make(map[Expression]Expression, len(node.Pairs))
+		for key, value := range node.Pairs {
+			pairs[cloneExpression(key)] = cloneExpression(value)
+		}
+		return &HashLiteral{Token: node.Token, Pairs: pairs}
+	default:
+		return node
+	}
+}
+
+// isNil reports whether n is nil or a typed nil pointer, which the parser
+// leaves behind for statements it couldn't parse.
+func isNil(n Node) bool {
+	return n == nil || reflect.ValueOf(n).IsNil()
+}
+
+func cloneExpression(exp Expression) Expression {
+	if exp == nil {
+		return nil
+	}
+	return Clone(exp).(Expression)
+}
+
+func cloneExpressions(exps []Expression) []Expression {
+	if exps == nil {
+		return nil
+	}
+	cloned := make([]Expression, len(exps))
+	for i, exp := range exps {
+		cloned[i] = cloneExpression(exp)
+	}
+	return cloned
+}
+
+func cloneStatements(stmts []Statement) []Statement {
+	if stmts == nil {
+		return nil
+	}
+	cloned := make([]Statement, len(stmts))
+	for i, stmt := range stmts {
+		cloned[i] = Clone(stmt).(Statement)
+	}
+	return cloned
+}
+
+func cloneIdentifier(ident *Identifier) *Identifier {
+	if ident == nil {
+		return nil
+	}
+	c := *ident
+	return &c
+}
+
+func cloneBlock(block *BlockStatement) *BlockStatement {
+	if block == nil {
+		return nil
+	}
+	return &BlockStatement{Token: block.Token, Statements: cloneStatements(block.Statements)}
+}
@@ -0,0 +1,153 @@
+package ast_test
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/rock619/monkey/ast"
+	"github.com/rock619/monkey/lexer"
+	"github.com/rock619/monkey/parser"
+	"github.com/stretchr/testify/assert"
+)
+
+const corpus = `
+let add = fn(a, b) { a + b };
+let xs = [1, 2 * 3, add(4, 5)];
+let h = {"one": 1, true: -xs[0]};
+if (xs[1] > 5) { return h["one"]; } else { !false };
+h.one.two(3);
+`
+
+func parse(t *testing.T, input string) *ast.Program {
+	t.Helper()
+
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	assert.Empty(t, p.Errors())
+	return program
+}
+
+func TestClone(t *testing.T) {
+	program := parse(t, corpus)
+	original := program.String()
+
+	clone := ast.Clone(program).(*ast.Program)
+	assert.Equal(t, original, clone.String())
+
+	// Rewriting every node of the clone must leave the original untouched.
+	ast.Apply(clone, nil, func(c *ast.Cursor) bool {
+		switch n := c.Node().(type) {
+		case *ast.IntegerLiteral:
+			n.Value = 0
+			n.Token.Literal = "0"
+		case *ast.Identifier:
+			n.Value = "z"
+		case *ast.Boolean:
+			n.Value = !n.Value
+			n.Token.Literal = "flipped"
+		case *ast.StringLiteral:
+			n.Value = ""
+			n.Token.Literal = ""
+		}
+		return true
+	})
+
+	assert.NotEqual(t, original, clone.String())
+	assert.Equal(t, original, program.String())
+}
+
+func TestApply(t *testing.T) {
+	program := parse(t, "let x = 1 + 2 * 3; fn(y) { y + 4 * 5 };")
+
+	// Fold constant integer arithmetic bottom-up in post.
+	folded := ast.Apply(program, nil, func(c *ast.Cursor) bool {
+		infix, ok := c.Node().(*ast.InfixExpression)
+		if !ok {
+			return true
+		}
+		left, lok := infix.Left.(*ast.IntegerLiteral)
+		right, rok := infix.Right.(*ast.IntegerLiteral)
+		if !lok || !rok {
+			return true
+		}
+
+		var value int64
+		switch infix.Operator {
+		case "+":
+			value = left.Value + right.Value
+		case "*":
+			value = left.Value * right.Value
+		default:
+			return true
+		}
+		tok := left.Token
+		tok.Literal = strconv.FormatInt(value, 10)
+		c.Replace(&ast.IntegerLiteral{Token: tok, Value: value})
+		return true
+	})
+
+	assert.Equal(t, "let x = 7;fn(y) (y + 20)", folded.String())
+}
+
+func TestApplySkipAndAbort(t *testing.T) {
+	program := parse(t, "a; fn() { b }; c; d;")
+
+	visited := []string{}
+	ast.Apply(program, func(c *ast.Cursor) bool {
+		_, isFunction := c.Node().(*ast.FunctionLiteral)
+		return !isFunction
+	}, func(c *ast.Cursor) bool {
+		ident, ok := c.Node().(*ast.Identifier)
+		if !ok {
+			return true
+		}
+		visited = append(visited, ident.Value)
+		return ident.Value != "c"
+	})
+
+	assert.Equal(t, []string{"a", "c"}, visited)
+}
+
+func TestInspect(t *testing.T) {
+	program := parse(t, "let a = b + c; fn(d) { e }; f(g);")
+	original := program.String()
+
+	visited := []string{}
+	ast.Inspect(program, func(n ast.Node) bool {
+		if _, ok := n.(*ast.FunctionLiteral); ok {
+			return false
+		}
+		if ident, ok := n.(*ast.Identifier); ok {
+			visited = append(visited, ident.Value)
+		}
+		return true
+	})
+
+	assert.Equal(t, []string{"a", "b", "c", "f", "g"}, visited)
+	assert.Equal(t, original, program.String())
+}
+
+func TestHashLiteralOrder(t *testing.T) {
+	program := parse(t, `{k1: v1, k2: v2, k3: v3, k4: v4, k5: v5, k6: v6, k7: v7, k8: v8}`)
+	expected := []string{"k1", "v1", "k2", "v2", "k3", "v3", "k4", "v4", "k5", "v5", "k6", "v6", "k7", "v7", "k8", "v8"}
+
+	for i := 0; i < 10; i++ {
+		visited := []string{}
+		ast.Inspect(program, func(n ast.Node) bool {
+			if ident, ok := n.(*ast.Identifier); ok {
+				visited = append(visited, ident.Value)
+			}
+			return true
+		})
+		assert.Equal(t, expected, visited)
+
+		visited = []string{}
+		ast.Apply(program, func(c *ast.Cursor) bool {
+			if ident, ok := c.Node().(*ast.Identifier); ok {
+				visited = append(visited, ident.Value)
+			}
+			return true
+		}, nil)
+		assert.Equal(t, expected, visited)
+	}
+}
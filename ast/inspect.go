@@ -0,0 +1,84 @@
+package ast
+
+// Inspect traverses node depth-first, calling f for each node before its
+// children, much like go/ast.Inspect. If f returns false the children of
+// that node are skipped. Unlike Apply it never writes to the tree, so any
+// number of goroutines may inspect the same tree at once.
+func Inspect(node Node, f func(Node) bool) {
+	if isNil(node) || !f(node) {
+		return
+	}
+
+	switch n := node.(type) {
+	case *Program:
+		inspectStatements(n.Statements, f)
+	case *LetStatement:
+		Inspect(n.Name, f)
+		Inspect(n.Value, f)
+	case *ReturnStatement:
+		Inspect(n.ReturnValue, f)
+	case *DeferStatement:
+		Inspect(n.Call, f)
+	case *ExpressionStatement:
+		Inspect(n.Expression, f)
+	case *BlockStatement:
+		inspectStatements(n.Statements, f)
+	case *PrefixExpression:
+		Inspect(n.Right, f)
+	case *AssignExpression:
+		Inspect(n.Name, f)
+		Inspect(n.Value, f)
+	case *InfixExpression:
+		Inspect(n.Left, f)
+		Inspect(n.Right, f)
+	case *IfExpression:
+		Inspect(n.Condition, f)
+		Inspect(n.Consequence, f)
+		Inspect(n.Alternative, f)
+	case *WhileExpression:
+		Inspect(n.Condition, f)
+		Inspect(n.Body, f)
+	case *ForExpression:
+		Inspect(n.Init, f)
+		Inspect(n.Condition, f)
+		Inspect(n.Update, f)
+		Inspect(n.Body, f)
+	case *ForInExpression:
+		Inspect(n.Variable, f)
+		Inspect(n.Iterable, f)
+		Inspect(n.Body, f)
+	case *FunctionLiteral:
+		for _, param := range n.Parameters {
+			Inspect(param, f)
+		}
+		Inspect(n.Body, f)
+	case *CallExpression:
+		Inspect(n.Function, f)
+		inspectExpressions(n.Arguments, f)
+	case *ArrayLiteral:
+		inspectExpressions(n.Elements, f)
+	case *IndexExpression:
+		Inspect(n.Left, f)
+		Inspect(n.Index, f)
+	case *MemberExpression:
+		Inspect(n.Left, f)
+		Inspect(n.Property, f)
+	case *HashLiteral:
+		for _, key := range n.Keys() {
+			Inspect(key, f)
+			Inspect(n.Pairs[key], f)
+		}
+	}
+}
+
+func inspectStatements(stmts []Statement, f func(Node) bool) {
+	for _, stmt := range stmts {
+		Inspect(stmt, f)
+	}
+}
+
+func inspectExpressions(exps []Expression, f func(Node) bool) {
+	for _, exp := range exps {
+		Inspect(exp, f)
+	}
+}
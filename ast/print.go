@@ -0,0 +1,126 @@
+package ast
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/rock619/monkey/token"
+)
+
+// Fprint writes the tree rooted at node to w with the type and position of
+// every node and the name of every field, in the spirit of go/ast.Fprint.
+// The format is meant for debugging and may change.
+func Fprint(w io.Writer, node Node) error {
+	var p treePrinter
+	p.value("", reflect.ValueOf(node))
+	_, err := io.WriteString(w, p.String())
+	return err
+}
+
+var (
+	nodeType       = reflect.TypeOf((*Node)(nil)).Elem()
+	expressionType = reflect.TypeOf((*Expression)(nil)).Elem()
+	tokenType      = reflect.TypeOf(token.Token{})
+)
+
+type treePrinter struct {
+	strings.Builder
+	depth int
+}
+
+func (p *treePrinter) line(format string, a ...any) {
+	p.WriteString(strings.Repeat("  ", p.depth))
+	fmt.Fprintf(p, format, a...)
+	p.WriteByte('\n')
+}
+
+// value prints v, prefixed by label if it isn't empty. Nodes are printed
+// field by field; their Token is summarized by the position instead.
+func (p *treePrinter) value(label string, v reflect.Value) {
+	if label != "" {
+		label += ": "
+	}
+
+	switch v.Kind() {
+	case reflect.Invalid:
+		p.line("%snil", label)
+	case reflect.Interface:
+		p.value(strings.TrimSuffix(label, ": "), v.Elem())
+	case reflect.Pointer:
+		if v.IsNil() {
+			p.line("%snil", label)
+			return
+		}
+		if !v.Type().Implements(nodeType) || v.Elem().Kind() != reflect.Struct {
+			p.value(strings.TrimSuffix(label, ": "), v.Elem())
+			return
+		}
+
+		p.line("%s%s @ %s {", label, v.Type(), v.Interface().(Node).Pos())
+		p.depth++
+		s := v.Elem()
+		for i := 0; i < s.NumField(); i++ {
+			field := s.Type().Field(i)
+			if !field.IsExported() || field.Type == tokenType {
+				continue
+			}
+			p.value(field.Name, s.Field(i))
+		}
+		p.depth--
+		p.line("}")
+	case reflect.Slice:
+		if v.Len() == 0 {
+			p.line("%s[]", label)
+			return
+		}
+		p.line("%s[", label)
+		p.depth++
+		for i := 0; i < v.Len(); i++ {
+			p.value(strconv.Itoa(i), v.Index(i))
+		}
+		p.depth--
+		p.line("]")
+	case reflect.Map:
+		if v.Len() == 0 {
+			p.line("%s{}", label)
+			return
+		}
+		p.line("%s{", label)
+		p.depth++
+		for i, key := range sortedMapKeys(v) {
+			p.line("%d: {", i)
+			p.depth++
+			p.value("Key", key)
+			p.value("Value", v.MapIndex(key))
+			p.depth--
+			p.line("}")
+		}
+		p.depth--
+		p.line("}")
+	default:
+		p.line("%s%#v", label, v.Interface())
+	}
+}
+
+// sortedMapKeys returns the keys of m, in source order if they are
+// expressions.
+func sortedMapKeys(m reflect.Value) []reflect.Value {
+	keys := m.MapKeys()
+	if m.Type().Key() != expressionType {
+		return keys
+	}
+
+	exps := make([]Expression, len(keys))
+	for i, key := range keys {
+		exps[i] = key.Interface().(Expression)
+	}
+	sortExpressions(exps)
+	sorted := reflect.ValueOf(exps)
+	for i := range keys {
+		keys[i] = sorted.Index(i)
+	}
+	return keys
+}
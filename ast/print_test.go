@@ -0,0 +1,69 @@
+package ast_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/rock619/monkey/ast"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFprint(t *testing.T) {
+	program := parse(t, `let x = -1 + f(true); {"b": x, "a": []}`)
+
+	expected := `*ast.Program @ 1:1 {
+  Statements: [
+    0: *ast.LetStatement @ 1:1 {
+      Name: *ast.Identifier @ 1:5 {
+        Value: "x"
+      }
+      Value: *ast.InfixExpression @ 1:12 {
+        Left: *ast.PrefixExpression @ 1:9 {
+          Operator: "-"
+          Right: *ast.IntegerLiteral @ 1:10 {
+            Value: 1
+          }
+        }
+        Operator: "+"
+        Right: *ast.CallExpression @ 1:15 {
+          Function: *ast.Identifier @ 1:14 {
+            Value: "f"
+          }
+          Arguments: [
+            0: *ast.Boolean @ 1:16 {
+              Value: true
+            }
+          ]
+        }
+      }
+    }
+    1: *ast.ExpressionStatement @ 1:23 {
+      Expression: *ast.HashLiteral @ 1:23 {
+        Pairs: {
+          0: {
+            Key: *ast.StringLiteral @ 1:24 {
+              Value: "b"
+            }
+            Value: *ast.Identifier @ 1:29 {
+              Value: "x"
+            }
+          }
+          1: {
+            Key: *ast.StringLiteral @ 1:32 {
+              Value: "a"
+            }
+            Value: *ast.ArrayLiteral @ 1:37 {
+              Elements: []
+            }
+          }
+        }
+      }
+    }
+  ]
+}
+`
+
+	var out strings.Builder
+	assert.NoError(t, ast.Fprint(&out, program))
+	assert.Equal(t, expected, out.String())
+}
@@ -0,0 +1,254 @@
+package ast
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// Source returns node as Monkey source code. Unlike String, which is a
+// debugging aid, the result parses back into an equivalent tree: every
+// prefix and infix expression is parenthesized and statements are
+// terminated by semicolons, one per line, with blocks indented by tabs.
+func Source(node Node) string {
+	var p printer
+	p.node(node)
+	return p.String()
+}
+
+type printer struct {
+	strings.Builder
+	indent int
+}
+
+func (p *printer) newline() {
+	p.WriteByte('\n')
+	for i := 0; i < p.indent; i++ {
+		p.WriteByte('\t')
+	}
+}
+
+func (p *printer) node(node Node) {
+	if isNil(node) {
+		return
+	}
+
+	switch n := node.(type) {
+	case *Program:
+		for i, stmt := range n.Statements {
+			if i > 0 {
+				p.newline()
+			}
+			p.node(stmt)
+		}
+	case *LetStatement:
+		p.WriteString("let ")
+		p.node(n.Name)
+		p.WriteString(" = ")
+		p.node(n.Value)
+		p.WriteByte(';')
+	case *ReturnStatement:
+		p.WriteString("return ")
+		p.node(n.ReturnValue)
+		p.WriteByte(';')
+	case *DeferStatement:
+		p.WriteString("defer ")
+		p.node(n.Call)
+		p.WriteByte(';')
+	case *ExpressionStatement:
+		p.node(n.Expression)
+		p.WriteByte(';')
+	case *BlockStatement:
+		p.block(n)
+	case *Identifier:
+		p.WriteString(n.Value)
+	case *IntegerLiteral:
+		p.WriteString(n.Token.Literal)
+	case *FloatLiteral:
+		p.WriteString(n.Token.Literal)
+	case *DurationLiteral:
+		p.WriteString(n.Token.Literal)
+	case *Boolean:
+		p.WriteString(n.Token.Literal)
+	case *StringLiteral:
+		p.string(n.Value)
+	case *PrefixExpression:
+		p.WriteString("(" + n.Operator)
+		p.node(n.Right)
+		p.WriteByte(')')
+	case *AssignExpression:
+		p.WriteByte('(')
+		p.node(n.Name)
+		p.WriteString(" = ")
+		p.node(n.Value)
+		p.WriteByte(')')
+	case *InfixExpression:
+		p.WriteByte('(')
+		p.node(n.Left)
+		p.WriteString(" " + n.Operator + " ")
+		p.node(n.Right)
+		p.WriteByte(')')
+	case *IfExpression:
+		p.condition("if", n.Condition)
+		p.block(n.Consequence)
+		if n.Alternative != nil {
+			p.WriteString(" else ")
+			p.block(n.Alternative)
+		}
+	case *WhileExpression:
+		p.condition("while", n.Condition)
+		p.block(n.Body)
+	case *ForExpression:
+		p.WriteString("for (")
+		if n.Init != nil {
+			p.node(n.Init)
+		} else {
+			p.WriteByte(';')
+		}
+		if n.Condition != nil {
+			p.WriteByte(' ')
+			p.node(n.Condition)
+		}
+		p.WriteByte(';')
+		if n.Update != nil {
+			p.WriteByte(' ')
+			p.node(n.Update)
+		}
+		p.WriteString(") ")
+		p.block(n.Body)
+	case *ForInExpression:
+		p.WriteString("for (" + n.Variable.Value + " in ")
+		p.node(n.Iterable)
+		p.WriteString(") ")
+		p.block(n.Body)
+	case *FunctionLiteral:
+		p.WriteString("fn(")
+		for i, param := range n.Parameters {
+			if i > 0 {
+				p.WriteString(", ")
+			}
+			p.node(param)
+		}
+		p.WriteString(") ")
+		p.block(n.Body)
+	case *CallExpression:
+		p.node(n.Function)
+		p.WriteByte('(')
+		p.list(n.Arguments)
+		p.WriteByte(')')
+	case *ArrayLiteral:
+		p.WriteByte('[')
+		p.list(n.Elements)
+		p.WriteByte(']')
+	case *IndexExpression:
+		p.WriteByte('(')
+		p.node(n.Left)
+		p.WriteByte('[')
+		p.node(n.Index)
+		p.WriteString("])")
+	case *MemberExpression:
+		p.WriteByte('(')
+		p.node(n.Left)
+		p.WriteByte('.')
+		p.node(n.Property)
+		p.WriteByte(')')
+	case *HashLiteral:
+		p.WriteByte('{')
+		for i, key := range n.Keys() {
+			if i > 0 {
+				p.WriteString(", ")
+			}
+			p.node(key)
+			p.WriteString(": ")
+			p.node(n.Pairs[key])
+		}
+		p.WriteByte('}')
+	}
+}
+
+// condition prints keyword and cond, which must be parenthesized, as
+// operator expressions already are.
+func (p *printer) condition(keyword string, cond Expression) {
+	switch cond.(type) {
+	case *PrefixExpression, *InfixExpression, *IndexExpression, *MemberExpression, *AssignExpression:
+		p.WriteString(keyword + " ")
+		p.node(cond)
+		p.WriteString(" ")
+	default:
+		p.WriteString(keyword + " (")
+		p.node(cond)
+		p.WriteString(") ")
+	}
+}
+
+func (p *printer) block(block *BlockStatement) {
+	if block == nil || len(block.Statements) == 0 {
+		p.WriteString("{}")
+		return
+	}
+
+	p.WriteByte('{')
+	p.indent++
+	for _, stmt := range block.Statements {
+		p.newline()
+		p.node(stmt)
+	}
+	p.indent--
+	p.newline()
+	p.WriteByte('}')
+}
+
+func (p *printer) list(exps []Expression) {
+	for i, exp := range exps {
+		if i > 0 {
+			p.WriteString(", ")
+		}
+		p.node(exp)
+	}
+}
+
+// Keys returns the keys of hl in source order. Keys without a position are
+// ordered by their text so that the result is always deterministic.
+func (hl *HashLiteral) Keys() []Expression {
+	keys := make([]Expression, 0, len(hl.Pairs))
+	for key := range hl.Pairs {
+		keys = append(keys, key)
+	}
+
+	sortExpressions(keys)
+	return keys
+}
+
+// sortExpressions sorts exps by position, and by text for expressions at
+// the same or no position.
+func sortExpressions(exps []Expression) {
+	sort.Slice(exps, func(i, j int) bool {
+		pi, pj := exps[i].Pos(), exps[j].Pos()
+		if pi.Line != pj.Line {
+			return pi.Line < pj.Line
+		}
+		if pi.Column != pj.Column {
+			return pi.Column < pj.Column
+		}
+		return exps[i].String() < exps[j].String()
+	})
+}
+
+// string writes s as a string literal, or as a heredoc if it contains a
+// double quote, which a string literal cannot.
+func (p *printer) string(s string) {
+	if !strings.Contains(s, `"`) {
+		p.WriteString(`"` + s + `"`)
+		return
+	}
+
+	marker := "EOS"
+	lines := strings.Split(s, "\n")
+	for i := 1; slices.ContainsFunc(lines, func(line string) bool {
+		return strings.HasPrefix(strings.TrimLeft(line, " \t"), marker)
+	}); i++ {
+		marker = fmt.Sprintf("EOS%d", i)
+	}
+	p.WriteString("<<<" + marker + "\n" + s + "\n" + marker)
+}
@@ -0,0 +1,55 @@
+package ast_test
+
+import (
+	"testing"
+
+	"github.com/rock619/monkey/ast"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSource(t *testing.T) {
+	program := parse(t, `let f = fn(x) { if (x > 1) { return -x * 2; } else { x } }; f(3)[0];`)
+
+	expected := `let f = fn(x) {
+	if (x > 1) {
+		return ((-x) * 2);
+	} else {
+		x;
+	};
+};
+(f(3)[0]);`
+
+	assert.Equal(t, expected, ast.Source(program))
+}
+
+func TestSourceRoundTrip(t *testing.T) {
+	inputs := []string{
+		corpus,
+		"a + b * c - d / e;",
+		"-(1 + 2) * !true;",
+		"a * [1, 2, 3, 4][b * c] * d;",
+		"add(a, b, 1, 2 * 3, 4 + 5, add(6, 7 * 8));",
+		"fn() {}; fn(x) { x }(5);",
+		`{"b": 2, "a": 1, 3: fn() { "three" }}["a"];`,
+		"if (a) { b } else { if (c) { d } };",
+		"let x = 1 == 1 != false < 2;",
+		"x = y = 1 + 2; f(x = 3);",
+		"let r = 2.5e-3 * -1.0 + 7;",
+		"withTimeout(1h30m, f); 2 * 500ms;",
+		"while (i < 3) { i = i + 1; }; while (ok) { f(); };",
+		"for (let i = 0; i < n; i = i + 1) { f(i); }; for (;;) {}; for (i = 0; ; ) { g(); }; for (x in xs) { h(x); };",
+		"fn(f) { defer f(1, 2); defer close(h.file); };",
+		`f(1)[0].name(2)["k"]; -a.b[c](d).e; fn(x) { x }(1).y;`,
+		`{ let x = 1; {"a": x} }; {"b": 2}; fn() { { return 1; } };`,
+		"fn() { let s = <<<END\n\tsay \"hi\"\n\tEOS\n\tEND; s };",
+	}
+
+	for _, input := range inputs {
+		program := parse(t, input)
+		source := ast.Source(program)
+
+		reparsed := parse(t, source)
+		assert.Equal(t, program.String(), reparsed.String(), "input=%q source=%q", input, source)
+		assert.Equal(t, source, ast.Source(reparsed), "input=%q", input)
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/rock619/monkey/object"
+	"github.com/rock619/monkey/parser"
+	"github.com/rock619/monkey/token"
+)
+
+// A diagnostic is a problem found in a source file, in a form that editors
+// and CI pipelines can consume with -json.
+type diagnostic struct {
+	File     string `json:"file"`
+	Line     int    `json:"line"`
+	Col      int    `json:"col"`
+	Severity string `json:"severity"`
+	Code     string `json:"code"`
+	Message  string `json:"message"`
+}
+
+const (
+	codeSyntax  = "syntax"
+	codeRuntime = "runtime"
+)
+
+func newDiagnostic(pos token.Position, code, msg string) diagnostic {
+	return diagnostic{
+		File:     pos.Filename,
+		Line:     pos.Line,
+		Col:      pos.Column,
+		Severity: "error",
+		Code:     code,
+		Message:  msg,
+	}
+}
+
+func syntaxDiagnostics(errors []*parser.Error) []diagnostic {
+	diags := make([]diagnostic, len(errors))
+	for i, err := range errors {
+		diags[i] = newDiagnostic(err.Pos, codeSyntax, err.Msg)
+	}
+	return diags
+}
+
+func runtimeDiagnostic(err *object.Error) diagnostic {
+	return newDiagnostic(err.Pos, codeRuntime, err.Message)
+}
+
+// printDiagnostics writes diags to w, as one JSON object per line if asJSON
+// is set and as "file:line:col: message" otherwise.
+func printDiagnostics(w io.Writer, diags []diagnostic, asJSON bool) {
+	enc := json.NewEncoder(w)
+	for _, d := range diags {
+		if asJSON {
+			enc.Encode(d)
+			continue
+		}
+		pos := token.Position{Filename: d.File, Line: d.Line, Column: d.Col}
+		fmt.Fprintf(w, "%s: %s\n", pos, d.Message)
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/rock619/monkey/object"
+	"github.com/rock619/monkey/parser"
+	"github.com/rock619/monkey/token"
+)
+
+func TestParseFileDiagnostics(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "bad.mk")
+	if err := os.WriteFile(file, []byte("let x = 1;\nlet = 2;\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	syntax, _ := parser.LookupDialect(parser.DefaultDialect)
+	_, diags, err := parseFile(file, syntax)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diags) == 0 {
+		t.Fatal("parseFile reported no diagnostics")
+	}
+	d := diags[0]
+	if d.File != file || d.Line != 2 || d.Code != codeSyntax || d.Severity != "error" {
+		t.Errorf("diags[0] = %+v, want a syntax error at %s line 2", d, file)
+	}
+
+	if _, _, err := parseFile(filepath.Join(t.TempDir(), "missing.mk"), syntax); err == nil {
+		t.Error("parseFile of a missing file returned no error")
+	}
+}
+
+func TestPrintDiagnostics(t *testing.T) {
+	diags := []diagnostic{
+		newDiagnostic(token.Position{Filename: "a.mk", Line: 2, Column: 5}, codeSyntax, "unexpected ';'"),
+		runtimeDiagnostic(&object.Error{
+			Message: "identifier not found: y",
+			Pos:     token.Position{Filename: "b.mk", Line: 7, Column: 1},
+		}),
+	}
+
+	var text bytes.Buffer
+	printDiagnostics(&text, diags, false)
+	want := "a.mk:2:5: unexpected ';'\nb.mk:7:1: identifier not found: y\n"
+	if text.String() != want {
+		t.Errorf("text output = %q, want %q", text.String(), want)
+	}
+
+	var out bytes.Buffer
+	printDiagnostics(&out, diags, true)
+	var got []diagnostic
+	dec := json.NewDecoder(&out)
+	for dec.More() {
+		var d diagnostic
+		if err := dec.Decode(&d); err != nil {
+			t.Fatalf("decoding JSON output: %v", err)
+		}
+		got = append(got, d)
+	}
+	if !reflect.DeepEqual(got, diags) {
+		t.Errorf("JSON output decoded to %+v, want %+v", got, diags)
+	}
+
+	out.Reset()
+	printDiagnostics(&out, diags[:1], true)
+	wantJSON := `{"file":"a.mk","line":2,"col":5,"severity":"error","code":"syntax","message":"unexpected ';'"}` + "\n"
+	if out.String() != wantJSON {
+		t.Errorf("JSON output = %q, want %q", out.String(), wantJSON)
+	}
+}
@@ -0,0 +1,77 @@
+package evaluator
+
+import (
+	"sync"
+	"time"
+
+	"github.com/rock619/monkey/object"
+	"github.com/rock619/monkey/token"
+)
+
+// An AuditEntry records a call of a builtin that needs a capability.
+type AuditEntry struct {
+	Time time.Time
+	// Builtin is the name of the builtin, such as "exec".
+	Builtin    string
+	Capability Capabilities
+	// Args are the arguments of the call as they are inspected, such as
+	// the pattern passed to glob or the program and arguments of exec.
+	Args []string
+	// Pos is the position of the call.
+	Pos token.Position
+	// Denied is set when the Evaluator lacked the capability, so that the
+	// call failed.
+	Denied bool
+}
+
+// An AuditLog records the calls of builtins that need a capability, for
+// embedders that have to account for what scripts did. Set it as
+// Options.Audit and read it with Entries after each run. It is safe for
+// concurrent use, so that several Evaluators can share it.
+type AuditLog struct {
+	mu      sync.Mutex
+	entries []AuditEntry
+}
+
+func (l *AuditLog) record(entry AuditEntry) {
+	l.mu.Lock()
+	l.entries = append(l.entries, entry)
+	l.mu.Unlock()
+}
+
+// Entries returns the entries recorded so far, oldest first.
+func (l *AuditLog) Entries() []AuditEntry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]AuditEntry(nil), l.entries...)
+}
+
+// Reset removes all entries and returns them.
+func (l *AuditLog) Reset() []AuditEntry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	entries := l.entries
+	l.entries = nil
+	return entries
+}
+
+// audit records a call of the builtin name with args in the audit log of
+// e, if it has one.
+func (e *Evaluator) audit(c Capabilities, name string, args []object.Object, denied bool) {
+	if e.opts.Audit == nil {
+		return
+	}
+
+	inspected := make([]string, len(args))
+	for i, arg := range args {
+		inspected[i] = arg.Inspect()
+	}
+	e.opts.Audit.record(AuditEntry{
+		Time:       time.Now(),
+		Builtin:    name,
+		Capability: c,
+		Args:       inspected,
+		Pos:        e.callPos,
+		Denied:     denied,
+	})
+}
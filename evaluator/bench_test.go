@@ -0,0 +1,40 @@
+package evaluator
+
+import (
+	"testing"
+
+	"github.com/rock619/monkey/lexer"
+	"github.com/rock619/monkey/object"
+	"github.com/rock619/monkey/parser"
+)
+
+func benchmarkEval(b *testing.B, input string) {
+	program := parser.New(lexer.New(input)).ParseProgram()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if result := Eval(program, object.NewEnvironment()); isError(result) {
+			b.Fatal(result.Inspect())
+		}
+	}
+}
+
+func BenchmarkFib(b *testing.B) {
+	benchmarkEval(b, `
+let fib = fn(n) { if (n < 2) { n } else { fib(n - 1) + fib(n - 2) } };
+fib(20);`)
+}
+
+func BenchmarkLoop(b *testing.B) {
+	benchmarkEval(b, `
+let loop = fn(i, acc) { if (i == 0) { acc } else { loop(i - 1, acc + i * 2 - 1) } };
+loop(5000, 0);`)
+}
+
+func BenchmarkLeafCalls(b *testing.B) {
+	benchmarkEval(b, `
+let add = fn(a, b) { a + b };
+let loop = fn(i, acc) { if (i == 0) { acc } else { loop(i - 1, add(acc, 1)) } };
+loop(500, 0);`)
+}
@@ -0,0 +1,126 @@
+package evaluator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+
+	"github.com/rock619/monkey/object"
+)
+
+var (
+	contextType = reflect.TypeOf((*context.Context)(nil)).Elem()
+	errorType   = reflect.TypeOf((*error)(nil)).Elem()
+)
+
+// Bind sets the Go function target points to, such as a *func(string)
+// error, to call fn, a Monkey function or builtin, so that host code like
+// an event system can call scripts.
+//
+// Arguments are converted with object.FromGo and the result with
+// object.ToGoValue. The function must return an error, optionally preceded
+// by one other result; the error reports failed conversions, evaluation
+// errors and panics during evaluation. A first parameter of type
+// context.Context isn't passed to fn but interrupts its evaluation when
+// done.
+//
+// The bound function may be called from any goroutine. Its calls are
+// serialized, but they must not overlap with other uses of e.
+func (e *Evaluator) Bind(target any, fn object.Object) error {
+	ptr := reflect.ValueOf(target)
+	if ptr.Kind() != reflect.Pointer || ptr.IsNil() || ptr.Elem().Kind() != reflect.Func {
+		return fmt.Errorf("bind: target must be a non-nil pointer to a function, got %T", target)
+	}
+	typ := ptr.Elem().Type()
+
+	withContext := typ.NumIn() > 0 && typ.In(0) == contextType
+	params := typ.NumIn()
+	if withContext {
+		params--
+	}
+	if typ.IsVariadic() {
+		return fmt.Errorf("bind: %s is variadic", typ)
+	}
+	if typ.NumOut() == 0 || typ.NumOut() > 2 || typ.Out(typ.NumOut()-1) != errorType {
+		return fmt.Errorf("bind: %s must return error or a value and an error", typ)
+	}
+
+	switch fn := fn.(type) {
+	case *object.Function:
+		if len(fn.Parameters) != params {
+			return fmt.Errorf("bind: function takes %d arguments, %s takes %d",
+				len(fn.Parameters), typ, params)
+		}
+	case *object.Builtin:
+	default:
+		return fmt.Errorf("bind: not a function: %s", fn.Type())
+	}
+
+	ptr.Elem().Set(reflect.MakeFunc(typ, func(in []reflect.Value) []reflect.Value {
+		ctx := context.Background()
+		if withContext {
+			if c, ok := in[0].Interface().(context.Context); ok && c != nil {
+				ctx = c
+			}
+			in = in[1:]
+		}
+
+		var result reflect.Value
+		err := e.callBound(ctx, fn, in, func(obj object.Object) (err error) {
+			if typ.NumOut() == 2 {
+				result, err = object.ToGoValue(obj, typ.Out(0))
+			}
+			return err
+		})
+
+		out := []reflect.Value{reflect.Zero(errorType)}
+		if err != nil {
+			out[0] = reflect.ValueOf(&err).Elem()
+		}
+		if typ.NumOut() == 2 {
+			if err != nil || !result.IsValid() {
+				result = reflect.Zero(typ.Out(0))
+			}
+			out = append([]reflect.Value{result}, out...)
+		}
+		return out
+	}))
+	return nil
+}
+
+// callBound calls fn with the Go values in and passes its result to
+// convert, turning panics into errors.
+func (e *Evaluator) callBound(
+	ctx context.Context,
+	fn object.Object,
+	in []reflect.Value,
+	convert func(object.Object) error,
+) (err error) {
+	e.bindMu.Lock()
+	defer e.bindMu.Unlock()
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic during evaluation: %v", r)
+		}
+	}()
+
+	args := make([]object.Object, len(in))
+	for i, v := range in {
+		arg, err := object.FromGo(v.Interface())
+		if err != nil {
+			return fmt.Errorf("argument %d: %w", i+1, err)
+		}
+		args[i] = arg
+	}
+
+	result := e.Call(ctx, fn, args...)
+	if errObj, ok := result.(*object.Error); ok {
+		if errObj.Pos.IsValid() {
+			return fmt.Errorf("%s: %s", errObj.Pos, errObj.Message)
+		}
+		return errors.New(errObj.Message)
+	}
+	return convert(result)
+}
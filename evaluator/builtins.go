@@ -1,118 +1,495 @@
 package evaluator
 
 import (
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"fmt"
+	"io"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode/utf8"
 
 	"github.com/rock619/monkey/object"
 )
 
-var builtins = map[string]*object.Builtin{
-	"len": {
-		Fn: func(args ...object.Object) object.Object {
-			if len(args) != 1 {
-				return newError("wrong number of arguments. got=%d, want=1",
-					len(args))
-			}
+// newBuiltins returns the builtin functions of e.
+func newBuiltins(e *Evaluator) map[string]*object.Builtin {
+	opts := e.opts
 
-			switch arg := args[0].(type) {
-			case *object.Array:
-				return &object.Integer{Value: int64(len(arg.Elements))}
-			case *object.String:
-				return &object.Integer{Value: int64(len(arg.Value))}
-			default:
-				return newError("argument to `len` not supported, got %s",
-					args[0].Type())
-			}
+	return map[string]*object.Builtin{
+		"len": {
+			Fn: func(args ...object.Object) object.Object {
+				if len(args) != 1 {
+					return newError("wrong number of arguments. got=%d, want=1",
+						len(args))
+				}
+
+				sized, ok := args[0].(object.Sized)
+				if !ok {
+					return newError("argument to `len` not supported, got %s",
+						args[0].Type())
+				}
+				return newInteger(int64(sized.Len()))
+			},
 		},
-	},
-	"first": {
-		Fn: func(args ...object.Object) object.Object {
-			if len(args) != 1 {
-				return newError("wrong number of arguments. got=%d, want=1",
-					len(args))
-			}
-			if args[0].Type() != object.ARRAY_OBJ {
-				return newError("argument to `first` must be ARRAY, got %s",
-					args[0].Type())
-			}
+		"first": {
+			Fn: func(args ...object.Object) object.Object {
+				if len(args) != 1 {
+					return newError("wrong number of arguments. got=%d, want=1",
+						len(args))
+				}
 
-			arr := args[0].(*object.Array)
-			if len(arr.Elements) > 0 {
-				return arr.Elements[0]
-			}
+				switch arg := args[0].(type) {
+				case *object.Array:
+					if len(arg.Elements) > 0 {
+						return arg.Elements[0]
+					}
+				case *object.String:
+					if r, size := utf8.DecodeRuneInString(arg.Value); size > 0 {
+						return &object.String{Value: string(r)}
+					}
+				default:
+					return newError("argument to `first` must be ARRAY or STRING, got %s",
+						args[0].Type())
+				}
 
-			return NULL
+				return empty(opts, "first", args[0])
+			},
 		},
-	},
-	"last": {
-		Fn: func(args ...object.Object) object.Object {
-			if len(args) != 1 {
-				return newError("wrong number of arguments. got=%d, want=1",
-					len(args))
-			}
-			if args[0].Type() != object.ARRAY_OBJ {
-				return newError("argument to `last` must be ARRAY, got %s",
-					args[0].Type())
-			}
+		"last": {
+			Fn: func(args ...object.Object) object.Object {
+				if len(args) != 1 {
+					return newError("wrong number of arguments. got=%d, want=1",
+						len(args))
+				}
 
-			arr := args[0].(*object.Array)
-			length := len(arr.Elements)
-			if length > 0 {
-				return arr.Elements[length-1]
-			}
+				switch arg := args[0].(type) {
+				case *object.Array:
+					length := len(arg.Elements)
+					if length > 0 {
+						return arg.Elements[length-1]
+					}
+				case *object.String:
+					if r, size := utf8.DecodeLastRuneInString(arg.Value); size > 0 {
+						return &object.String{Value: string(r)}
+					}
+				default:
+					return newError("argument to `last` must be ARRAY or STRING, got %s",
+						args[0].Type())
+				}
 
-			return NULL
+				return empty(opts, "last", args[0])
+			},
 		},
-	},
-	"rest": {
-		Fn: func(args ...object.Object) object.Object {
-			if len(args) != 1 {
-				return newError("wrong number of arguments. got=%d, want=1",
-					len(args))
-			}
-			if args[0].Type() != object.ARRAY_OBJ {
-				return newError("argument to `rest` must be ARRAY, got %s",
-					args[0].Type())
-			}
+		"rest": {
+			Fn: func(args ...object.Object) object.Object {
+				if len(args) != 1 {
+					return newError("wrong number of arguments. got=%d, want=1",
+						len(args))
+				}
+
+				switch arg := args[0].(type) {
+				case *object.Array:
+					length := len(arg.Elements)
+					if length > 0 {
+						newElements := make([]object.Object, length-1)
+						copy(newElements, arg.Elements[1:length])
+						return &object.Array{Elements: newElements}
+					}
+				case *object.String:
+					if _, size := utf8.DecodeRuneInString(arg.Value); size > 0 {
+						return &object.String{Value: arg.Value[size:]}
+					}
+				default:
+					return newError("argument to `rest` must be ARRAY or STRING, got %s",
+						args[0].Type())
+				}
+
+				return empty(opts, "rest", args[0])
+			},
+		},
+		"push": {
+			Fn: func(args ...object.Object) object.Object {
+				if len(args) != 2 {
+					return newError("wrong number of arguments. got=%d, want=2",
+						len(args))
+				}
+				if args[0].Type() != object.ARRAY_OBJ {
+					return newError("argument to `push` must be ARRAY, got %s",
+						args[0].Type())
+				}
+
+				arr := args[0].(*object.Array)
+				length := len(arr.Elements)
+
+				newElements := make([]object.Object, length+1)
+				copy(newElements, arr.Elements)
+				newElements[length] = args[1]
 
-			arr := args[0].(*object.Array)
-			length := len(arr.Elements)
-			if length > 0 {
-				newElements := make([]object.Object, length-1)
-				copy(newElements, arr.Elements[1:length])
 				return &object.Array{Elements: newElements}
-			}
+			},
+		},
+		// bool always converts with extended truthiness, so that it gives the
+		// intuitive answer whatever the evaluator options are.
+		"bool": {
+			Fn: func(args ...object.Object) object.Object {
+				if len(args) != 1 {
+					return newError("wrong number of arguments. got=%d, want=1",
+						len(args))
+				}
+				return nativeBoolToBooleanObject(isTruthy(args[0], true))
+			},
+		},
+		"import": {
+			Fn: func(args ...object.Object) object.Object {
+				if len(args) != 1 {
+					return newError("wrong number of arguments. got=%d, want=1",
+						len(args))
+				}
+				name, ok := args[0].(*object.String)
+				if !ok {
+					return newError("argument to `import` must be STRING, got %s",
+						args[0].Type())
+				}
+				return e.importModule(name.Value)
+			},
+		},
+		"puts": {
+			Fn: func(args ...object.Object) object.Object {
+				lines, err := e.toStrings(args)
+				if err != nil {
+					return err
+				}
+				for _, line := range lines {
+					fmt.Fprintln(e.out, line)
+				}
+				if opts.PutsReturnsValue && len(args) > 0 {
+					return args[len(args)-1]
+				}
+				return NULL
+			},
+		},
+		// print writes its arguments separated by spaces, without a
+		// newline.
+		"print": {
+			Fn: func(args ...object.Object) object.Object {
+				s, err := e.toStrings(args)
+				if err != nil {
+					return err
+				}
+				fmt.Fprint(e.out, strings.Join(s, " "))
+				return NULL
+			},
+		},
+		// log writes its arguments separated by spaces to the error stream.
+		"log": {
+			Fn: func(args ...object.Object) object.Object {
+				s, err := e.toStrings(args)
+				if err != nil {
+					return err
+				}
+				// The streams may be the same, so keep this after what
+				// was printed before.
+				e.Flush()
+				fmt.Fprintln(e.err, strings.Join(s, " "))
+				return NULL
+			},
+		},
+		// readLine returns the next line of input without its line ending,
+		// or null at the end of the input.
+		"readLine": {
+			Fn: func(args ...object.Object) object.Object {
+				if len(args) != 0 {
+					return newError("wrong number of arguments. got=%d, want=0",
+						len(args))
+				}
 
-			return NULL
+				// Show a prompt printed without a newline.
+				e.Flush()
+				line, err := e.in.ReadString('\n')
+				if err == io.EOF && line == "" {
+					return NULL
+				}
+				if err != nil && err != io.EOF {
+					return newError("readLine: %s", err)
+				}
+				line = strings.TrimSuffix(line, "\n")
+				return &object.String{Value: strings.TrimSuffix(line, "\r")}
+			},
 		},
-	},
-	"push": {
-		Fn: func(args ...object.Object) object.Object {
-			if len(args) != 2 {
-				return newError("wrong number of arguments. got=%d, want=2",
-					len(args))
-			}
-			if args[0].Type() != object.ARRAY_OBJ {
-				return newError("argument to `push` must be ARRAY, got %s",
-					args[0].Type())
-			}
+		"formatNumber": {
+			Fn: func(args ...object.Object) object.Object {
+				if len(args) != 1 && len(args) != 2 {
+					return newError("wrong number of arguments. got=%d, want=1 or 2",
+						len(args))
+				}
+				if !isNumber(args[0]) {
+					return newError("argument to `formatNumber` must be a number, got %s",
+						args[0].Type())
+				}
 
-			arr := args[0].(*object.Array)
-			length := len(arr.Elements)
+				format := defaultNumberFormat
+				if len(args) == 2 {
+					var err object.Object
+					if format, err = parseNumberFormat(args[1]); err != nil {
+						return err
+					}
+				}
+				if n, ok := args[0].(*object.Integer); ok {
+					return &object.String{Value: format.integer(n.Value)}
+				}
+				return &object.String{Value: format.float(args[0].(*object.Float).Value)}
+			},
+		},
+		"encodeBase64": stringFunction("encodeBase64", func(s string) (string, error) {
+			return base64.StdEncoding.EncodeToString([]byte(s)), nil
+		}),
+		"decodeBase64": stringFunction("decodeBase64", func(s string) (string, error) {
+			b, err := base64.StdEncoding.DecodeString(s)
+			return string(b), err
+		}),
+		"encodeHex": stringFunction("encodeHex", func(s string) (string, error) {
+			return hex.EncodeToString([]byte(s)), nil
+		}),
+		"decodeHex": stringFunction("decodeHex", func(s string) (string, error) {
+			b, err := hex.DecodeString(s)
+			return string(b), err
+		}),
+		"urlEncode": stringFunction("urlEncode", func(s string) (string, error) {
+			return url.QueryEscape(s), nil
+		}),
+		"urlDecode": stringFunction("urlDecode", url.QueryUnescape),
+		"sha256": stringFunction("sha256", func(s string) (string, error) {
+			sum := sha256.Sum256([]byte(s))
+			return hex.EncodeToString(sum[:]), nil
+		}),
+		"md5": stringFunction("md5", func(s string) (string, error) {
+			sum := md5.Sum([]byte(s))
+			return hex.EncodeToString(sum[:]), nil
+		}),
+		// The path functions only work on strings and don't look at the
+		// file system, so unlike glob they need no capability.
+		"pathJoin": {
+			Fn: func(args ...object.Object) object.Object {
+				elems := make([]string, len(args))
+				for i, arg := range args {
+					s, ok := arg.(*object.String)
+					if !ok {
+						return newError("arguments to `pathJoin` must be STRING, got %s",
+							arg.Type())
+					}
+					elems[i] = s.Value
+				}
+				return &object.String{Value: filepath.Join(elems...)}
+			},
+		},
+		"dirname": stringFunction("dirname", func(s string) (string, error) {
+			return filepath.Dir(s), nil
+		}),
+		"basename": stringFunction("basename", func(s string) (string, error) {
+			return filepath.Base(s), nil
+		}),
+		"ext": stringFunction("ext", func(s string) (string, error) {
+			return filepath.Ext(s), nil
+		}),
+		// glob returns the files matching a pattern such as "*.mky" in
+		// lexical order.
+		"glob": {
+			Fn: func(args ...object.Object) object.Object {
+				if err := e.require(CapIO, "glob", args); err != nil {
+					return err
+				}
+				if len(args) != 1 {
+					return newError("wrong number of arguments. got=%d, want=1",
+						len(args))
+				}
+				pattern, ok := args[0].(*object.String)
+				if !ok {
+					return newError("argument to `glob` must be STRING, got %s",
+						args[0].Type())
+				}
 
-			newElements := make([]object.Object, length+1)
-			copy(newElements, arr.Elements)
-			newElements[length] = args[1]
+				matches, err := filepath.Glob(pattern.Value)
+				if err != nil {
+					return newError("glob: %s", err)
+				}
+				elements := make([]object.Object, len(matches))
+				for i, match := range matches {
+					elements[i] = &object.String{Value: match}
+				}
+				return &object.Array{Elements: elements}
+			},
+		},
+		// exec runs a program with a list of arguments and returns its
+		// output and exit code as {"stdout": ..., "stderr": ..., "code": ...}.
+		// The program is killed when the evaluation is interrupted.
+		"exec": {
+			Fn: func(args ...object.Object) object.Object {
+				if err := e.require(CapExec, "exec", args); err != nil {
+					return err
+				}
+				return e.exec(args)
+			},
+		},
+		// withTimeout calls a function of no arguments and returns its
+		// result, or an error if it takes longer than a duration or a
+		// number of milliseconds.
+		"withTimeout": {
+			Fn: func(args ...object.Object) object.Object {
+				return e.withTimeout(args)
+			},
+		},
+		// retry calls a function of no arguments up to a number of times,
+		// until it doesn't return an error, and returns its last result.
+		"retry": {
+			Fn: func(args ...object.Object) object.Object {
+				if len(args) != 2 {
+					return newError("wrong number of arguments. got=%d, want=2",
+						len(args))
+				}
+				times, ok := args[0].(*object.Integer)
+				if !ok || times.Value < 1 {
+					return newError("first argument to `retry` must be a positive INTEGER, got %s",
+						args[0].Inspect())
+				}
+				if err := checkCallback("retry", args[1], 0); err != nil {
+					return err
+				}
+				return e.retry(backoff{times: times.Value}, args[1])
+			},
+		},
+		// retryWithBackoff is retry waiting between calls, with options
+		// {"times": 3, "delay": 100ms, "factor": 2, "maxDelay": 0}, the delays
+		// being durations or milliseconds.
+		"retryWithBackoff": {
+			Fn: func(args ...object.Object) object.Object {
+				if len(args) != 2 {
+					return newError("wrong number of arguments. got=%d, want=2",
+						len(args))
+				}
+				b, err := parseBackoff(args[0])
+				if err != nil {
+					return err
+				}
+				if err := checkCallback("retryWithBackoff", args[1], 0); err != nil {
+					return err
+				}
+				return e.retry(b, args[1])
+			},
+		},
+		// now returns the current time.
+		"now": {
+			Fn: func(args ...object.Object) object.Object {
+				if len(args) != 0 {
+					return newError("wrong number of arguments. got=%d, want=0",
+						len(args))
+				}
+				if err := e.require(CapClock, "now", args); err != nil {
+					return err
+				}
+				return &object.Time{Value: time.Now()}
+			},
+		},
+		"parseTime": {
+			Fn: func(args ...object.Object) object.Object {
+				return parseTime(args)
+			},
+		},
+		"formatTime": {
+			Fn: func(args ...object.Object) object.Object {
+				return formatTime(args)
+			},
+		},
+		"addDuration": {
+			Fn: func(args ...object.Object) object.Object {
+				return addDuration(args)
+			},
+		},
+		// memoize returns a function that calls a pure function and caches
+		// its results by arguments.
+		"memoize": {
+			Fn: func(args ...object.Object) object.Object {
+				return e.memoize(args)
+			},
+		},
+		// expect returns matchers for a value, as in
+		// expect(x).toEqual(y), for tests written in Monkey.
+		"expect": {
+			Fn: func(args ...object.Object) object.Object {
+				if len(args) != 1 {
+					return newError("wrong number of arguments. got=%d, want=1",
+						len(args))
+				}
+				return e.expect(args[0])
+			},
+		},
+		// eachCase calls a function with every case of a table test.
+		"eachCase": {
+			Fn: func(args ...object.Object) object.Object {
+				return e.eachCase(args)
+			},
+		},
+		// hmacSha256 returns the HMAC-SHA256 of a message with a key.
+		"hmacSha256": {
+			Fn: func(args ...object.Object) object.Object {
+				if len(args) != 2 {
+					return newError("wrong number of arguments. got=%d, want=2",
+						len(args))
+				}
+				key, ok := args[0].(*object.String)
+				if !ok {
+					return newError("first argument to `hmacSha256` must be STRING, got %s",
+						args[0].Type())
+				}
+				message, ok := args[1].(*object.String)
+				if !ok {
+					return newError("second argument to `hmacSha256` must be STRING, got %s",
+						args[1].Type())
+				}
 
-			return &object.Array{Elements: newElements}
+				mac := hmac.New(sha256.New, []byte(key.Value))
+				mac.Write([]byte(message.Value))
+				return &object.String{Value: hex.EncodeToString(mac.Sum(nil))}
+			},
 		},
-	},
-	"puts": {
+	}
+}
+
+// stringFunction makes a builtin of one STRING argument from fn. Errors
+// returned by fn are reported as error objects.
+func stringFunction(name string, fn func(string) (string, error)) *object.Builtin {
+	return &object.Builtin{
 		Fn: func(args ...object.Object) object.Object {
-			for _, arg := range args {
-				fmt.Println(arg.Inspect())
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1",
+					len(args))
+			}
+			arg, ok := args[0].(*object.String)
+			if !ok {
+				return newError("argument to `%s` must be STRING, got %s",
+					name, args[0].Type())
 			}
-			return NULL
+
+			result, err := fn(arg.Value)
+			if err != nil {
+				return newError("%s: %s", name, err)
+			}
+			return &object.String{Value: result}
 		},
-	},
+	}
+}
+
+// empty is the result of a builtin asked for an element of an empty
+// collection: null, or an error in strict mode.
+func empty(opts Options, name string, arg object.Object) object.Object {
+	if opts.Strict {
+		return newError("argument to `%s` must not be empty, got empty %s",
+			name, arg.Type())
+	}
+	return NULL
 }
@@ -0,0 +1,79 @@
+package evaluator
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/rock619/monkey/object"
+)
+
+// Capabilities grant scripts access to the world outside the interpreter.
+// Builtins that need a capability the Evaluator wasn't given fail with an
+// error. Pure builtins, such as string and hash functions, need none.
+type Capabilities uint
+
+const (
+	// CapIO allows builtins to access the file system, such as glob. Path
+	// functions such as pathJoin only work on strings and don't need it.
+	CapIO Capabilities = 1 << iota
+	// CapExec allows the exec builtin to run other programs.
+	CapExec
+	// CapClock allows the now builtin to read the current time. Without
+	// it, the results of a script don't depend on when it runs.
+	CapClock
+)
+
+var capabilityNames = []struct {
+	capability Capabilities
+	name       string
+}{
+	{CapIO, "io"},
+	{CapExec, "exec"},
+	{CapClock, "clock"},
+}
+
+// String returns the names of the capabilities in c separated by commas.
+func (c Capabilities) String() string {
+	var names []string
+	for _, cn := range capabilityNames {
+		if c&cn.capability != 0 {
+			names = append(names, cn.name)
+		}
+	}
+	return strings.Join(names, ",")
+}
+
+// ParseCapabilities parses a comma-separated list of capability names, as
+// returned by Capabilities.String.
+func ParseCapabilities(s string) (Capabilities, error) {
+	var c Capabilities
+	for _, name := range strings.Split(s, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+
+		found := false
+		for _, cn := range capabilityNames {
+			if cn.name == name {
+				c |= cn.capability
+				found = true
+			}
+		}
+		if !found {
+			return 0, fmt.Errorf("unknown capability %q", name)
+		}
+	}
+	return c, nil
+}
+
+// require returns an error if e wasn't given the capability c that the
+// builtin name, called with args, needs. The call is audited either way.
+func (e *Evaluator) require(c Capabilities, name string, args []object.Object) *object.Error {
+	if e.opts.Capabilities&c != c {
+		e.audit(c, name, args, true)
+		return newError("`%s` requires the %s capability", name, c)
+	}
+	e.audit(c, name, args, false)
+	return nil
+}
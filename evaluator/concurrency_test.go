@@ -0,0 +1,96 @@
+package evaluator
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/rock619/monkey/lexer"
+	"github.com/rock619/monkey/object"
+	"github.com/rock619/monkey/parser"
+)
+
+// TestConcurrentEvaluators runs independent interpreters side by side. Run
+// it with -race to check that they share no mutable state.
+func TestConcurrentEvaluators(t *testing.T) {
+	const workers = 8
+
+	input := `
+let list = import("list");
+let fib = fn(n) { if (n < 2) { n } else { fib(n - 1) + fib(n - 2) } };
+let h = {"a": 1, "b": 2, true: 3, 4: 5};
+let total = list["sum"](map(range(0, 20), fn(x) { x * 2 }));
+[fib(15), h["a"] + h["b"] + h[true] + h[4], total, len("monkey")];
+`
+	expected := "[610, 11, 380, 6]"
+
+	var wg sync.WaitGroup
+	results := make([]string, workers)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			p := parser.New(lexer.NewFile(fmt.Sprintf("worker%d.mky", i), input))
+			program := p.ParseProgram()
+			if len(p.Errors()) != 0 {
+				results[i] = fmt.Sprint(p.Errors())
+				return
+			}
+
+			e := New(Options{Prelude: true})
+			var result object.Object
+			for j := 0; j < 5; j++ {
+				result = e.Eval(context.Background(), program, e.NewEnvironment())
+			}
+			results[i] = result.Inspect()
+		}(i)
+	}
+	wg.Wait()
+
+	for i, result := range results {
+		if result != expected {
+			t.Errorf("worker %d: wrong result. got=%s, want=%s", i, result, expected)
+		}
+	}
+}
+
+// TestSharedProgram evaluates one parsed program in several interpreters at
+// once, as pooled rule engines do. Run it with -race to check that
+// evaluation never writes to the tree.
+func TestSharedProgram(t *testing.T) {
+	const workers = 8
+
+	input := `
+let counter = fn() { let n = 0; fn() { n = n + 1; n } };
+let add = memoize(fn(a, b) { a + b });
+let c = counter();
+c(); c();
+add(c(), add(1, 2));
+`
+	p := parser.NewWithOptions(lexer.New(input), parser.Options{Assignment: true})
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+
+	var wg sync.WaitGroup
+	results := make([]string, workers)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			e := New(Options{})
+			results[i] = e.Eval(context.Background(), program, e.NewEnvironment()).Inspect()
+		}(i)
+	}
+	wg.Wait()
+
+	for i, result := range results {
+		if result != "6" {
+			t.Errorf("worker %d: wrong result. got=%s, want=6", i, result)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package evaluator
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/rock619/monkey/object"
+)
+
+// maxDiffLines is the number of differences diff describes before it
+// only counts the rest.
+const maxDiffLines = 10
+
+// diff describes where the contents of got differ from want, one line per
+// element, such as
+//
+//	[1]["name"]: got "b", want "a"
+//	[2]: missing, want 3
+//
+// It returns nil unless got and want are both arrays or both hashes, which
+// are better described as a whole.
+func diff(got, want object.Object) []string {
+	switch got.(type) {
+	case *object.Array:
+		if _, ok := want.(*object.Array); !ok {
+			return nil
+		}
+	case *object.Hash:
+		if _, ok := want.(*object.Hash); !ok {
+			return nil
+		}
+	default:
+		return nil
+	}
+
+	var d differ
+	d.diff("", got, want, object.Visited{})
+	if n := len(d.lines) - maxDiffLines; n > 0 {
+		d.lines = append(d.lines[:maxDiffLines], fmt.Sprintf("... and %d more", n))
+	}
+	return d.lines
+}
+
+type differ struct {
+	lines []string
+}
+
+func (d *differ) add(path, format string, a ...interface{}) {
+	d.lines = append(d.lines, path+": "+fmt.Sprintf(format, a...))
+}
+
+func (d *differ) diff(path string, got, want object.Object, visited object.Visited) {
+	switch got := got.(type) {
+	case *object.Array:
+		want, ok := want.(*object.Array)
+		if !ok || visited.Enter(got) {
+			break
+		}
+		defer visited.Leave(got)
+
+		for i := 0; i < max(len(got.Elements), len(want.Elements)); i++ {
+			elemPath := fmt.Sprintf("%s[%d]", path, i)
+			switch {
+			case i >= len(got.Elements):
+				d.add(elemPath, "missing, want %s", object.InspectElement(want.Elements[i]))
+			case i >= len(want.Elements):
+				d.add(elemPath, "got %s, want nothing", object.InspectElement(got.Elements[i]))
+			default:
+				d.diff(elemPath, got.Elements[i], want.Elements[i], visited)
+			}
+		}
+		return
+	case *object.Hash:
+		want, ok := want.(*object.Hash)
+		if !ok || visited.Enter(got) {
+			break
+		}
+		defer visited.Leave(got)
+
+		keys := make(map[object.HashKey]string)
+		for k, pair := range got.Pairs {
+			keys[k] = object.InspectElement(pair.Key)
+		}
+		for k, pair := range want.Pairs {
+			keys[k] = object.InspectElement(pair.Key)
+		}
+		sorted := make([]object.HashKey, 0, len(keys))
+		for k := range keys {
+			sorted = append(sorted, k)
+		}
+		sort.Slice(sorted, func(i, j int) bool { return keys[sorted[i]] < keys[sorted[j]] })
+
+		for _, k := range sorted {
+			elemPath := path + "[" + keys[k] + "]"
+			g, inGot := got.Pairs[k]
+			w, inWant := want.Pairs[k]
+			switch {
+			case !inGot:
+				d.add(elemPath, "missing, want %s", object.InspectElement(w.Value))
+			case !inWant:
+				d.add(elemPath, "got %s, want nothing", object.InspectElement(g.Value))
+			default:
+				d.diff(elemPath, g.Value, w.Value, visited)
+			}
+		}
+		return
+	}
+
+	if !deepEqual(got, want, visited) {
+		d.add(path, "got %s, want %s", object.InspectElement(got), object.InspectElement(want))
+	}
+}
+
+// describeDiff formats the lines of diff below a heading.
+func describeDiff(lines []string) string {
+	return "values differ:\n  " + strings.Join(lines, "\n  ")
+}
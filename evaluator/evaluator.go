@@ -1,105 +1,369 @@
 package evaluator
 
 import (
+	"bufio"
+	"context"
 	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"sync"
 
 	"github.com/rock619/monkey/ast"
 	"github.com/rock619/monkey/object"
+	"github.com/rock619/monkey/parser"
+	"github.com/rock619/monkey/token"
 )
 
+// Shorthands for the singletons of package object.
 var (
-	NULL  = &object.Null{}
-	TRUE  = &object.Boolean{Value: true}
-	FALSE = &object.Boolean{Value: false}
+	NULL  = object.NullValue
+	TRUE  = object.TrueValue
+	FALSE = object.FalseValue
 )
 
+// Evaluator evaluates Monkey programs. An Evaluator must not be used by
+// more than one goroutine at a time.
+type Evaluator struct {
+	ctx      context.Context
+	opts     Options
+	builtins map[string]*object.Builtin
+
+	// modules caches the modules loaded by import.
+	modules map[string]*object.Hash
+	prelude *object.Environment
+
+	// escapes caches createsClosures, and freeEnvs holds the environments
+	// of finished calls that can be reused.
+	escapes  map[*ast.BlockStatement]bool
+	freeEnvs []*object.Environment
+
+	// frames is the number of function calls in progress, and nesting
+	// the number of nodes being evaluated.
+	frames  int
+	nesting int
+	// deferred holds the calls deferred by each function call in
+	// progress, innermost last.
+	deferred [][]deferredCall
+
+	// stats is collected with the Stats option. builtinDepth is the number
+	// of builtin calls in progress while it is.
+	stats        *Stats
+	builtinDepth int
+
+	// steps and allocated count toward MaxSteps and MaxAllocBytes, and
+	// memoEntries toward MaxMemoEntries.
+	steps       int
+	allocated   int64
+	memoEntries int
+
+	// callPos is the position of the call being made, for the audit log.
+	// callee is its function, which names its span in a trace.
+	callPos token.Position
+	callee  ast.Expression
+
+	// bindMu serializes the calls of functions made by Bind.
+	bindMu sync.Mutex
+
+	in       *bufio.Reader
+	out, err io.Writer
+}
+
+// DefaultMaxFrames is the limit on nested function calls used when
+// Options.MaxFrames is zero.
+const DefaultMaxFrames = 10000
+
+// DefaultStackSize is the limit on nested evaluations used when
+// Options.StackSize is zero. It keeps the Go stack well below the size at
+// which the runtime aborts the process, about a million evaluations, while
+// leaving room for DefaultMaxFrames calls of several evaluations each.
+const DefaultStackSize = 500000
+
+// Options configures the semantics of an Evaluator. The zero value gives the
+// behavior of the book.
+type Options struct {
+	// ExtendedTruthiness makes 0 and empty collections such as "", [] and
+	// {} falsy in conditions and for the ! operator, in addition to false
+	// and null.
+	ExtendedTruthiness bool
+	// CheckedArithmetic makes integer operations whose result doesn't fit
+	// in an int64 return an error instead of silently wrapping around.
+	CheckedArithmetic bool
+	// Strict reports errors where the book quietly returns null, such as
+	// asking for the first element of an empty array.
+	Strict bool
+	// PutsReturnsValue makes puts return its last argument instead of
+	// null, so that it can wrap an expression while debugging.
+	PutsReturnsValue bool
+	// Prelude makes NewEnvironment provide the functions of the prelude
+	// module, such as map and filter, without an import.
+	Prelude bool
+	// MaxFrames limits how deeply function calls may nest before
+	// evaluation fails with a stack overflow error. Zero means
+	// DefaultMaxFrames.
+	MaxFrames int
+	// StackSize limits how deeply the evaluation of nodes may nest, counting
+	// each expression and statement being evaluated, so that a large
+	// MaxFrames or deeply nested code fails with a stack overflow error
+	// instead of exhausting the Go stack. Zero means DefaultStackSize.
+	StackSize int
+	// IO holds the streams builtins such as puts and readLine use.
+	IO IOStreams
+	// BufferOutput holds what builtins write to the output stream and
+	// passes it on a line at a time. See Evaluator.Flush.
+	BufferOutput bool
+	// Capabilities grants scripts access to the file system and the like.
+	Capabilities Capabilities
+	// Stats makes the Evaluator collect the figures returned by its Stats
+	// method, at some cost in speed.
+	Stats bool
+	// Audit, if not nil, records every call of a builtin that needs a
+	// capability, whether it was granted or not.
+	Audit *AuditLog
+	// ModulePath lists the directories import searches, in order, for a
+	// module name.mky that isn't part of the standard library.
+	ModulePath []string
+	// Syntax is the dialect the modules found in ModulePath are parsed in,
+	// normally that of the program. The standard library is always parsed
+	// in parser.DefaultDialect, which it is written in.
+	Syntax parser.Options
+	// Record, if not nil, records every statement run and the variables
+	// it changes, for debuggers that step backwards.
+	Record *Recording
+	// Trace, if not nil, records a span for every call.
+	Trace *Trace
+	// MaxSteps, if positive, limits the number of statements run and loop
+	// iterations over the life of the Evaluator.
+	MaxSteps int
+	// MaxAllocBytes, if positive, limits the total size of the strings,
+	// arrays and hashes created over the life of the Evaluator, as
+	// estimated from their lengths. Unlike a limit on the heap, it counts
+	// values that are no longer in use as well.
+	MaxAllocBytes int64
+	// MaxMemoEntries limits the number of results cached by the functions
+	// memoize returns, together. Zero means DefaultMaxMemoEntries.
+	MaxMemoEntries int
+}
+
+// IOStreams are the standard streams of an Evaluator. Nil fields mean
+// os.Stdin, os.Stdout and os.Stderr.
+type IOStreams struct {
+	In  io.Reader
+	Out io.Writer
+	Err io.Writer
+}
+
+func New(opts Options) *Evaluator {
+	e := &Evaluator{
+		ctx:     context.Background(),
+		opts:    opts,
+		modules: make(map[string]*object.Hash),
+		escapes: make(map[*ast.BlockStatement]bool),
+		in:      bufio.NewReader(os.Stdin),
+		out:     os.Stdout,
+		err:     os.Stderr,
+	}
+	if opts.IO.In != nil {
+		e.in = bufio.NewReader(opts.IO.In)
+	}
+	if opts.IO.Out != nil {
+		e.out = opts.IO.Out
+	}
+	if opts.IO.Err != nil {
+		e.err = opts.IO.Err
+	}
+	if opts.BufferOutput {
+		e.out = &lineWriter{w: e.out}
+	}
+	if opts.Stats {
+		e.stats = newStats()
+	}
+	e.builtins = newBuiltins(e)
+	return e
+}
+
+// Eval evaluates node in env. Evaluation stops with an error object as soon
+// as ctx is done.
+func (e *Evaluator) Eval(ctx context.Context, node ast.Node, env *object.Environment) object.Object {
+	e.ctx = ctx
+	defer e.Flush()
+	defer e.timeEval()()
+	return e.eval(node, env)
+}
+
+// Call calls fn, a function or builtin, with args. Like Eval, it stops with
+// an error object as soon as ctx is done.
+func (e *Evaluator) Call(ctx context.Context, fn object.Object, args ...object.Object) object.Object {
+	e.ctx = ctx
+	defer e.Flush()
+	defer e.timeEval()()
+	if result := e.applyFunction(fn, args); result != nil {
+		return result
+	}
+	return NULL
+}
+
+// Eval evaluates node in env with a new Evaluator that can't be interrupted.
 func Eval(node ast.Node, env *object.Environment) object.Object {
+	return New(Options{}).Eval(context.Background(), node, env)
+}
+
+// eval evaluates node and attributes errors that don't have a position yet
+// to it, so that an error points at the innermost node that failed.
+func (e *Evaluator) eval(node ast.Node, env *object.Environment) object.Object {
+	if e.nesting >= e.stackSize() {
+		return newError("stack overflow: evaluation nested too deeply")
+	}
+	e.nesting++
+	defer func() { e.nesting-- }()
+
+	obj := e.evalNode(node, env)
+	if e.stats != nil {
+		e.countNode(node, obj)
+	}
+	if e.opts.MaxAllocBytes > 0 {
+		obj = e.charge(node, obj)
+	}
+	if err, ok := obj.(*object.Error); ok && !err.Pos.IsValid() {
+		if _, isProgram := node.(*ast.Program); !isProgram {
+			err.Pos = node.Pos()
+		}
+	}
+	return obj
+}
+
+func (e *Evaluator) evalNode(node ast.Node, env *object.Environment) object.Object {
 	switch node := node.(type) {
 	case *ast.Program:
-		return evalProgram(node, env)
+		return e.evalProgram(node, env)
 	case *ast.ExpressionStatement:
-		return Eval(node.Expression, env)
+		return e.eval(node.Expression, env)
+	case *ast.DeferStatement:
+		return e.evalDeferStatement(node, env)
 	case *ast.IntegerLiteral:
-		return &object.Integer{Value: node.Value}
+		return newInteger(node.Value)
+	case *ast.FloatLiteral:
+		return &object.Float{Value: node.Value}
+	case *ast.DurationLiteral:
+		return &object.Duration{Value: node.Value}
 	case *ast.Boolean:
 		return nativeBoolToBooleanObject(node.Value)
 	case *ast.PrefixExpression:
-		right := Eval(node.Right, env)
-		if isError(right) {
+		right := e.eval(node.Right, env)
+		if stops(right) {
 			return right
 		}
-		return evalPrefixExpression(node.Operator, right)
+		return e.evalPrefixExpression(node.Operator, right)
+	case *ast.AssignExpression:
+		val := e.eval(node.Value, env)
+		if stops(val) {
+			return val
+		}
+		if err := e.assign(env, node.Name.Value, val); err != nil {
+			return newError("cannot assign to %s: %s", node.Name.Value, err)
+		}
+		return val
 	case *ast.InfixExpression:
-		left := Eval(node.Left, env)
-		if isError(left) {
+		left := e.eval(node.Left, env)
+		if stops(left) {
 			return left
 		}
-		right := Eval(node.Right, env)
-		if isError(right) {
+		right := e.eval(node.Right, env)
+		if stops(right) {
 			return right
 		}
-		return evalInfixExpression(node.Operator, left, right)
+
+		// Fast path for the most common case, integer arithmetic.
+		if _, ok := left.(*object.Integer); ok && !e.opts.CheckedArithmetic {
+			if _, ok := right.(*object.Integer); ok {
+				return evalIntegerInfixExpression(node.Operator, left, right)
+			}
+		}
+		return e.evalInfixExpression(node.Operator, left, right)
 	case *ast.BlockStatement:
-		return evalBlockStatement(node, env)
+		return e.evalBlockStatement(node, env)
 	case *ast.IfExpression:
-		return evalIfExpression(node, env)
+		return e.evalIfExpression(node, env)
+	case *ast.WhileExpression:
+		return e.evalWhileExpression(node, env)
+	case *ast.ForExpression:
+		return e.evalForExpression(node, env)
+	case *ast.ForInExpression:
+		return e.evalForInExpression(node, env)
 	case *ast.ReturnStatement:
-		val := Eval(node.ReturnValue, env)
-		if isError(val) {
+		val := e.eval(node.ReturnValue, env)
+		if stops(val) {
 			return val
 		}
 		return &object.ReturnValue{Value: val}
 	case *ast.LetStatement:
-		val := Eval(node.Value, env)
-		if isError(val) {
+		val := e.eval(node.Value, env)
+		if stops(val) {
 			return val
 		}
+		if e.opts.Record != nil {
+			old, _ := env.GetLocal(node.Name.Value)
+			e.opts.Record.change(env, node.Name.Value, old, val)
+		}
 		env.Set(node.Name.Value, val)
 	case *ast.Identifier:
-		return evalIdentifier(node, env)
+		return e.evalIdentifier(node, env)
 	case *ast.FunctionLiteral:
 		params := node.Parameters
 		body := node.Body
 		return &object.Function{Parameters: params, Env: env, Body: body}
 	case *ast.CallExpression:
-		function := Eval(node.Function, env)
-		if isError(function) {
+		function := e.eval(node.Function, env)
+		if stops(function) {
 			return function
 		}
-		args := evalExpressions(node.Arguments, env)
-		if len(args) == 1 && isError(args[0]) {
+		args := e.evalExpressions(node.Arguments, env)
+		if len(args) == 1 && stops(args[0]) {
 			return args[0]
 		}
-		return applyFunction(function, args)
+		e.callPos = node.Pos()
+		e.callee = node.Function
+		return e.applyFunction(function, args)
 	case *ast.StringLiteral:
 		return &object.String{Value: node.Value}
 	case *ast.ArrayLiteral:
-		elements := evalExpressions(node.Elements, env)
-		if len(elements) == 1 && isError(elements[0]) {
+		elements := e.evalExpressions(node.Elements, env)
+		if len(elements) == 1 && stops(elements[0]) {
 			return elements[0]
 		}
 		return &object.Array{Elements: elements}
 	case *ast.IndexExpression:
-		left := Eval(node.Left, env)
-		if isError(left) {
+		left := e.eval(node.Left, env)
+		if stops(left) {
 			return left
 		}
-		index := Eval(node.Index, env)
-		if isError(index) {
+		index := e.eval(node.Index, env)
+		if stops(index) {
 			return index
 		}
 		return evalIndexExpression(left, index)
+	case *ast.MemberExpression:
+		left := e.eval(node.Left, env)
+		if stops(left) {
+			return left
+		}
+		return evalMemberExpression(left, node.Property.Value)
 	case *ast.HashLiteral:
-		return evalHashLiteral(node, env)
+		return e.evalHashLiteral(node, env)
 	}
 	return nil
 }
 
-func evalProgram(program *ast.Program, env *object.Environment) object.Object {
+func (e *Evaluator) evalProgram(program *ast.Program, env *object.Environment) object.Object {
 	var result object.Object
 
 	for _, statement := range program.Statements {
-		result = Eval(statement, env)
+		if err := e.step(statement); err != nil {
+			return err
+		}
+
+		result = e.evalStatement(statement, env)
 
 		switch result := result.(type) {
 		case *object.ReturnValue:
@@ -112,11 +376,15 @@ func evalProgram(program *ast.Program, env *object.Environment) object.Object {
 	return result
 }
 
-func evalBlockStatement(block *ast.BlockStatement, env *object.Environment) object.Object {
+func (e *Evaluator) evalBlockStatement(block *ast.BlockStatement, env *object.Environment) object.Object {
 	var result object.Object
 
 	for _, statement := range block.Statements {
-		result = Eval(statement, env)
+		if err := e.step(statement); err != nil {
+			return err
+		}
+
+		result = e.evalStatement(statement, env)
 
 		if result != nil {
 			rt := result.Type()
@@ -126,62 +394,85 @@ func evalBlockStatement(block *ast.BlockStatement, env *object.Environment) obje
 		}
 	}
 
+	// A block that is empty or ends with a let statement has no value.
+	if result == nil {
+		return NULL
+	}
 	return result
 }
 
-func nativeBoolToBooleanObject(input bool) *object.Boolean {
-	if input {
-		return TRUE
+// interrupted reports an error once the evaluation's context is done.
+func (e *Evaluator) interrupted() *object.Error {
+	if err := e.ctx.Err(); err != nil {
+		return newError("evaluation interrupted: %s", err)
 	}
-	return FALSE
+	return nil
+}
+
+func nativeBoolToBooleanObject(input bool) *object.Boolean {
+	return object.BooleanValue(input)
 }
 
-func evalPrefixExpression(operator string, right object.Object) object.Object {
+func (e *Evaluator) evalPrefixExpression(operator string, right object.Object) object.Object {
 	switch operator {
 	case "!":
-		return evalBangOperatorExpression(right)
+		return nativeBoolToBooleanObject(!e.Truthy(right))
 	case "-":
-		return evalMinusPrefixOperatorExpression(right)
+		if float, ok := right.(*object.Float); ok {
+			return &object.Float{Value: -float.Value}
+		}
+		if duration, ok := right.(*object.Duration); ok {
+			return &object.Duration{Value: -duration.Value}
+		}
+		integer, ok := right.(*object.Integer)
+		if !ok {
+			return newError("unary - requires a number or %s, got %s",
+				object.DURATION_OBJ, right.Type())
+		}
+		if e.opts.CheckedArithmetic && integer.Value == math.MinInt64 {
+			return newError("integer overflow: -(%d)", integer.Value)
+		}
+		return newInteger(-integer.Value)
+	case "+":
+		if !isNumber(right) {
+			return newError("unary + requires a number, got %s", right.Type())
+		}
+		return right
 	default:
 		return newError("unknown operator: %s%s", operator, right.Type())
 	}
 }
 
-func evalBangOperatorExpression(right object.Object) object.Object {
-	switch right {
-	case TRUE:
-		return FALSE
-	case FALSE:
-		return TRUE
-	case NULL:
-		return TRUE
-	default:
-		return FALSE
-	}
-}
-
-func evalMinusPrefixOperatorExpression(right object.Object) object.Object {
-	if right.Type() != object.INTEGER_OBJ {
-		return newError("unknown operator: -%s", right.Type())
-	}
-
-	value := right.(*object.Integer).Value
-	return &object.Integer{Value: -value}
-}
-
-func evalInfixExpression(
+func (e *Evaluator) evalInfixExpression(
 	operator string,
 	left, right object.Object,
 ) object.Object {
 	switch {
 	case left.Type() == object.INTEGER_OBJ && right.Type() == object.INTEGER_OBJ:
+		if e.opts.CheckedArithmetic {
+			return evalCheckedIntegerInfixExpression(operator, left, right)
+		}
 		return evalIntegerInfixExpression(operator, left, right)
+	case isNumber(left) && isNumber(right):
+		return evalFloatInfixExpression(operator, left, right)
+	case timeOperands(operator, left, right):
+		return evalTimeInfixExpression(operator, left, right)
+	case left.Type() == object.STRING_OBJ && right.Type() == object.STRING_OBJ:
+		return evalStringInfixExpression(operator, left, right)
 	case operator == "==":
 		return nativeBoolToBooleanObject(left == right)
 	case operator == "!=":
 		return nativeBoolToBooleanObject(left != right)
-	case left.Type() == object.STRING_OBJ && right.Type() == object.STRING_OBJ:
-		return evalStringInfixExpression(operator, left, right)
+	case operator == "+" && concatenates(left, right):
+		l, err := e.toString(left)
+		if err != nil {
+			return err
+		}
+		r, err := e.toString(right)
+		if err != nil {
+			return err
+		}
+		return &object.String{Value: l + r}
 	case left.Type() != right.Type():
 		return newError("type mismatch: %s %s %s",
 			left.Type(), operator, right.Type())
@@ -191,6 +482,20 @@ func evalInfixExpression(
 	}
 }
 
+// concatenates reports whether + joins left and right as strings: one is a
+// string and the other has a toString function.
+func concatenates(left, right object.Object) bool {
+	if left.Type() == object.STRING_OBJ {
+		_, ok := toStringFunction(right)
+		return ok
+	}
+	if right.Type() == object.STRING_OBJ {
+		_, ok := toStringFunction(left)
+		return ok
+	}
+	return false
+}
+
 func evalIntegerInfixExpression(
 	operator string,
 	left, right object.Object,
@@ -200,13 +505,21 @@ func evalIntegerInfixExpression(
 
 	switch operator {
 	case "+":
-		return &object.Integer{Value: leftVal + rightVal}
+		return newInteger(leftVal + rightVal)
 	case "-":
-		return &object.Integer{Value: leftVal - rightVal}
+		return newInteger(leftVal - rightVal)
 	case "*":
-		return &object.Integer{Value: leftVal * rightVal}
+		return newInteger(leftVal * rightVal)
 	case "/":
-		return &object.Integer{Value: leftVal / rightVal}
+		if rightVal == 0 {
+			return newError("division by zero: %d / 0", leftVal)
+		}
+		return newInteger(leftVal / rightVal)
+	case "%":
+		if rightVal == 0 {
+			return newError("modulo by zero: %d %% 0", leftVal)
+		}
+		return newInteger(leftVal % rightVal)
 	case "<":
 		return nativeBoolToBooleanObject(leftVal < rightVal)
 	case ">":
@@ -221,22 +534,208 @@ func evalIntegerInfixExpression(
 	}
 }
 
-func evalIfExpression(ie *ast.IfExpression, env *object.Environment) object.Object {
-	condition := Eval(ie.Condition, env)
-	if isError(condition) {
+// evalCheckedIntegerInfixExpression is evalIntegerInfixExpression, except
+// that results which don't fit in an int64 are errors instead of wrapping.
+func evalCheckedIntegerInfixExpression(
+	operator string,
+	left, right object.Object,
+) object.Object {
+	a := left.(*object.Integer).Value
+	b := right.(*object.Integer).Value
+
+	var overflow bool
+	switch operator {
+	case "+":
+		c := a + b
+		overflow = (a^c)&(b^c) < 0
+	case "-":
+		c := a - b
+		overflow = (a^b)&(a^c) < 0
+	case "*":
+		c := a * b
+		overflow = a != 0 && (c/a != b || a == -1 && b == math.MinInt64)
+	case "/":
+		overflow = a == math.MinInt64 && b == -1
+	}
+	if overflow {
+		return newError("integer overflow: %d %s %d", a, operator, b)
+	}
+
+	return evalIntegerInfixExpression(operator, left, right)
+}
+
+// toFloat returns the value of a float or integer as a float64. Integers
+// are promoted so that mixed arithmetic is done in floating point.
+func toFloat(obj object.Object) (float64, bool) {
+	switch obj := obj.(type) {
+	case *object.Float:
+		return obj.Value, true
+	case *object.Integer:
+		return float64(obj.Value), true
+	default:
+		return 0, false
+	}
+}
+
+func isNumber(obj object.Object) bool {
+	_, ok := toFloat(obj)
+	return ok
+}
+
+// evalFloatInfixExpression applies operator to two numbers of which at
+// least one is a FLOAT, converting the other to a float.
+func evalFloatInfixExpression(operator string, left, right object.Object) object.Object {
+	a, _ := toFloat(left)
+	b, _ := toFloat(right)
+	switch operator {
+	case "+":
+		return &object.Float{Value: a + b}
+	case "-":
+		return &object.Float{Value: a - b}
+	case "*":
+		return &object.Float{Value: a * b}
+	case "/":
+		return &object.Float{Value: a / b}
+	case "%":
+		return &object.Float{Value: math.Mod(a, b)}
+	case "<":
+		return nativeBoolToBooleanObject(a < b)
+	case ">":
+		return nativeBoolToBooleanObject(a > b)
+	case "==":
+		return nativeBoolToBooleanObject(a == b)
+	case "!=":
+		return nativeBoolToBooleanObject(a != b)
+	default:
+		return newError("unknown operator: %s %s %s",
+			left.Type(), operator, right.Type())
+	}
+}
+
+// evalWhileExpression runs the body for as long as the condition is truthy,
+// in an environment of its own every iteration, as for loops do. The loop
+// itself evaluates to null; a return or an error in the body ends it early
+// with that instead.
+func (e *Evaluator) evalWhileExpression(we *ast.WhileExpression, env *object.Environment) object.Object {
+	for {
+		condition := e.eval(we.Condition, env)
+		if stops(condition) {
+			return condition
+		}
+		if !e.Truthy(condition) {
+			return NULL
+		}
+
+		if result := e.eval(we.Body, object.NewEnclosedEnvironment(env)); stops(result) {
+			return result
+		}
+		if err := e.step(we); err != nil {
+			return err
+		}
+	}
+}
+
+// evalForExpression runs a C-style loop. The variable declared by its init
+// statement is copied into a new environment before every update, as in
+// JavaScript, so closures made in the body see the value of their own
+// iteration. The body runs in an environment of its own every iteration.
+func (e *Evaluator) evalForExpression(fe *ast.ForExpression, env *object.Environment) object.Object {
+	loopEnv := object.NewEnclosedEnvironment(env)
+	if fe.Init != nil {
+		if result := e.eval(fe.Init, loopEnv); stops(result) {
+			return result
+		}
+	}
+
+	for {
+		if fe.Condition != nil {
+			condition := e.eval(fe.Condition, loopEnv)
+			if stops(condition) {
+				return condition
+			}
+			if !e.Truthy(condition) {
+				return NULL
+			}
+		}
+
+		if result := e.eval(fe.Body, object.NewEnclosedEnvironment(loopEnv)); stops(result) {
+			return result
+		}
+		if err := e.step(fe); err != nil {
+			return err
+		}
+
+		if let, ok := fe.Init.(*ast.LetStatement); ok {
+			val, _ := loopEnv.GetLocal(let.Name.Value)
+			loopEnv = object.NewEnclosedEnvironment(env)
+			loopEnv.Set(let.Name.Value, val)
+		}
+		if fe.Update != nil {
+			if result := e.eval(fe.Update, loopEnv); stops(result) {
+				return result
+			}
+		}
+	}
+}
+
+// evalForInExpression runs the body once for every element of an array,
+// character of a string or key of a hash, in an environment of its own
+// holding the loop variable. The keys of a hash are taken in the order of
+// object.CompareKeys.
+func (e *Evaluator) evalForInExpression(fe *ast.ForInExpression, env *object.Environment) object.Object {
+	iterable := e.eval(fe.Iterable, env)
+	if stops(iterable) {
+		return iterable
+	}
+
+	var items []object.Object
+	switch iterable := iterable.(type) {
+	case *object.Array:
+		items = iterable.Elements
+	case *object.String:
+		for _, r := range iterable.Value {
+			items = append(items, &object.String{Value: string(r)})
+		}
+	case *object.Hash:
+		for _, pair := range iterable.Pairs {
+			items = append(items, pair.Key)
+		}
+		sort.Slice(items, func(i, j int) bool {
+			return object.CompareKeys(items[i], items[j]) < 0
+		})
+	default:
+		return newError("cannot iterate over %s", iterable.Type())
+	}
+
+	for _, item := range items {
+		iterEnv := object.NewEnclosedEnvironment(env)
+		iterEnv.Set(fe.Variable.Value, item)
+		if result := e.eval(fe.Body, iterEnv); stops(result) {
+			return result
+		}
+		if err := e.step(fe); err != nil {
+			return err
+		}
+	}
+	return NULL
+}
+
+func (e *Evaluator) evalIfExpression(ie *ast.IfExpression, env *object.Environment) object.Object {
+	condition := e.eval(ie.Condition, env)
+	if stops(condition) {
 		return condition
 	}
 
-	if isTruthy(condition) {
-		return Eval(ie.Consequence, env)
+	if e.Truthy(condition) {
+		return e.eval(ie.Consequence, env)
 	} else if ie.Alternative != nil {
-		return Eval(ie.Alternative, env)
+		return e.eval(ie.Alternative, env)
 	} else {
 		return NULL
 	}
 }
 
-func evalIdentifier(
+func (e *Evaluator) evalIdentifier(
 	node *ast.Identifier,
 	env *object.Environment,
 ) object.Object {
@@ -244,22 +743,63 @@ func evalIdentifier(
 		return val
 	}
 
-	if builtin, ok := builtins[node.Value]; ok {
+	if builtin, ok := e.builtins[node.Value]; ok {
 		return builtin
 	}
 
+	if suggestion := e.suggestIdentifier(node.Value, env); suggestion != "" {
+		return newError("identifier not found: %s (did you mean %s?)", node.Value, suggestion)
+	}
 	return newError("identifier not found: " + node.Value)
 }
 
-func evalExpressions(
+// suggestIdentifier returns the visible name closest to name, or "" if
+// nothing is close enough to be a likely typo.
+func (e *Evaluator) suggestIdentifier(name string, env *object.Environment) string {
+	candidates := env.Names()
+	for builtin := range e.builtins {
+		candidates = append(candidates, builtin)
+	}
+	sort.Strings(candidates)
+
+	best, bestDistance := "", len(name)/2+1
+	for _, candidate := range candidates {
+		if d := levenshtein(name, candidate); d < bestDistance && d <= 2 {
+			best, bestDistance = candidate, d
+		}
+	}
+	return best
+}
+
+func levenshtein(a, b string) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(b)]
+}
+
+func (e *Evaluator) evalExpressions(
 	exps []ast.Expression,
 	env *object.Environment,
 ) []object.Object {
-	var result []object.Object
+	result := make([]object.Object, 0, len(exps))
 
-	for _, e := range exps {
-		evaluated := Eval(e, env)
-		if isError(evaluated) {
+	for _, exp := range exps {
+		evaluated := e.eval(exp, env)
+		if stops(evaluated) {
 			return []object.Object{evaluated}
 		}
 		result = append(result, evaluated)
@@ -268,7 +808,15 @@ func evalExpressions(
 	return result
 }
 
-func isTruthy(obj object.Object) bool {
+// Truthy reports whether obj counts as true in a condition under the
+// options of e.
+func (e *Evaluator) Truthy(obj object.Object) bool {
+	return isTruthy(obj, e.opts.ExtendedTruthiness)
+}
+
+// isTruthy reports whether obj counts as true. Only false and null are falsy
+// unless extended is set, in which case empty and zero values are too.
+func isTruthy(obj object.Object, extended bool) bool {
 	switch obj {
 	case NULL:
 		return false
@@ -276,6 +824,21 @@ func isTruthy(obj object.Object) bool {
 		return true
 	case FALSE:
 		return false
+	}
+
+	if !extended {
+		return true
+	}
+
+	switch obj := obj.(type) {
+	case *object.Integer:
+		return obj.Value != 0
+	case *object.Float:
+		return obj.Value != 0
+	case *object.Duration:
+		return obj.Value != 0
+	case object.Sized:
+		return obj.Len() != 0
 	default:
 		return true
 	}
@@ -292,19 +855,135 @@ func isError(obj object.Object) bool {
 	return false
 }
 
-func applyFunction(fn object.Object, args []object.Object) object.Object {
+// stops reports whether obj, the value of a subexpression, ends the
+// evaluation of the enclosing expression: an error, or a return from a
+// block such as the branch of an if. A return inside an expression, as in
+// let x = if (c) { return 1 } else { 2 }, thus returns from the enclosing
+// function, or ends the program at the top level.
+func stops(obj object.Object) bool {
+	switch obj.(type) {
+	case *object.Error, *object.ReturnValue:
+		return true
+	}
+	return false
+}
+
+func (e *Evaluator) applyFunction(fn object.Object, args []object.Object) object.Object {
+	if e.opts.Trace != nil {
+		return e.traceCall(fn, args)
+	}
+	return e.apply(fn, args)
+}
+
+func (e *Evaluator) apply(fn object.Object, args []object.Object) object.Object {
 	switch fn := fn.(type) {
 	case *object.Function:
-		extendedEnv := extendFunctionEnv(fn, args)
-		evaluated := Eval(fn.Body, extendedEnv)
+		if len(args) != len(fn.Parameters) {
+			return newError("wrong number of arguments. got=%d, want=%d",
+				len(args), len(fn.Parameters))
+		}
+		if e.frames >= e.maxFrames() {
+			return newError("stack overflow: too many nested calls")
+		}
+		e.frames++
+		if e.stats != nil {
+			e.stats.MaxDepth = max(e.stats.MaxDepth, e.frames)
+		}
+		// The frame is left even if a builtin panics, so that a host that
+		// recovers can keep using e.
+		depth := len(e.deferred)
+		e.deferred = append(e.deferred, nil)
+		defer func() {
+			e.frames--
+			e.deferred = e.deferred[:depth]
+		}()
+
+		if e.createsClosures(fn.Body) || e.opts.Record != nil {
+			extendedEnv := extendFunctionEnv(fn, args)
+			evaluated := e.eval(fn.Body, extendedEnv)
+			return unwrapReturnValue(e.runDeferred(evaluated))
+		}
+
+		// Nothing can refer to the environment of the call once it returns,
+		// so it is reused for later calls.
+		env := e.newCallEnv(fn, args)
+		evaluated := e.eval(fn.Body, env)
+		evaluated = e.runDeferred(evaluated)
+		e.releaseCallEnv(env)
 		return unwrapReturnValue(evaluated)
 	case *object.Builtin:
-		return fn.Fn(args...)
+		return e.callBuiltin(fn, args)
 	default:
 		return newError("not a function: %s", fn.Type())
 	}
 }
 
+// A deferredCall is a call whose function and arguments were evaluated by
+// a defer statement.
+type deferredCall struct {
+	fn     object.Object
+	args   []object.Object
+	pos    token.Position
+	callee ast.Expression
+}
+
+func (e *Evaluator) evalDeferStatement(node *ast.DeferStatement, env *object.Environment) object.Object {
+	if len(e.deferred) == 0 {
+		return newError("defer outside function")
+	}
+
+	function := e.eval(node.Call.Function, env)
+	if stops(function) {
+		return function
+	}
+	args := e.evalExpressions(node.Call.Arguments, env)
+	if len(args) == 1 && stops(args[0]) {
+		return args[0]
+	}
+
+	top := len(e.deferred) - 1
+	e.deferred[top] = append(e.deferred[top], deferredCall{
+		fn: function, args: args, pos: node.Call.Pos(), callee: node.Call.Function,
+	})
+	return nil
+}
+
+// runDeferred makes the calls deferred by the innermost function call, last
+// deferred first, and returns the result of that function. An error from
+// a deferred call becomes the result unless the function already failed.
+func (e *Evaluator) runDeferred(result object.Object) object.Object {
+	top := len(e.deferred) - 1
+	calls := e.deferred[top]
+	e.deferred = e.deferred[:top]
+
+	for i := len(calls) - 1; i >= 0; i-- {
+		call := calls[i]
+		e.callPos, e.callee = call.pos, call.callee
+		out := e.applyFunction(call.fn, call.args)
+		if err, ok := out.(*object.Error); ok && !isError(result) {
+			if !err.Pos.IsValid() {
+				err.Pos = call.pos
+			}
+			result = err
+		}
+	}
+	return result
+}
+
+func (e *Evaluator) maxFrames() int {
+	if e.opts.MaxFrames > 0 {
+		return e.opts.MaxFrames
+	}
+	return DefaultMaxFrames
+}
+
+func (e *Evaluator) stackSize() int {
+	if e.opts.StackSize > 0 {
+		return e.opts.StackSize
+	}
+	return DefaultStackSize
+}
+
 func extendFunctionEnv(
 	fn *object.Function,
 	args []object.Object,
@@ -330,14 +1009,20 @@ func evalStringInfixExpression(
 	operator string,
 	left, right object.Object,
 ) object.Object {
-	if operator != "+" {
+	leftVal := left.(*object.String).Value
+	rightVal := right.(*object.String).Value
+
+	switch operator {
+	case "+":
+		return &object.String{Value: leftVal + rightVal}
+	case "==":
+		return nativeBoolToBooleanObject(leftVal == rightVal)
+	case "!=":
+		return nativeBoolToBooleanObject(leftVal != rightVal)
+	default:
 		return newError("unknown operator: %s %s %s",
 			left.Type(), operator, right.Type())
 	}
-
-	leftVal := left.(*object.String).Value
-	rightVal := right.(*object.String).Value
-	return &object.String{Value: leftVal + rightVal}
 }
 
 func evalIndexExpression(left, index object.Object) object.Object {
@@ -351,6 +1036,23 @@ func evalIndexExpression(left, index object.Object) object.Object {
 	}
 }
 
+// evalMemberExpression reads the member name of left: the value under the
+// key name of a hash, or a field or method of a Go value.
+func evalMemberExpression(left object.Object, name string) object.Object {
+	switch left := left.(type) {
+	case *object.Hash:
+		return evalHashIndexExpression(left, &object.String{Value: name})
+	case *object.GoValue:
+		member, err := left.Member(name)
+		if err != nil {
+			return newError("%s", err)
+		}
+		return member
+	default:
+		return newError("member access not supported: %s.%s", left.Type(), name)
+	}
+}
+
 func evalArrayIndexExpression(array, index object.Object) object.Object {
 	arrayObject := array.(*object.Array)
 	idx := index.(*object.Integer).Value
@@ -363,15 +1065,18 @@ func evalArrayIndexExpression(array, index object.Object) object.Object {
 	return arrayObject.Elements[idx]
 }
 
-func evalHashLiteral(
+func (e *Evaluator) evalHashLiteral(
 	node *ast.HashLiteral,
 	env *object.Environment,
 ) object.Object {
 	pairs := make(map[object.HashKey]object.HashPair)
 
-	for keyNode, valueNode := range node.Pairs {
-		key := Eval(keyNode, env)
-		if isError(key) {
+	// Pairs are evaluated in source order, so when keys computed at run
+	// time collide the last pair wins.
+	for _, keyNode := range node.Keys() {
+		valueNode := node.Pairs[keyNode]
+		key := e.eval(keyNode, env)
+		if stops(key) {
 			return key
 		}
 
@@ -380,8 +1085,8 @@ func evalHashLiteral(
 			return newError("unusable as hash key: %s", key.Type())
 		}
 
-		value := Eval(valueNode, env)
-		if isError(value) {
+		value := e.eval(valueNode, env)
+		if stops(value) {
 			return value
 		}
 
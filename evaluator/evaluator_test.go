@@ -1,7 +1,19 @@
 package evaluator
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/rock619/monkey/lexer"
 	"github.com/rock619/monkey/object"
@@ -17,6 +29,10 @@ func TestEvalIntegerExpression(t *testing.T) {
 		{"10", 10},
 		{"-5", -5},
 		{"-10", -10},
+		{"+5", 5},
+		{"+-5", -5},
+		{"-+5", -5},
+		{"1 - +2", -1},
 		{"5 + 5 + 5 + 5 - 10", 10},
 		{"2 * 2 * 2 * 2 * 2", 32},
 		{"-50 + 100 + -50", 0},
@@ -28,6 +44,10 @@ func TestEvalIntegerExpression(t *testing.T) {
 		{"3 * 3 * 3 + 10", 37},
 		{"3 * (3 * 3) + 10", 37},
 		{"(5 + 10 * 2 + 15 / 3) * 2 + -10", 50},
+		{"17 % 5", 2},
+		{"-17 % 5", -2},
+		{"17 % -5", 2},
+		{"2 + 10 % 4 * 3", 8},
 	}
 
 	for _, tt := range tests {
@@ -45,6 +65,15 @@ func testEval(input string) object.Object {
 	return Eval(program, env)
 }
 
+func testEvalWithOptions(input string, opts Options) object.Object {
+	l := lexer.New(input)
+	p := parser.New(l)
+	program := p.ParseProgram()
+	env := object.NewEnvironment()
+
+	return New(opts).Eval(context.Background(), program, env)
+}
+
 func testIntegerObject(t *testing.T, obj object.Object, expected int64) bool {
 	result, ok := obj.(*object.Integer)
 	if !ok {
@@ -60,6 +89,61 @@ func testIntegerObject(t *testing.T, obj object.Object, expected int64) bool {
 	return true
 }
 
+func TestEvalFloatExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"2.5", 2.5},
+		{"-0.5", -0.5},
+		{"+1.25", 1.25},
+		{"1.5 + 2.25", 3.75},
+		{"1 + 0.5", 1.5},
+		{"0.5 * 4", 2.0},
+		{"7 / 2.0", 3.5},
+		{"7 / 2", 3},
+		{"1.0 / 0", math.Inf(1)},
+		{"7.5 % 2", 1.5},
+		{"2.5 > 2", true},
+		{"2 < 1.5", false},
+		{"1 == 1.0", true},
+		{"1.5 != 1.5", false},
+		{"1.5 == \"1.5\"", false},
+		{"1.5 - true", errorMessage("type mismatch: FLOAT - BOOLEAN")},
+		{"-\"a\"", errorMessage("unary - requires a number or DURATION, got STRING")},
+		{"+true", errorMessage("unary + requires a number, got BOOLEAN")},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case float64:
+			result, ok := evaluated.(*object.Float)
+			if !ok {
+				t.Errorf("%s: object is not Float. got=%T (%+v)", tt.input, evaluated, evaluated)
+			} else if result.Value != expected {
+				t.Errorf("%s: object has wrong value. got=%v, want=%v", tt.input, result.Value, expected)
+			}
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case errorMessage:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("%s: no error object returned. got=%T (%+v)", tt.input, evaluated, evaluated)
+			} else if errObj.Message != string(expected) {
+				t.Errorf("%s: wrong error message. got=%q, want=%q", tt.input, errObj.Message, expected)
+			}
+		}
+	}
+
+	// No syntax reaches an unknown operator, but its error names the
+	// operands as they were.
+	evaluated := New(Options{}).evalInfixExpression("&", &object.Integer{Value: 1}, &object.Float{Value: 2})
+	testBuiltinResult(t, "1 & 2.0", evaluated, errorMessage("unknown operator: INTEGER & FLOAT"))
+}
+
 func TestEvalBooleanExpression(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -125,6 +209,80 @@ func TestBangOperator(t *testing.T) {
 	}
 }
 
+func TestExtendedTruthiness(t *testing.T) {
+	tests := []struct {
+		input    string
+		book     bool
+		extended bool
+	}{
+		{"!!0", true, false},
+		{"!!1", true, true},
+		{`!!""`, true, false},
+		{`!!"a"`, true, true},
+		{"!![]", true, false},
+		{"!![0]", true, true},
+		{"!!{}", true, false},
+		{`!!{"a": 1}`, true, true},
+		{"!!fn() {}", true, true},
+		{"if (0) { true } else { false }", true, false},
+		{`if ("") { true } else { false }`, true, false},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEvalWithOptions(tt.input, Options{})
+		testBooleanObject(t, evaluated, tt.book)
+
+		evaluated = testEvalWithOptions(tt.input, Options{ExtendedTruthiness: true})
+		testBooleanObject(t, evaluated, tt.extended)
+	}
+}
+
+func TestCheckedArithmetic(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"9223372036854775807 - 1 + 1", 9223372036854775807},
+		{"9223372036854775807 + 1", "integer overflow: 9223372036854775807 + 1"},
+		{"-9223372036854775807 - 1", -9223372036854775808},
+		{"-9223372036854775807 - 2", "integer overflow: -9223372036854775807 - 2"},
+		{"1 - -9223372036854775807", "integer overflow: 1 - -9223372036854775807"},
+		{"3037000499 * 3037000499", 9223372030926249001},
+		{"3037000500 * 3037000500", "integer overflow: 3037000500 * 3037000500"},
+		{"-1 * (-9223372036854775807 - 1)", "integer overflow: -1 * -9223372036854775808"},
+		{"(-9223372036854775807 - 1) * -1", "integer overflow: -9223372036854775808 * -1"},
+		{"(-9223372036854775807 - 1) / -1", "integer overflow: -9223372036854775808 / -1"},
+		{"-(-9223372036854775807 - 1)", "integer overflow: -(-9223372036854775808)"},
+		{"0 * -9223372036854775807", 0},
+		{"1 / 0", "division by zero: 1 / 0"},
+		{"(-9223372036854775807 - 1) / 0", "division by zero: -9223372036854775808 / 0"},
+		{"(-9223372036854775807 - 1) % -1", 0},
+		{"1 % 0", "modulo by zero: 1 % 0"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEvalWithOptions(tt.input, Options{CheckedArithmetic: true})
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("object is not Error. got=%T (%+v)", evaluated, evaluated)
+				continue
+			}
+			if errObj.Message != expected {
+				t.Errorf("wrong error message. expected=%q, got=%q",
+					expected, errObj.Message)
+			}
+		}
+	}
+
+	// Without the option arithmetic wraps around, as in Go.
+	testIntegerObject(t, testEval("9223372036854775807 + 1"), math.MinInt64)
+}
+
 func TestIfElseExpressions(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -202,7 +360,11 @@ func TestErrorHandling(t *testing.T) {
 		},
 		{
 			"-true",
-			"unknown operator: -BOOLEAN",
+			"unary - requires a number or DURATION, got BOOLEAN",
+		},
+		{
+			`+"abc"`,
+			"unary + requires a number, got STRING",
 		},
 		{
 			"true + false;",
@@ -232,6 +394,18 @@ if (10 > 1) {
 			"foobar",
 			"identifier not found: foobar",
 		},
+		{
+			"10 / (5 - 5)",
+			"division by zero: 10 / 0",
+		},
+		{
+			"let counter = 1; countr",
+			"identifier not found: countr (did you mean counter?)",
+		},
+		{
+			`frist([1, 2])`,
+			"identifier not found: frist (did you mean first?)",
+		},
 		{
 			`"Hello" - "World"`,
 			"unknown operator: STRING - STRING",
@@ -240,6 +414,18 @@ if (10 > 1) {
 			`{"name": "Monkey"}[fn(x) { x }];`,
 			"unusable as hash key: FUNCTION",
 		},
+		{
+			`let f = fn(x) { x }; f();`,
+			"wrong number of arguments. got=0, want=1",
+		},
+		{
+			`let f = fn(x) { x }; f(1, 2);`,
+			"wrong number of arguments. got=2, want=1",
+		},
+		{
+			`let f = fn(x, y) { fn() { x + y } }; f(1);`,
+			"wrong number of arguments. got=1, want=2",
+		},
 	}
 
 	for _, tt := range tests {
@@ -358,6 +544,25 @@ func TestStringConcatenation(t *testing.T) {
 	}
 }
 
+func TestStringComparison(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{`"Monkey" == "Monkey"`, true},
+		{`"Monkey" == "monkey"`, false},
+		{`"Monkey" != "Monkey"`, false},
+		{`"Monkey" != "monkey"`, true},
+		{`let a = "Mon"; a + "key" == "Monkey"`, true},
+		{`{"name": "Monkey"}["name"] == "Monkey"`, true},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testBooleanObject(t, evaluated, tt.expected)
+	}
+}
+
 func TestBuiltinFunctions(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -366,7 +571,11 @@ func TestBuiltinFunctions(t *testing.T) {
 		{`len("")`, 0},
 		{`len("four")`, 4},
 		{`len("hello world")`, 11},
+		{`len([1, 2, 3])`, 3},
+		{`len({})`, 0},
+		{`len({"a": 1, "b": 2})`, 2},
 		{`len(1)`, "argument to `len` not supported, got INTEGER"},
+		{`len(fn() {})`, "argument to `len` not supported, got FUNCTION"},
 		{`len("one", "two")`, "wrong number of arguments. got=2, want=1"},
 	}
 
@@ -508,6 +717,26 @@ func TestHashLiterals(t *testing.T) {
 	}
 }
 
+func TestHashLiteralsLastKeyWins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{`let a = "k"; let b = "k"; {a: 1, b: 2}["k"]`, 2},
+		{`let a = "k"; let b = "k"; {b: 2, a: 1}["k"]`, 1},
+		{`let k = 1; {k: 1, 0 + 1: 2, 2 - 1: 3}[1]`, 3},
+		{`{5: 1, 5.0: 2}[5]`, 2},
+		{`len({5: 1, 5.0: 2, 5.5: 3})`, 2},
+	}
+
+	for _, tt := range tests {
+		for i := 0; i < 10; i++ {
+			evaluated := testEval(tt.input)
+			testIntegerObject(t, evaluated, tt.expected)
+		}
+	}
+}
+
 func TestHashIndexExpressions(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -541,6 +770,26 @@ func TestHashIndexExpressions(t *testing.T) {
 			`{false: 5}[false]`,
 			5,
 		},
+		{
+			`{5.0: 5}[5]`,
+			5,
+		},
+		{
+			`{5: 5}[5.0]`,
+			5,
+		},
+		{
+			`{2.5: 5}[2.5]`,
+			5,
+		},
+		{
+			`{2.5: 5}[2]`,
+			nil,
+		},
+		{
+			`{-0.0: 5}[0]`,
+			5,
+		},
 	}
 
 	for _, tt := range tests {
@@ -553,3 +802,1798 @@ func TestHashIndexExpressions(t *testing.T) {
 		}
 	}
 }
+
+func TestFirstLastRest(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`first([1, 2, 3])`, 1},
+		{`last([1, 2, 3])`, 3},
+		{`rest([1, 2, 3])`, "[2, 3]"},
+		{`rest([1])`, "[]"},
+		{`first("héllo")`, "h"},
+		{`first("éa")`, "é"},
+		{`last("héllo")`, "o"},
+		{`last("café")`, "é"},
+		{`rest("héllo")`, "éllo"},
+		{`rest("h")`, ""},
+		{`first([])`, nil},
+		{`last("")`, nil},
+		{`rest("")`, nil},
+		{`first(1)`, errorMessage("argument to `first` must be ARRAY or STRING, got INTEGER")},
+		{`rest({})`, errorMessage("argument to `rest` must be ARRAY or STRING, got HASH")},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testBuiltinResult(t, tt.input, evaluated, tt.expected)
+	}
+}
+
+func TestFirstLastRestStrict(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`first([])`, errorMessage("argument to `first` must not be empty, got empty ARRAY")},
+		{`last([])`, errorMessage("argument to `last` must not be empty, got empty ARRAY")},
+		{`rest("")`, errorMessage("argument to `rest` must not be empty, got empty STRING")},
+		{`first("a")`, "a"},
+		{`rest([1])`, "[]"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEvalWithOptions(tt.input, Options{Strict: true})
+		testBuiltinResult(t, tt.input, evaluated, tt.expected)
+	}
+}
+
+// errorMessage is the expected message of an error object, as opposed to
+// the expected Inspect output of any other object.
+type errorMessage string
+
+func testBuiltinResult(t *testing.T, input string, obj object.Object, expected interface{}) {
+	t.Helper()
+
+	switch expected := expected.(type) {
+	case int:
+		testIntegerObject(t, obj, int64(expected))
+	case nil:
+		testNullObject(t, obj)
+	case errorMessage:
+		errObj, ok := obj.(*object.Error)
+		if !ok {
+			t.Errorf("%s: object is not Error. got=%T (%+v)", input, obj, obj)
+			return
+		}
+		if errObj.Message != string(expected) {
+			t.Errorf("%s: wrong error message. expected=%q, got=%q",
+				input, expected, errObj.Message)
+		}
+	case string:
+		if isError(obj) {
+			t.Errorf("%s: unexpected error %s", input, obj.Inspect())
+		} else if obj.Inspect() != expected {
+			t.Errorf("%s: wrong result. expected=%q, got=%q",
+				input, expected, obj.Inspect())
+		}
+	}
+}
+
+func TestPutsReturnsValue(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`let x = puts(1 + 2); x`, 3},
+		{`puts("a", "b")`, "b"},
+		{`puts()`, nil},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEvalWithOptions(tt.input, Options{PutsReturnsValue: true})
+		testBuiltinResult(t, tt.input, evaluated, tt.expected)
+	}
+
+	testNullObject(t, testEval(`puts(1)`))
+}
+
+func TestErrorPositions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"5 + true", "ERROR: type mismatch: INTEGER + BOOLEAN at script.mky:1:3"},
+		{"let s = \"abc\";\n  -s", "ERROR: unary - requires a number or DURATION, got STRING at script.mky:2:3"},
+		{"let f = fn(x) {\n  x * y\n};\nf(1)", "ERROR: identifier not found: y at script.mky:2:7"},
+		{"len(1, 2)", "ERROR: wrong number of arguments. got=2, want=1 at script.mky:1:4"},
+		{"if (true) { return [1][true]; }", "ERROR: index operator not supported: ARRAY at script.mky:1:23"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.NewFile("script.mky", tt.input)
+		p := parser.New(l)
+		program := p.ParseProgram()
+
+		evaluated := Eval(program, object.NewEnvironment())
+		if !isError(evaluated) {
+			t.Errorf("%q: no error object returned. got=%T(%+v)", tt.input, evaluated, evaluated)
+			continue
+		}
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("%q: wrong error. expected=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestImport(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`let list = import("list"); list["map"]([1, 2, 3], fn(x) { x * 2 })`, "[2, 4, 6]"},
+		{`let list = import("list"); list["filter"](list["range"](0, 10), fn(x) { x > 6 })`, "[7, 8, 9]"},
+		{`let list = import("list"); list["reduce"]([1, 2, 3, 4], 0, fn(a, x) { a + x })`, 10},
+		{`let list = import("list"); list["reverse"]([1, 2, 3])`, "[3, 2, 1]"},
+		{`let list = import("list"); list["contains"]([1, 2, 3], 2)`, "true"},
+		{`let list = import("list"); list["contains"](["a", "b"], "a")`, "true"},
+		{`let list = import("list"); list["contains"](["a", "b"], "c")`, "false"},
+		{`let list = import("list"); list["contains"]([1, "a", true], "a")`, "true"},
+		{`let list = import("list"); list["contains"]([1, "a", true], true)`, "true"},
+		{`let list = import("list"); list["contains"]([1, "a", true], "1")`, "false"},
+		{`let list = import("list"); list["sum"](list["range"](1, 5))`, 10},
+		{`let list = import("list"); list["_prepend"]`, nil},
+		{`let s = import("strings"); s["join"](["a", "b", "c"], ", ")`, "a, b, c"},
+		{`let s = import("strings"); s["join"]([], ", ")`, ""},
+		{`let s = import("strings"); s["repeat"]("ab", 3)`, "ababab"},
+		{`let s = import("strings"); s["reverse"]("héllo")`, "olléh"},
+		{`let f = import("func"); f["compose"](fn(x) { x + 1 }, fn(x) { x * 2 })(5)`, 11},
+		{`let f = import("func"); f["flip"](fn(a, b) { a - b })(1, 10)`, 9},
+		{`import("list") == import("list")`, "true"},
+		{`import("nope")`, errorMessage("module not found: nope")},
+		{`import(1)`, errorMessage("argument to `import` must be STRING, got INTEGER")},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testBuiltinResult(t, tt.input, evaluated, tt.expected)
+	}
+}
+
+func TestImportModulePath(t *testing.T) {
+	first, second := t.TempDir(), t.TempDir()
+	files := map[string]string{
+		filepath.Join(first, "greet.mky"):         `let hello = fn(name) { "hello " + name };`,
+		filepath.Join(second, "greet.mky"):        `let hello = fn(name) { "shadowed" };`,
+		filepath.Join(second, "util", "math.mky"): `let double = fn(x) { x * 2 }; let _helper = 1;`,
+		filepath.Join(second, "broken.mky"):       `let x = ;`,
+		filepath.Join(second, "list.mky"):         `let mine = true;`,
+		filepath.Join(first, "assign.mky"):        `let inc = fn(x) { x = x + 1; x };`,
+	}
+	for path, src := range files {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`import("greet")["hello"]("monkey")`, "hello monkey"},
+		{`import("util/math")["double"](21)`, 42},
+		{`import("util/math")["_helper"]`, nil},
+		{`import("list")["mine"]`, nil},
+		{`import("missing")`, errorMessage("module not found: missing")},
+		{`import("../greet")`, errorMessage("invalid module name: ../greet")},
+	}
+
+	opts := Options{ModulePath: []string{first, second}}
+	for _, tt := range tests {
+		evaluated := testEvalWithOptions(tt.input, opts)
+		testBuiltinResult(t, tt.input, evaluated, tt.expected)
+	}
+
+	broken := testEvalWithOptions(`import("broken")`, opts)
+	if err, ok := broken.(*object.Error); !ok || !strings.HasPrefix(err.Message, "module broken: ") {
+		t.Errorf("import of a module with syntax errors returned %s", broken.Inspect())
+	}
+
+	// Modules are parsed in the dialect of the Syntax option, while the
+	// standard library keeps its own.
+	input := `import("assign")["inc"](1) + len(import("list")["map"]([1], fn(x) { x }))`
+	extended, _ := parser.LookupDialect(parser.ExtendedDialect)
+	evaluated := testEvalWithOptions(input, Options{ModulePath: []string{first}, Syntax: extended})
+	testBuiltinResult(t, input, evaluated, 3)
+	evaluated = testEvalWithOptions(input, Options{ModulePath: []string{first}})
+	if err, ok := evaluated.(*object.Error); !ok || !strings.HasPrefix(err.Message, "module assign: ") {
+		t.Errorf("import of a module in another dialect returned %s", evaluated.Inspect())
+	}
+}
+
+func TestPrelude(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`map([1, 2], fn(x) { x + 1 })`, "[2, 3]"},
+		{`reduce(range(0, 4), 0, fn(a, x) { a + x })`, 6},
+		// User code shadows the prelude...
+		{`let map = 5; map`, 5},
+		{`let range = fn(a, b) { "mine" }; range(1, 2)`, "mine"},
+		// ...without changing what the prelude itself uses.
+		{`let reduce = 0; let range = 0; filter([1, 2, 3], fn(x) { x > 1 })`, "[2, 3]"},
+		{`_list`, errorMessage("identifier not found: _list (did you mean last?)")},
+	}
+
+	for _, tt := range tests {
+		e := New(Options{Prelude: true})
+		env := e.NewEnvironment()
+		program := parser.New(lexer.New(tt.input)).ParseProgram()
+
+		evaluated := e.Eval(context.Background(), program, env)
+		testBuiltinResult(t, tt.input, evaluated, tt.expected)
+	}
+
+	// A later environment sees the prelude unchanged.
+	e := New(Options{Prelude: true})
+	program := parser.New(lexer.New(`let map = 1;`)).ParseProgram()
+	e.Eval(context.Background(), program, e.NewEnvironment())
+	if _, ok := e.NewEnvironment().Get("map"); !ok {
+		t.Errorf("prelude map missing from a new environment")
+	}
+
+	evaluated := testEval(`map`)
+	testBuiltinResult(t, "map without prelude", evaluated, errorMessage("identifier not found: map"))
+
+	// Loading the prelude spends none of the budgets of the program.
+	for _, opts := range []Options{{Prelude: true, MaxSteps: 5}, {Prelude: true, MaxAllocBytes: 10}} {
+		e := New(opts)
+		program := parser.New(lexer.New(`let f = map; 1`)).ParseProgram()
+		evaluated := e.Eval(context.Background(), program, e.NewEnvironment())
+		testBuiltinResult(t, fmt.Sprintf("%+v", opts), evaluated, 1)
+	}
+}
+
+func TestMaxFrames(t *testing.T) {
+	input := `let f = fn(n) { if (n == 0) { 0 } else { 1 + f(n - 1) } };`
+
+	evaluated := testEvalWithOptions(input+"f(100)", Options{MaxFrames: 101})
+	testIntegerObject(t, evaluated, 100)
+
+	evaluated = testEvalWithOptions(input+"f(100)", Options{MaxFrames: 100})
+	testBuiltinResult(t, "f(100)", evaluated, errorMessage("stack overflow: too many nested calls"))
+
+	// Unbounded recursion fails cleanly with the default limit.
+	evaluated = testEval(`let loop = fn() { loop() }; loop()`)
+	testBuiltinResult(t, "loop()", evaluated, errorMessage("stack overflow: too many nested calls"))
+
+	// The count is reset once the calls return.
+	evaluated = testEvalWithOptions(input+"f(50); f(50)", Options{MaxFrames: 60})
+	testIntegerObject(t, evaluated, 50)
+}
+
+func TestStackSize(t *testing.T) {
+	recursion := `let f = fn(n) { if (n == 0) { 0 } else { 1 + f(n - 1) } }; f(1000)`
+	nested := strings.Repeat("-(", 200) + "1" + strings.Repeat(")", 200)
+
+	tests := []struct {
+		input    string
+		opts     Options
+		expected interface{}
+	}{
+		{recursion, Options{}, 1000},
+		{nested, Options{}, 1},
+		// A call takes several nested evaluations, so the stack runs out
+		// before MaxFrames is reached.
+		{recursion, Options{MaxFrames: 1000000, StackSize: 1000},
+			errorMessage("stack overflow: evaluation nested too deeply")},
+		{nested, Options{StackSize: 100}, errorMessage("stack overflow: evaluation nested too deeply")},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEvalWithOptions(tt.input, tt.opts)
+		testBuiltinResult(t, tt.input[:20], evaluated, tt.expected)
+	}
+}
+
+func TestCreatesClosuresCacheBounded(t *testing.T) {
+	e := New(Options{})
+	env := e.NewEnvironment()
+
+	// Every input of a REPL brings new function bodies.
+	for i := 0; i < maxEscapes+10; i++ {
+		program := parser.New(lexer.New(`let f = fn() { 1 }; f()`)).ParseProgram()
+		e.Eval(context.Background(), program, env)
+	}
+	if len(e.escapes) > maxEscapes {
+		t.Errorf("escapes cache holds %d bodies, want at most %d", len(e.escapes), maxEscapes)
+	}
+}
+
+func TestAssignment(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let x = 1; x = 2; x", 2},
+		{"let x = 1; let y = 1; x = y = 5; x + y", 10},
+		{"let x = 1; (x = 7) + 1", 8},
+		// Closures capture variables by reference: assignments made by the
+		// closure outlive the call...
+		{`let counter = fn() { let n = 0; fn() { n = n + 1 } };
+		  let c = counter(); c(); c(); c()`, 3},
+		// ...every counter has its own variable...
+		{`let counter = fn() { let n = 0; fn() { n = n + 1 } };
+		  let a = counter(); let b = counter(); a(); a(); b(); a() * 10 + b()`, 32},
+		// ...closures sharing a variable see each other's assignments...
+		{`let pair = fn() { let n = 0; [fn() { n = n + 1 }, fn() { n }] };
+		  let p = pair(); p[0](); p[0](); p[1]()`, 2},
+		// ...and a closure sees assignments made after it was created.
+		{"let x = 1; let f = fn() { x }; x = 2; f()", 2},
+		// let declares a new variable that shadows the outer one.
+		{"let x = 1; let f = fn() { let x = 5; x = 6; x }; f() * 10 + x", 61},
+		{"y = 1", errorMessage("cannot assign to y: undeclared identifier")},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testBuiltinResult(t, tt.input, evaluated, tt.expected)
+	}
+
+	e := New(Options{Prelude: true})
+	program := parser.New(lexer.New("map = 1")).ParseProgram()
+	evaluated := e.Eval(context.Background(), program, e.NewEnvironment())
+	testBuiltinResult(t, "map = 1", evaluated, errorMessage("cannot assign to map: read-only identifier"))
+}
+
+// TestClosureSemantics pins down how closures share variables: a closure
+// captures the environment it was created in, not the values in it, so
+// every closure created in the same call sees the assignments of the others.
+func TestClosureSemantics(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		// Counters.
+		{`let make = fn() { let n = 0; {"inc": fn() { n = n + 1 }, "get": fn() { n }} };
+		  let c = make(); c.inc(); c.inc(); c.get()`, 2},
+		{`let make = fn() { let n = 0; {"inc": fn() { n = n + 1 }, "reset": fn() { n = 0 }, "get": fn() { n }} };
+		  let c = make(); c.inc(); c.reset(); c.inc(); c.get()`, 1},
+		{`let make = fn(start) { fn() { start = start + 1 } };
+		  let c = make(10); c(); c()`, 12},
+		// Parameters are variables of the call like any other.
+		{`let f = fn(x) { let g = fn() { x = x * 2 }; g(); g(); x }; f(3)`, 12},
+		// Two closures over a top-level variable and one created later.
+		{`let n = 0; let inc = fn() { n = n + 1 }; let get = fn() { n };
+		  inc(); let later = fn() { n * 10 }; inc(); get() + later()`, 22},
+		// Closures created by repeated calls, the way a loop would create
+		// them, each capture the variables of their own call...
+		{`let list = import("list");
+		  let fs = list.map([1, 2, 3], fn(i) { fn() { i } });
+		  fs[0]() * 100 + fs[1]() * 10 + fs[2]()`, 123},
+		{`let list = import("list");
+		  let counters = list.map([1, 2], fn(i) { let n = i * 10; fn() { n = n + 1 } });
+		  counters[0](); counters[0](); counters[1]() * 100 + counters[0]()`, 2113},
+		// ...but share the variables of the calls enclosing them.
+		{`let list = import("list");
+		  let total = 0;
+		  list.map([1, 2, 3], fn(i) { total = total + i });
+		  total`, 6},
+		// Shadowing: a let in a function declares a new variable, and the
+		// closures created inside it see that one.
+		{`let x = 1; let f = fn() { let x = 10; fn() { x = x + 1 } }; let g = f(); g(); g(); x`, 1},
+		{`let x = 1; let f = fn() { let x = 10; fn() { x = x + 1 } }; let g = f(); g(); g()`, 12},
+		{`let x = 1; let f = fn(x) { fn() { x = x + 1 } }; f(5)() * 10 + x`, 61},
+		// A second let in the same function rebinds the same variable.
+		{`let f = fn() { let x = 1; let g = fn() { x }; let x = 2; g() }; f()`, 2},
+		// Blocks don't open scopes: a let in an if declares a variable of
+		// the enclosing function.
+		{`let x = 1; if (true) { let x = 2; }; x`, 2},
+		{`let f = fn() { if (true) { let y = 3; }; y }; f()`, 3},
+		// Recursive closures see their own name through the environment.
+		{`let make = fn() { let fact = fn(n) { if (n < 2) { 1 } else { n * fact(n - 1) } }; fact };
+		  make()(5)`, 120},
+		// Assignment never declares, not even inside a closure.
+		{`let f = fn() { y = 1 }; f()`, errorMessage("cannot assign to y: undeclared identifier")},
+		{`let f = fn() { let y = 1; fn() { y } }; f(); y`, errorMessage("identifier not found: y")},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testBuiltinResult(t, tt.input, evaluated, tt.expected)
+	}
+}
+
+func TestBoolBuiltin(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"bool(true)", true},
+		{"bool(false)", false},
+		{"bool(if (false) { 1 })", false},
+		{"bool(0)", false},
+		{"bool(-1)", true},
+		{`bool("")`, false},
+		{`bool("0")`, true},
+		{"bool([])", false},
+		{"bool([[]])", true},
+		{"bool({})", false},
+		{"bool(len)", true},
+	}
+
+	for _, tt := range tests {
+		testBooleanObject(t, testEval(tt.input), tt.expected)
+	}
+
+	evaluated := testEval("bool(1, 2)")
+	errObj, ok := evaluated.(*object.Error)
+	if !ok {
+		t.Fatalf("object is not Error. got=%T (%+v)", evaluated, evaluated)
+	}
+	if errObj.Message != "wrong number of arguments. got=2, want=1" {
+		t.Errorf("wrong error message. got=%q", errObj.Message)
+	}
+}
+
+func TestEvalInterrupted(t *testing.T) {
+	input := `
+let fib = fn(n) { if (n < 2) { n } else { fib(n - 1) + fib(n - 2) } };
+fib(50);`
+
+	l := lexer.New(input)
+	p := parser.New(l)
+	program := p.ParseProgram()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	evaluated := New(Options{}).Eval(ctx, program, object.NewEnvironment())
+
+	errObj, ok := evaluated.(*object.Error)
+	if !ok {
+		t.Fatalf("object is not Error. got=%T (%+v)", evaluated, evaluated)
+	}
+
+	expected := "evaluation interrupted: context deadline exceeded"
+	if errObj.Message != expected {
+		t.Errorf("wrong error message. expected=%q, got=%q",
+			expected, errObj.Message)
+	}
+}
+
+func TestCall(t *testing.T) {
+	e := New(Options{})
+	env := object.NewEnvironment()
+	e.Eval(context.Background(), parser.New(lexer.New("let add = fn(a, b) { a + b };")).ParseProgram(), env)
+	add, _ := env.Get("add")
+
+	testIntegerObject(t, e.Call(context.Background(), add, newInteger(2), newInteger(3)), 5)
+	testIntegerObject(t, e.Call(context.Background(), e.builtins["len"], &object.String{Value: "abc"}), 3)
+
+	tests := []struct {
+		fn       object.Object
+		args     []object.Object
+		expected string
+	}{
+		{add, []object.Object{newInteger(1)}, "wrong number of arguments. got=1, want=2"},
+		{newInteger(1), nil, "not a function: INTEGER"},
+	}
+	for _, tt := range tests {
+		errObj, ok := e.Call(context.Background(), tt.fn, tt.args...).(*object.Error)
+		if !ok {
+			t.Errorf("expected an error for %s", tt.fn.Inspect())
+			continue
+		}
+		if errObj.Message != tt.expected {
+			t.Errorf("wrong error message. expected=%q, got=%q", tt.expected, errObj.Message)
+		}
+	}
+}
+
+type testUser struct {
+	Name    string
+	Age     int
+	Tags    []string
+	Friend  *testUser
+	private string
+}
+
+func (u *testUser) Greet(greeting string) string { return greeting + ", " + u.Name }
+func (u *testUser) Birthday()                    { u.Age++ }
+func (u *testUser) Rename(name string) error {
+	if name == "" {
+		return fmt.Errorf("name must not be empty")
+	}
+	u.Name = name
+	return nil
+}
+
+func TestMemberExpressions(t *testing.T) {
+	user := &testUser{Name: "Ann", Age: 30, Tags: []string{"a", "b"}, Friend: &testUser{Name: "Bob"}, private: "x"}
+	allow := []string{"Name", "Age", "Tags", "Friend", "Greet", "Birthday", "Rename", "private"}
+
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`let list = import("list"); list.sum(list.range(1, 5))`, 10},
+		{`{"a": {"b": 2}}.a.b`, 2},
+		{`{"a": 1}.missing`, nil},
+		{`user.Name`, "Ann"},
+		{`user.Age + 1`, 31},
+		{`len(user.Tags)`, 2},
+		{`user.Friend.Name`, "Bob"},
+		{`user.Greet("Hello")`, "Hello, Ann"},
+		{`user.Birthday(); user.Age`, 31},
+		{`user.Rename("Cat"); user.Name`, "Cat"},
+		{`user.Rename("")`, errorMessage("name must not be empty")},
+		{`user.Greet(1)`, errorMessage("argument 1 to Greet: cannot use INTEGER as string")},
+		{`user.Greet()`, errorMessage("wrong number of arguments to Greet. got=0, want=1")},
+		{`user.Email`, errorMessage("*evaluator.testUser has no member Email")},
+		{`user.private`, errorMessage("*evaluator.testUser has no member private")},
+		{`1.a`, errorMessage("member access not supported: INTEGER.a")},
+	}
+
+	for _, tt := range tests {
+		u := *user
+		env := object.NewEnvironment()
+		env.Set("user", &object.GoValue{Value: &u, Allow: allow})
+
+		p := parser.New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		if len(p.Errors()) != 0 {
+			t.Fatalf("%q: parser errors: %v", tt.input, p.Errors())
+		}
+		evaluated := New(Options{}).Eval(context.Background(), program, env)
+		testBuiltinResult(t, tt.input, evaluated, tt.expected)
+	}
+}
+
+func TestBind(t *testing.T) {
+	e := New(Options{})
+	env := object.NewEnvironment()
+	input := `
+let greet = fn(name, n) { if (n > 1) { name + "!" } else { name } };
+let fail = fn(x) { x + "a" };
+let loop = fn(n) { loop(n + 1) };
+let crashInside = fn() { defer len(""); boom() };
+`
+	env.Set("boom", &object.Builtin{Fn: func(...object.Object) object.Object { panic("boom") }})
+	e.Eval(context.Background(), parser.New(lexer.New(input)).ParseProgram(), env)
+	get := func(name string) object.Object {
+		fn, _ := env.Get(name)
+		return fn
+	}
+
+	var greet func(string, int) (string, error)
+	if err := e.Bind(&greet, get("greet")); err != nil {
+		t.Fatalf("Bind: %s", err)
+	}
+	if got, err := greet("hi", 2); got != "hi!" || err != nil {
+		t.Errorf("greet = %q, %v", got, err)
+	}
+
+	var fail func(int) error
+	if err := e.Bind(&fail, get("fail")); err != nil {
+		t.Fatalf("Bind: %s", err)
+	}
+	if err := fail(1); err == nil || err.Error() != "3:22: type mismatch: INTEGER + STRING" {
+		t.Errorf("wrong error: %v", err)
+	}
+
+	var count func(string) (int64, error)
+	e.Bind(&count, e.builtins["len"])
+	if n, err := count("four"); n != 4 || err != nil {
+		t.Errorf("count = %d, %v", n, err)
+	}
+
+	var wrongResult func(string) ([]int, error)
+	e.Bind(&wrongResult, e.builtins["len"])
+	if _, err := wrongResult("x"); err == nil || err.Error() != "cannot use INTEGER as []int" {
+		t.Errorf("wrong error: %v", err)
+	}
+
+	var crash func() error
+	e.Bind(&crash, &object.Builtin{Fn: func(...object.Object) object.Object { panic("boom") }})
+	if err := crash(); err == nil || err.Error() != "panic during evaluation: boom" {
+		t.Errorf("wrong error: %v", err)
+	}
+
+	// A panic inside a function leaves its frame.
+	e.Bind(&crash, get("crashInside"))
+	if err := crash(); err == nil || err.Error() != "panic during evaluation: boom" {
+		t.Errorf("wrong error: %v", err)
+	}
+	if e.frames != 0 || len(e.deferred) != 0 {
+		t.Errorf("frames left after a panic: %d, %d deferred", e.frames, len(e.deferred))
+	}
+
+	var loop func(context.Context, int) error
+	e.Bind(&loop, get("loop"))
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := loop(ctx, 0); err == nil || err.Error() != "4:18: evaluation interrupted: context canceled" {
+		t.Errorf("wrong error: %v", err)
+	}
+
+	bindErrors := []struct {
+		target   any
+		fn       object.Object
+		expected string
+	}{
+		{greet, get("greet"), "bind: target must be a non-nil pointer to a function, got func(string, int) (string, error)"},
+		{new(func(string) string), get("fail"), "bind: func(string) string must return error or a value and an error"},
+		{new(func(...int) error), get("fail"), "bind: func(...int) error is variadic"},
+		{new(func(int, int) error), get("fail"), "bind: function takes 1 arguments, func(int, int) error takes 2"},
+		{new(func() error), newInteger(1), "bind: not a function: INTEGER"},
+	}
+	for _, tt := range bindErrors {
+		if err := e.Bind(tt.target, tt.fn); err == nil || err.Error() != tt.expected {
+			t.Errorf("wrong error. got=%v, want=%q", err, tt.expected)
+		}
+	}
+}
+
+func TestIOStreams(t *testing.T) {
+	var out, errOut strings.Builder
+	e := New(Options{IO: IOStreams{
+		In:  strings.NewReader("first\r\nsecond\nlast"),
+		Out: &out,
+		Err: &errOut,
+	}})
+
+	input := `
+let a = readLine();
+let b = readLine();
+puts(a, [b]);
+print("x", 1, true);
+print("y");
+log("warning:", readLine());
+readLine();
+`
+	program := parser.New(lexer.New(input)).ParseProgram()
+	evaluated := e.Eval(context.Background(), program, object.NewEnvironment())
+
+	testNullObject(t, evaluated)
+	if expected := "first\n[\"second\"]\nx 1 truey"; out.String() != expected {
+		t.Errorf("wrong output. expected=%q, got=%q", expected, out.String())
+	}
+	if expected := "warning: last\n"; errOut.String() != expected {
+		t.Errorf("wrong error output. expected=%q, got=%q", expected, errOut.String())
+	}
+}
+
+// writeLog records every call of Write.
+type writeLog []string
+
+func (w *writeLog) Write(p []byte) (int, error) {
+	*w = append(*w, string(p))
+	return len(p), nil
+}
+
+func TestBufferOutput(t *testing.T) {
+	tests := []struct {
+		input    string
+		opts     Options
+		expected []string
+	}{
+		{`print("a"); print("b"); puts("c", "d"); print("e")`, Options{},
+			[]string{"abc\n", "d\n", "e"}},
+		{`print("a"); log("b"); print("c")`, Options{},
+			[]string{"a", "b\n", "c"}},
+		{`print("name? "); readLine()`, Options{},
+			[]string{"name? "}},
+		{`print("a"); print("b"); print("c")`, Options{MaxSteps: 2},
+			[]string{"ab"}},
+		{`let f = fn() { print("a"); f() }; f()`, Options{MaxFrames: 3},
+			[]string{"aaa"}},
+	}
+
+	for _, tt := range tests {
+		var w writeLog
+		tt.opts.BufferOutput = true
+		tt.opts.IO = IOStreams{In: strings.NewReader(""), Out: &w, Err: &w}
+		e := New(tt.opts)
+
+		program := parser.New(lexer.New(tt.input)).ParseProgram()
+		e.Eval(context.Background(), program, e.NewEnvironment())
+
+		if !slices.Equal(w, tt.expected) {
+			t.Errorf("%s: wrong writes. got=%q, want=%q", tt.input, w, tt.expected)
+		}
+	}
+}
+
+func TestDefer(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`let f = fn() { defer print(1); defer print(2); print(3); 4 }; f()`, 4},
+		{`let f = fn() { defer print("a"); return "b"; print("c") }; f()`, "b"},
+		{`let f = fn(x) { defer print(x); x = 10; x }; f(1)`, 10},
+		{`let f = fn() { defer print("cleanup"); 1 + true }; f()`, errorMessage("type mismatch: INTEGER + BOOLEAN")},
+		{`let f = fn() { defer len(1); 5 }; f()`, errorMessage("argument to `len` not supported, got INTEGER")},
+		{`let f = fn() { defer missing(); 5 }; f()`, errorMessage("identifier not found: missing")},
+		{`let g = fn() { defer print("g"); 1 }; let f = fn() { defer g(); defer print("f"); 2 }; f()`, 2},
+		{`defer print(1);`, errorMessage("defer outside function")},
+	}
+	outputs := []string{"321", "a", "1", "cleanup", "", "", "fg", ""}
+
+	for i, tt := range tests {
+		var out strings.Builder
+		e := New(Options{IO: IOStreams{Out: &out}})
+		program := parser.New(lexer.New(tt.input)).ParseProgram()
+		evaluated := e.Eval(context.Background(), program, object.NewEnvironment())
+
+		testBuiltinResult(t, tt.input, evaluated, tt.expected)
+		if out.String() != outputs[i] {
+			t.Errorf("%q: wrong output. expected=%q, got=%q", tt.input, outputs[i], out.String())
+		}
+	}
+}
+
+func TestPostfixChains(t *testing.T) {
+	setup := `
+let api = {
+	"users": fn(n) { [{"name": fn(greeting) { {"k": greeting + "!"} }}, n] },
+	"list": import("list"),
+};
+`
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`api.users(1)[0].name("hi")["k"]`, "hi!"},
+		{`api["users"](2)[1] * api.list.sum([1, 2])`, 6},
+		{`api.list.map([1, 2], fn(x) { [x, x * 10] })[1][1]`, 20},
+		{`-api.users(7)[1]`, -7},
+		{`let f = fn() { fn() { {"v": [5]} } }; f()().v[0]`, 5},
+		{`api.users(1)[0].nope`, nil},
+		{`api.users(1)[5].name`, errorMessage("member access not supported: NULL.name")},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(setup + tt.input)
+		testBuiltinResult(t, tt.input, evaluated, tt.expected)
+	}
+}
+
+func TestToString(t *testing.T) {
+	setup := `
+let point = fn(x, y) {
+	{"x": x, "y": y, "toString": fn(self) { "(" + str(self["x"]) + ", " + str(self["y"]) + ")" }}
+};
+let str = fn(n) { if (n == 0) { "0" } else { if (n == 1) { "1" } else { "2" } } };
+let p = point(1, 2);
+`
+	tests := []struct {
+		input    string
+		expected interface{}
+		output   string
+	}{
+		{`puts(p); print(p, "x"); "at " + p`, "at (1, 2)", "(1, 2)\n(1, 2) x"},
+		{`p + "!"`, "(1, 2)!", ""},
+		{`p + 1`, errorMessage("type mismatch: HASH + INTEGER"), ""},
+		{`puts({"a": 1})`, nil, "{\"a\": 1}\n"},
+		{`puts({"toString": fn() { "no self" }})`, nil, "{\"toString\": fn() {\nno self\n}}\n"},
+		{`puts({"toString": fn(self) { 1 }})`, errorMessage("toString must return STRING, got INTEGER"), ""},
+		{`"" + {"toString": fn(self) { self["nope"] + 1 }}`, errorMessage("type mismatch: NULL + INTEGER"), ""},
+		{`puts([p, "a", [p]]); print({"at": p})`, nil, "[(1, 2), \"a\", [(1, 2)]]\n{\"at\": (1, 2)}"},
+		{`puts([1, {"toString": fn(self) { 1 }}])`, errorMessage("toString must return STRING, got INTEGER"), ""},
+	}
+
+	for _, tt := range tests {
+		var out strings.Builder
+		e := New(Options{IO: IOStreams{Out: &out}})
+		program := parser.New(lexer.New(setup + tt.input)).ParseProgram()
+		evaluated := e.Eval(context.Background(), program, object.NewEnvironment())
+
+		testBuiltinResult(t, tt.input, evaluated, tt.expected)
+		if out.String() != tt.output {
+			t.Errorf("%q: wrong output. expected=%q, got=%q", tt.input, tt.output, out.String())
+		}
+	}
+}
+
+func TestFormatNumber(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`formatNumber(1234567)`, "1234567"},
+		{`formatNumber(1234567, {"thousands": ","})`, "1,234,567"},
+		{`formatNumber(-1234, {"thousands": ",", "decimals": 2})`, "-1,234.00"},
+		{`formatNumber(123, {"thousands": ","})`, "123"},
+		{`formatNumber(123456, {"thousands": ","})`, "123,456"},
+		{`formatNumber(1234567, {"thousands": ".", "point": ",", "decimals": 1})`, "1.234.567,0"},
+		{`formatNumber(-9223372036854775807 - 1, {"thousands": " "})`, "-9 223 372 036 854 775 808"},
+		{`formatNumber(1234.5678, {"thousands": ",", "decimals": 2})`, "1,234.57"},
+		{`formatNumber(-1234567.5, {"thousands": ".", "point": ","})`, "-1.234.568"},
+		{`formatNumber(0.5, {"decimals": 3})`, "0.500"},
+		{`formatNumber(1.0 / 0, {"decimals": 2})`, "+Inf"},
+		{`formatNumber("1")`, errorMessage("argument to `formatNumber` must be a number, got STRING")},
+		{`formatNumber(1, 2)`, errorMessage("options to `formatNumber` must be HASH, got INTEGER")},
+		{`formatNumber(1, {"decimals": -1})`, errorMessage("option decimals must be an INTEGER from 0 to 20, got -1")},
+		{`formatNumber(1, {"thousands": 1})`, errorMessage("option thousands must be STRING, got INTEGER")},
+		{`formatNumber(1, {"digits": 1})`, errorMessage("unknown option to `formatNumber`: \"digits\"")},
+		{`formatNumber()`, errorMessage("wrong number of arguments. got=0, want=1 or 2")},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testBuiltinResult(t, tt.input, evaluated, tt.expected)
+	}
+}
+
+func TestEncodingBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`encodeBase64("héllo")`, "aMOpbGxv"},
+		{`decodeBase64("aMOpbGxv")`, "héllo"},
+		{`decodeBase64(encodeBase64(""))`, ""},
+		{`decodeBase64("a")`, errorMessage("decodeBase64: illegal base64 data at input byte 0")},
+		{`encodeHex("Hi!")`, "486921"},
+		{`decodeHex("486921")`, "Hi!"},
+		{`decodeHex("4")`, errorMessage("decodeHex: encoding/hex: odd length hex string")},
+		{`urlEncode("a b&c=d/é")`, "a+b%26c%3Dd%2F%C3%A9"},
+		{`urlDecode("a+b%26c")`, "a b&c"},
+		{`urlDecode("%zz")`, errorMessage("urlDecode: invalid URL escape \"%zz\"")},
+		{`encodeHex(1)`, errorMessage("argument to `encodeHex` must be STRING, got INTEGER")},
+		{`urlEncode()`, errorMessage("wrong number of arguments. got=0, want=1")},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testBuiltinResult(t, tt.input, evaluated, tt.expected)
+	}
+}
+
+func TestHashBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`sha256("")`, "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"},
+		{`sha256("abc")`, "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"},
+		{`md5("abc")`, "900150983cd24fb0d6963f7d28e17f72"},
+		{`hmacSha256("key", "The quick brown fox jumps over the lazy dog")`,
+			"f7bc83f430538424b13298e6aa6fb143ef4d59a14946175997479dbc2d1a3cd8"},
+		{`sha256(1)`, errorMessage("argument to `sha256` must be STRING, got INTEGER")},
+		{`hmacSha256("k")`, errorMessage("wrong number of arguments. got=1, want=2")},
+		{`hmacSha256(1, "m")`, errorMessage("first argument to `hmacSha256` must be STRING, got INTEGER")},
+		{`hmacSha256("k", [])`, errorMessage("second argument to `hmacSha256` must be STRING, got ARRAY")},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testBuiltinResult(t, tt.input, evaluated, tt.expected)
+	}
+}
+
+func TestPathBuiltins(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"b.mky", "a.mky", "c.txt"} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`pathJoin("a", "b", "../c.mky")`, filepath.FromSlash("a/c.mky")},
+		{`pathJoin()`, ""},
+		{`dirname(pathJoin("a", "b", "c.mky"))`, filepath.FromSlash("a/b")},
+		{`basename(pathJoin("a", "b", "c.mky"))`, "c.mky"},
+		{`ext("script.test.mky")`, ".mky"},
+		{`ext("Makefile")`, ""},
+		{`map(glob(pathJoin(dir, "*.mky")), basename)`, `["a.mky", "b.mky"]`},
+		{`glob(pathJoin(dir, "*.go"))`, "[]"},
+		{`glob("[")`, errorMessage("glob: syntax error in pattern")},
+		{`pathJoin("a", 1)`, errorMessage("arguments to `pathJoin` must be STRING, got INTEGER")},
+	}
+
+	for _, tt := range tests {
+		program := parser.New(lexer.New(tt.input)).ParseProgram()
+		e := New(Options{Prelude: true, Capabilities: CapIO})
+		scope := object.NewEnclosedEnvironment(e.NewEnvironment())
+		scope.Set("dir", &object.String{Value: dir})
+		evaluated := e.Eval(context.Background(), program, scope)
+		testBuiltinResult(t, tt.input, evaluated, tt.expected)
+	}
+
+	denied := testEval(`glob("*")`)
+	testBuiltinResult(t, `glob("*")`, denied, errorMessage("`glob` requires the io capability"))
+
+	// Path functions don't touch the file system, so they work without it.
+	allowed := testEval(`ext(basename(dirname(pathJoin("a", "b.d", "c"))))`)
+	testBuiltinResult(t, "path functions without io", allowed, ".d")
+}
+
+func TestParseCapabilities(t *testing.T) {
+	c, err := ParseCapabilities(" io, ")
+	if err != nil || c != CapIO || c.String() != "io" {
+		t.Errorf("ParseCapabilities = %v, %v", c, err)
+	}
+	if c, err := ParseCapabilities("exec,io"); err != nil || c != CapIO|CapExec || c.String() != "io,exec" {
+		t.Errorf("ParseCapabilities = %v, %v", c, err)
+	}
+	if c, err := ParseCapabilities(""); err != nil || c != 0 {
+		t.Errorf("ParseCapabilities(\"\") = %v, %v", c, err)
+	}
+	if _, err := ParseCapabilities("io,net"); err == nil || err.Error() != `unknown capability "net"` {
+		t.Errorf("wrong error: %v", err)
+	}
+}
+
+func TestExec(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("no sh to run")
+	}
+
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`let r = exec("sh", ["-c", "echo out; echo err >&2; exit 3"]); [r.stdout, r.stderr, r.code]`,
+			`["out\n", "err\n", 3]`},
+		{`exec("echo").code`, 0},
+		{`exec("no-such-program-really").code`,
+			errorMessage(`exec: exec: "no-such-program-really": executable file not found in $PATH`)},
+		{`exec("sh", "-c")`, errorMessage("second argument to `exec` must be ARRAY, got STRING")},
+		{`exec("sh", [1])`, errorMessage("arguments of `exec` must be STRING, got INTEGER")},
+	}
+
+	for _, tt := range tests {
+		program := parser.New(lexer.New(tt.input)).ParseProgram()
+		evaluated := New(Options{Capabilities: CapExec}).Eval(context.Background(), program, object.NewEnvironment())
+		testBuiltinResult(t, tt.input, evaluated, tt.expected)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	program := parser.New(lexer.New(`exec("sleep", ["5"])`)).ParseProgram()
+	evaluated := New(Options{Capabilities: CapExec}).Eval(ctx, program, object.NewEnvironment())
+	testBuiltinResult(t, "sleep", evaluated, errorMessage("evaluation interrupted: context deadline exceeded"))
+
+	testBuiltinResult(t, "denied", testEval(`exec("echo")`), errorMessage("`exec` requires the exec capability"))
+}
+
+func TestAuditLog(t *testing.T) {
+	input := `let files = glob("*.none");
+exec("echo", ["hi"]);
+len("pure")`
+	log := &AuditLog{}
+	program := parser.New(lexer.NewFile("audit.mky", input)).ParseProgram()
+	evaluated := New(Options{Capabilities: CapIO, Audit: log}).Eval(context.Background(), program, object.NewEnvironment())
+	testBuiltinResult(t, input, evaluated, errorMessage("`exec` requires the exec capability"))
+
+	entries := log.Entries()
+	if len(entries) != 2 {
+		t.Fatalf("wrong number of entries. want=2, got=%d", len(entries))
+	}
+	expected := []struct {
+		builtin string
+		args    string
+		pos     string
+		denied  bool
+	}{
+		{"glob", "*.none", "audit.mky:1:17", false},
+		{"exec", `echo ["hi"]`, "audit.mky:2:5", true},
+	}
+	for i, want := range expected {
+		got := entries[i]
+		if got.Builtin != want.builtin || strings.Join(got.Args, " ") != want.args ||
+			got.Pos.String() != want.pos || got.Denied != want.denied || got.Time.IsZero() {
+			t.Errorf("entries[%d] = %+v, want %+v", i, got, want)
+		}
+	}
+
+	if reset := log.Reset(); len(reset) != 2 || len(log.Entries()) != 0 {
+		t.Errorf("Reset returned %d entries and left %d", len(reset), len(log.Entries()))
+	}
+}
+
+func TestStats(t *testing.T) {
+	input := `let f = fn(n) { if (n == 0) { 0 } else { f(n - 1) } };
+f(3);
+let s = "a" + "b";
+[len(s), 100000 * 3, 1 + 1]`
+	program := parser.New(lexer.New(input)).ParseProgram()
+	e := New(Options{Stats: true})
+	e.Eval(context.Background(), program, object.NewEnvironment())
+
+	stats := e.Stats()
+	if stats.Nodes == 0 || stats.ScriptTime <= 0 {
+		t.Errorf("nothing was measured: %+v", stats)
+	}
+	if stats.MaxDepth != 4 {
+		t.Errorf("wrong max depth. want=4, got=%d", stats.MaxDepth)
+	}
+	expected := map[object.ObjectType]int{
+		object.FUNCTION_OBJ: 1,
+		object.STRING_OBJ:   3,
+		object.INTEGER_OBJ:  2,
+		object.ARRAY_OBJ:    1,
+	}
+	for typ, count := range expected {
+		if stats.Allocations[typ] != count {
+			t.Errorf("wrong number of %s allocations. want=%d, got=%d", typ, count, stats.Allocations[typ])
+		}
+	}
+
+	e.ResetStats()
+	if stats := e.Stats(); stats.Nodes != 0 || len(stats.Allocations) != 0 {
+		t.Errorf("stats not reset: %+v", stats)
+	}
+	if stats := New(Options{}).Stats(); stats.Nodes != 0 || stats.Allocations != nil {
+		t.Errorf("stats collected without the option: %+v", stats)
+	}
+}
+
+func TestSnapshot(t *testing.T) {
+	input := `let n = 42;
+let s = "hello";
+let flags = [true, false, if (false) { 1 }];
+let h = {"a": [1, 2], 3: {"b": "c"}};
+let double = fn(x) { x * 2 };
+let twice = fn(x) { double(double(x)) };
+let counter = fn() { let c = 0; fn() { c } }();
+let l = len;
+let pi = 3.25;
+let inf = 1.0 / 0;
+let nan = inf - inf;
+let t = parseTime("2024-02-29T12:30:00.5+09:00");`
+	e := New(Options{})
+	env := object.NewEnvironment()
+	e.Eval(context.Background(), parser.New(lexer.New(input)).ParseProgram(), env)
+	env.Set("cycle", selfContaining())
+
+	var b strings.Builder
+	skipped, err := Snapshot(&b, env)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(skipped, ",") != "counter,cycle,l" {
+		t.Errorf("wrong skipped names. got=%q", skipped)
+	}
+
+	restored := object.NewEnvironment()
+	if err := Restore(strings.NewReader(b.String()), restored); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`n`, 42},
+		{`s`, "hello"},
+		{`flags`, "[true, false, null]"},
+		{`h["a"][1]`, 2},
+		{`h[3]["b"]`, "c"},
+		{`twice(5)`, 20},
+		{`pi * 2`, "6.5"},
+		{`-inf`, "-Inf"},
+		{`nan == nan`, false},
+		{`nan`, "NaN"},
+		{`formatTime(t)`, "2024-02-29T12:30:00.5+09:00"},
+		{`counter`, errorMessage("identifier not found: counter")},
+	}
+	for _, tt := range tests {
+		evaluated := New(Options{}).Eval(context.Background(), parser.New(lexer.New(tt.input)).ParseProgram(), restored)
+		testBuiltinResult(t, tt.input, evaluated, tt.expected)
+	}
+
+	if err := Restore(strings.NewReader(`{"version": 2}`), restored); err == nil ||
+		err.Error() != "restore: unsupported snapshot version 2" {
+		t.Errorf("wrong error for an unknown version: %v", err)
+	}
+}
+
+func TestTime(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`parseTime("2024-01-02T03:04:05Z")`, "2024-01-02T03:04:05Z"},
+		{`formatTime(parseTime("02/01/2024", "01/02/2006"), "2006-01-02")`, "2024-02-01"},
+		{`addDuration(parseTime("2024-01-01T00:00:00Z"), 90000)`, "2024-01-01T00:01:30Z"},
+		{`addDuration(parseTime("2024-01-01T00:00:00Z"), -1)`, "2023-12-31T23:59:59.999Z"},
+		{`parseTime("2024-01-01T10:00:00+01:00") == parseTime("2024-01-01T09:00:00Z")`, true},
+		{`parseTime("2024-01-01T00:00:00Z") < parseTime("2024-01-02T00:00:00Z")`, true},
+		{`parseTime("2024-01-01T00:00:00Z") > parseTime("2024-01-02T00:00:00Z")`, false},
+		{`parseTime("2024-01-01T00:00:01Z") - parseTime("2024-01-01T00:00:00Z")`, "1s"},
+		{`let h = {parseTime("2024-01-01T10:00:00+01:00"): "x"}; h[parseTime("2024-01-01T09:00:00Z")]`, "x"},
+		{`now() < addDuration(now(), 1000)`, true},
+		{`now(1)`, errorMessage("wrong number of arguments. got=1, want=0")},
+		{`parseTime("yesterday")`, errorMessage(`parseTime: parsing time "yesterday" as "2006-01-02T15:04:05.999999999Z07:00": cannot parse "yesterday" as "2006"`)},
+		{`formatTime("2024")`, errorMessage("first argument to `formatTime` must be TIME, got STRING")},
+		{`addDuration(now(), "1h")`, errorMessage("second argument to `addDuration` must be INTEGER or DURATION, got STRING")},
+		{`now() + now()`, errorMessage("unknown operator: TIME + TIME")},
+		{`now() < 1`, errorMessage("type mismatch: TIME < INTEGER")},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEvalWithOptions(tt.input, Options{Capabilities: CapClock})
+		switch obj := evaluated.(type) {
+		case *object.Time, *object.Duration:
+			evaluated = &object.String{Value: obj.Inspect()}
+		}
+		testBuiltinResult(t, tt.input, evaluated, tt.expected)
+	}
+
+	testBuiltinResult(t, "now()", testEval("now()"),
+		errorMessage("`now` requires the clock capability"))
+}
+
+func TestDuration(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`1h30m`, "1h30m0s"},
+		{`1.5s + 250ms`, "1.75s"},
+		{`2h - 90m`, "30m0s"},
+		{`-5s`, "-5s"},
+		{`3 * 20ms`, "60ms"},
+		{`1s * 3 / 2`, "1.5s"},
+		{`1h / 15m`, 4},
+		{`1s > 999ms`, true},
+		{`60s == 1m`, true},
+		{`1s == 1000`, false},
+		{`{1m: "minute"}[60s]`, "minute"},
+		{`parseTime("2024-01-01T00:00:00Z") + 36h`, "2024-01-02T12:00:00Z"},
+		{`2h + parseTime("2024-01-01T00:00:00Z")`, "2024-01-01T02:00:00Z"},
+		{`parseTime("2024-01-01T00:00:00Z") - 1ns`, "2023-12-31T23:59:59.999999999Z"},
+		{`addDuration(parseTime("2024-01-01T00:00:00Z"), 1m)`, "2024-01-01T00:01:00Z"},
+		{`withTimeout(1s, fn() { 7 })`, 7},
+		{`let loop = fn(n) { loop(n + 1) }; withTimeout(10ms, fn() { loop(0) })`, errorMessage("timeout after 10ms")},
+		{`1s / 0`, errorMessage("division by zero: 1s / 0")},
+		{`1s / 0s`, errorMessage("division by zero: 1s / 0s")},
+		{`1s + 1`, errorMessage("unknown operator: DURATION + INTEGER")},
+		{`1s * 1s`, errorMessage("unknown operator: DURATION * DURATION")},
+		{`1s < 2`, errorMessage("type mismatch: DURATION < INTEGER")},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch obj := evaluated.(type) {
+		case *object.Time, *object.Duration:
+			evaluated = &object.String{Value: obj.Inspect()}
+		}
+		testBuiltinResult(t, tt.input, evaluated, tt.expected)
+	}
+}
+
+func TestWithTimeout(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`withTimeout(1000, fn() { 1 + 2 })`, 3},
+		{`withTimeout(1000, fn() { return 5; 6 })`, 5},
+		{`withTimeout(1000, fn() {})`, nil},
+		{`let loop = fn(n) { loop(n + 1) }; withTimeout(10, fn() { loop(0) })`, errorMessage("timeout after 10ms")},
+		{`withTimeout(1000, fn() { 1 + true })`, errorMessage("type mismatch: INTEGER + BOOLEAN")},
+		{`withTimeout(-1, fn() { 1 })`, errorMessage("first argument to `withTimeout` must be a non-negative INTEGER or DURATION, got -1")},
+		{`withTimeout(10, fn(x) { x })`, errorMessage("function passed to `withTimeout` must take 0 arguments, takes 1")},
+		{`withTimeout(10, 1)`, errorMessage("last argument to `withTimeout` must be FUNCTION, got INTEGER")},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEvalWithOptions(tt.input, Options{MaxFrames: math.MaxInt})
+		testBuiltinResult(t, tt.input, evaluated, tt.expected)
+	}
+
+	// The deadline applies only to the function, not to what follows it.
+	e := New(Options{MaxFrames: math.MaxInt})
+	env := object.NewEnvironment()
+	timeout := parser.New(lexer.New(`let loop = fn(n) { loop(n + 1) }; withTimeout(5, fn() { loop(0) })`)).ParseProgram()
+	e.Eval(context.Background(), timeout, env)
+	after := parser.New(lexer.New(`if (true) { 42 }`)).ParseProgram()
+	testBuiltinResult(t, "after timeout", e.Eval(context.Background(), after, env), 42)
+}
+
+func TestRetry(t *testing.T) {
+	flaky := `let calls = 0;
+let flaky = fn() { calls = calls + 1; if (calls < 3) { 1 + true } else { calls } };
+`
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{flaky + `retry(3, flaky)`, 3},
+		{flaky + `retry(2, flaky)`, errorMessage("type mismatch: INTEGER + BOOLEAN")},
+		{flaky + `retry(5, flaky); calls`, 3},
+		{`retry(1, fn() { 7 })`, 7},
+		{`retry(3, fn() {})`, nil},
+		{`retry(0, fn() { 7 })`, errorMessage("first argument to `retry` must be a positive INTEGER, got 0")},
+		{`retry(2, fn(x) { x })`, errorMessage("function passed to `retry` must take 0 arguments, takes 1")},
+		{flaky + `retryWithBackoff({"times": 3, "delay": 1, "factor": 3, "maxDelay": 2}, flaky)`, 3},
+		{flaky + `retryWithBackoff({"delay": 0}, flaky)`, 3},
+		{`retryWithBackoff({"times": 0}, fn() { 1 })`, errorMessage("option times must be a positive INTEGER, got 0")},
+		{`retryWithBackoff({"delay": "1s"}, fn() { 1 })`, errorMessage(`option delay must be a non-negative INTEGER or DURATION, got "1s"`)},
+		{`retryWithBackoff({"tries": 1}, fn() { 1 })`, errorMessage(`unknown option to ` + "`retryWithBackoff`" + `: "tries"`)},
+		{`retryWithBackoff([], fn() { 1 })`, errorMessage("options to `retryWithBackoff` must be HASH, got ARRAY")},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testBuiltinResult(t, tt.input, evaluated, tt.expected)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	program := parser.New(lexer.New(`retryWithBackoff({"times": 100, "delay": 1000}, fn() { 1 + true })`)).ParseProgram()
+	evaluated := New(Options{}).Eval(ctx, program, object.NewEnvironment())
+	testBuiltinResult(t, "interrupted", evaluated, errorMessage("evaluation interrupted: context deadline exceeded"))
+}
+
+func TestExpect(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`expect(1 + 1).toEqual(2)`, "true"},
+		{`expect([1, "a", {"k": [true]}]).toEqual([1, "a", {"k": [true]}])`, "true"},
+		{`expect("a" + "b").toEqual("ab")`, "true"},
+		{`expect([1, 2]).toEqual([1, 3])`, errorMessage("values differ:\n  [1]: got 2, want 3")},
+		{`expect("1").toEqual(1)`, errorMessage(`expected "1" to equal 1`)},
+		{`expect({"a": 1}).toEqual({"a": 2})`, errorMessage("values differ:\n  [\"a\"]: got 1, want 2")},
+		{`expect([1, 2]).toEqual({"a": 2})`, errorMessage(`expected [1, 2] to equal {"a": 2}`)},
+		{`expect([[1], "b"]).toContain([1])`, "true"},
+		{`expect([1, 2]).toContain(3)`, errorMessage("expected [1, 2] to contain 3")},
+		{`expect({"a": 1}).toContain("a")`, "true"},
+		{`expect({"a": 1}).toContain("b")`, errorMessage(`expected {"a": 1} to have the key "b"`)},
+		{`expect("monkey").toContain("key")`, "true"},
+		{`expect(1).toContain(1)`, errorMessage("`toContain` requires an ARRAY, HASH or STRING, got INTEGER")},
+		{`expect(fn() { 1 + true }).toThrow()`, "true"},
+		{`expect(fn() { 1 + true }).toThrow("type mismatch")`, "true"},
+		{`expect(fn() { 1 + true }).toThrow("unknown")`,
+			errorMessage(`expected an error containing "unknown", got "type mismatch: INTEGER + BOOLEAN"`)},
+		{`expect(fn() { 1 }).toThrow()`, errorMessage("expected an error, got 1")},
+		{`expect(1).toThrow()`, errorMessage("last argument to `toThrow` must be FUNCTION, got INTEGER")},
+		{`eachCase([[1, 2], [2, 4]], fn(c) { expect(c[0] * 2).toEqual(c[1]) })`, nil},
+		{`eachCase([[1, 2], [2, 5]], fn(c) { expect(c[0] * 2).toEqual(c[1]) })`,
+			errorMessage("case 1 ([2, 5]): expected 4 to equal 5")},
+		{`eachCase(1, fn(c) { c })`, errorMessage("first argument to `eachCase` must be ARRAY, got INTEGER")},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testBuiltinResult(t, tt.input, evaluated, tt.expected)
+	}
+
+	// Arrays that contain themselves are equal only to themselves.
+	env := object.NewEnvironment()
+	a := selfContaining()
+	env.Set("a", a)
+	env.Set("b", selfContaining())
+	for _, tt := range []struct {
+		input    string
+		expected interface{}
+	}{
+		{`expect(a).toEqual(a)`, "true"},
+		{`expect(a).toEqual(b)`, errorMessage("values differ:\n  [1]: got [1, [...]], want [1, [...]]")},
+		{`expect([a]).toContain(a)`, "true"},
+	} {
+		evaluated := New(Options{}).Eval(context.Background(), parser.New(lexer.New(tt.input)).ParseProgram(), env)
+		testBuiltinResult(t, tt.input, evaluated, tt.expected)
+	}
+}
+
+// selfContaining returns the array [1, itself], which no script can build.
+func selfContaining() *object.Array {
+	arr := &object.Array{Elements: []object.Object{&object.Integer{Value: 1}}}
+	arr.Elements = append(arr.Elements, arr)
+	return arr
+}
+
+func TestHashKeysAcrossSources(t *testing.T) {
+	// Hashes built by a literal, converted from Go and restored from a
+	// snapshot, each in another evaluator, must find each other's keys.
+	literal := testEval(`{"a": 1, 2: "b", true: [3]}`)
+	converted, err := object.FromGo(map[string]any{"a": 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	env := object.NewEnvironment()
+	env.Set("h", literal)
+	var b strings.Builder
+	if _, err := Snapshot(&b, env); err != nil {
+		t.Fatal(err)
+	}
+	restoredEnv := object.NewEnvironment()
+	if err := Restore(strings.NewReader(b.String()), restoredEnv); err != nil {
+		t.Fatal(err)
+	}
+	restored, _ := restoredEnv.Get("h")
+
+	scope := object.NewEnvironment()
+	scope.Set("literal", literal)
+	scope.Set("converted", converted)
+	scope.Set("restored", restored)
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`literal["a"] + converted["a"] + restored["a"]`, 3},
+		{`restored[2]`, "b"},
+		{`restored[true]`, "[3]"},
+		{`restored["2"]`, nil},
+		{`literal["true"]`, nil},
+	}
+	for _, tt := range tests {
+		program := parser.New(lexer.New(tt.input)).ParseProgram()
+		evaluated := New(Options{}).Eval(context.Background(), program, scope)
+		testBuiltinResult(t, tt.input, evaluated, tt.expected)
+	}
+}
+
+func TestCallEnvironmentReuse(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		// Calls of functions that create no closures reuse environments;
+		// none of that may be observable.
+		{`let add = fn(a, b) { a + b }; let g = fn(x) { add(x, 1) + add(x, 2) + x }; g(5)`, 18},
+		{`let id = fn(x) { x }; id(1) + id(2) * 10 + id(id(3)) * 100`, 321},
+		{`let fib = fn(n) { if (n < 2) { n } else { fib(n - 1) + fib(n - 2) } }; fib(15)`, 610},
+		{`let pair = fn(a, b) { let sum = a + b; [a, b, sum] }; let p = pair(1, 2); pair(5, 6); p`, "[1, 2, 3]"},
+		{`let f = fn(x) { let y = x; y }; f(1); let g = fn(z) { y }; g(2)`, errorMessage("identifier not found: y")},
+		{`let f = fn() { let leaked = 1; leaked }; f(); let g = fn() { leaked }; g()`, errorMessage("identifier not found: leaked")},
+		{`let f = fn(x) { defer puts(x); x * 2 }; f(1) + f(2)`, 6},
+		// Functions that create closures keep their environments.
+		{`let make = fn(x) { fn() { x } }; let a = make(1); let b = make(2); a() * 10 + b()`, 12},
+		{`let leaf = fn(x) { x }; let make = fn(x) { leaf(x + 1); fn() { x } }; let a = make(7); leaf(0); a()`, 7},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEvalWithOptions(tt.input, Options{IO: IOStreams{Out: io.Discard}})
+		testBuiltinResult(t, tt.input, evaluated, tt.expected)
+	}
+}
+
+func TestHeredoc(t *testing.T) {
+	input := `let greet = fn(name) {
+	<<<END
+	  "Hello,"
+	END + " " + name
+};
+greet("World")`
+
+	evaluated := testEval(input)
+	str, ok := evaluated.(*object.String)
+	if !ok {
+		t.Fatalf("object is not String. got=%T (%+v)", evaluated, evaluated)
+	}
+	if str.Value != `  "Hello," World` {
+		t.Errorf("String has wrong value. got=%q", str.Value)
+	}
+}
+
+func TestWhileExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`let i = 0; while (i < 5) { i = i + 1 }; i`, 5},
+		{`let i = 0; let sum = 0; while (i < 100000) { i = i + 1; sum = sum + i }; sum`, 5000050000},
+		{`while (false) { 1 }`, nil},
+		{`let f = fn() { let i = 0; while (true) { i = i + 1; if (i == 3) { return i } } }; f()`, 3},
+		{`let i = 0; while (i < 3) { i = i + 1; if (i == 2) { i + true } }`,
+			errorMessage("type mismatch: INTEGER + BOOLEAN")},
+		{`while (x) { 1 }`, errorMessage("identifier not found: x")},
+		{`let i = 0; while (i < 2) { let j = i; i = i + 1 }; j`, errorMessage("identifier not found: j")},
+		{`let i = 0; let j = 10; while (i < 2) { let j = i; i = i + 1 }; j`, 10},
+		{`let i = 0; let fs = []; while (i < 3) { let k = i; fs = push(fs, fn() { k }); i = i + 1 }; fs[0]() + fs[2]()`, 2},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testBuiltinResult(t, tt.input, evaluated, tt.expected)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	program := parser.New(lexer.New(`while (true) { 1 }`)).ParseProgram()
+	evaluated := New(Options{}).Eval(ctx, program, object.NewEnvironment())
+	testBuiltinResult(t, "interrupted", evaluated, errorMessage("evaluation interrupted: context deadline exceeded"))
+}
+
+func TestForExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`let sum = 0; for (let i = 1; i < 5; i = i + 1) { sum = sum + i }; sum`, 10},
+		{`let i = 0; for (i = 10; i > 7; i = i - 1) {}; i`, 7},
+		{`let n = 0; for (; n < 3;) { n = n + 1 }; n`, 3},
+		{`let f = fn() { for (;;) { return 4 } }; f()`, 4},
+		{`for (let i = 0; i < 3; i = i + 1) { i }`, nil},
+		{`let i = 5; for (let i = 0; i < 3; i = i + 1) {}; i`, 5},
+		{`let fs = []; for (let i = 0; i < 3; i = i + 1) { fs = push(fs, fn() { i }) }; fs[0]() + fs[2]()`, 2},
+		{`let s = ""; for (x in ["a", "b", "c"]) { s = s + x }; s`, "abc"},
+		{`let s = ""; for (c in "héllo") { s = c + s }; s`, "olléh"},
+		{`let s = ""; for (k in {"b": 1, "a": 2, "c": 3}) { s = s + k }; s`, "abc"},
+		{`let s = []; for (k in {10: 1, 2: 2, -3: 3, 100: 4, 1: 5}) { s = push(s, k) }; s`, "[-3, 1, 2, 10, 100]"},
+		{`let s = []; for (k in {"10": 1, 9: 2, true: 3, "9": 4, 10: 5, false: 6}) { s = push(s, k) }; s`,
+			`[false, true, 9, 10, "10", "9"]`},
+		{`let fs = []; for (x in [1, 2]) { fs = push(fs, fn() { x }) }; fs[0]()`, 1},
+		{`for (x in [1]) { let y = x }; y`, errorMessage("identifier not found: y")},
+		{`for (x in 5) {}`, errorMessage("cannot iterate over INTEGER")},
+		{`for (x in [1, true]) { x + 1 }`, errorMessage("type mismatch: BOOLEAN + INTEGER")},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testBuiltinResult(t, tt.input, evaluated, tt.expected)
+	}
+}
+
+func TestReturnInExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		// The value of a block is the value of its last statement, so an
+		// if at the end of a function body is its result.
+		{"let f = fn(c) { if (c) { 1 } else { 2 } }; f(false)", 2},
+		{"let f = fn() { if (false) { 1 } }; f()", nil},
+		{"let f = fn() { if (true) { if (true) { 1 } } }; f()", 1},
+		{"let f = fn() { if (true) { 1; 2 } else { 3 }; }; f()", 2},
+		{"let f = fn() { let x = 1; }; f()", nil},
+		{"let f = fn() {}; f()", nil},
+		{"let x = if (true) { let y = 1; }; x", nil},
+
+		// A return in a nested block returns from the function, skipping
+		// the rest of every enclosing block.
+		{"let f = fn() { if (true) { if (true) { return 1; } 2 } 3 }; f()", 1},
+		{"let f = fn() { if (true) { return 1; 2 } }; f()", 1},
+
+		// A return inside an expression returns from the function too,
+		// without finishing the expression.
+		{"let f = fn(c) { let x = if (c) { return 1; } else { 2 }; x + 10 }; f(true)", 1},
+		{"let f = fn(c) { let x = if (c) { return 1; } else { 2 }; x + 10 }; f(false)", 12},
+		{"let f = fn() { let x = 0; x = if (true) { return 9 } else { 1 }; x }; f()", 9},
+		{"let f = fn() { 1 + if (true) { return 10 } else { 0 } + 100 }; f()", 10},
+		{"let f = fn() { -if (true) { return 5 } }; f()", 5},
+		{"let g = fn(x) { x * 100 }; let f = fn() { g(if (true) { return 3 }) }; f()", 3},
+		{"let f = fn() { [1, if (true) { return 4 }, 3] }; f()", 4},
+		{"let f = fn() { [1, 2][if (true) { return 5 } else { 0 }] }; f()", 5},
+		{`let f = fn() { {"a": if (true) { return 6 }} }; f()`, 6},
+		{"let f = fn() { if (if (true) { return 7 }) { 1 } else { 2 } }; f()", 7},
+		{"let f = fn() { return if (true) { return 8 } else { 9 }; }; f()", 8},
+
+		// Only the innermost function returns.
+		{"let g = fn() { let x = if (true) { return 1 } else { 2 }; 100 }; g() + 10", 11},
+		{"let apply = fn(f) { f(1) + 5 }; apply(fn(x) { let y = if (x == 1) { return 0 } else { x }; y })", 5},
+
+		// At the top level, a return ends the program.
+		{"let x = if (true) { return 1; } else { 2 }; x + 10", 1},
+		{"let x = if (false) { return 1; } else { 2 }; x + 10", 12},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		default:
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+func TestDiff(t *testing.T) {
+	tests := []struct {
+		got, want string
+		expected  []string
+	}{
+		{`[1, 2]`, `[1, 2]`, []string{}},
+		{`[1, [2, "a"]]`, `[1, [2, "b"]]`, []string{`[1][1]: got "a", want "b"`}},
+		{`[1]`, `[1, 2, 3]`, []string{"[1]: missing, want 2", "[2]: missing, want 3"}},
+		{`[1, 2]`, `[]`, []string{"[0]: got 1, want nothing", "[1]: got 2, want nothing"}},
+		{`{"a": 1, "b": [1], "c": 3}`, `{"a": 1, "b": [2], "d": 4}`, []string{
+			`["b"][0]: got 1, want 2`,
+			`["c"]: got 3, want nothing`,
+			`["d"]: missing, want 4`,
+		}},
+		{`[{"k": true}]`, `[{"k": 1}]`, []string{`[0]["k"]: got true, want 1`}},
+		{`[0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0]`, `[1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1]`, []string{
+			"[0]: got 0, want 1", "[1]: got 0, want 1", "[2]: got 0, want 1", "[3]: got 0, want 1",
+			"[4]: got 0, want 1", "[5]: got 0, want 1", "[6]: got 0, want 1", "[7]: got 0, want 1",
+			"[8]: got 0, want 1", "[9]: got 0, want 1", "... and 2 more",
+		}},
+		{`[1]`, `{}`, nil},
+		{`1`, `2`, nil},
+	}
+
+	for _, tt := range tests {
+		got := testEval(tt.got)
+		want := testEval(tt.want)
+		lines := diff(got, want)
+		if tt.expected == nil {
+			if lines != nil {
+				t.Errorf("diff(%s, %s) = %q, want nil", tt.got, tt.want, lines)
+			}
+			continue
+		}
+		if strings.Join(lines, "\n") != strings.Join(tt.expected, "\n") {
+			t.Errorf("diff(%s, %s) = %q, want %q", tt.got, tt.want, lines, tt.expected)
+		}
+	}
+}
+
+func TestRecording(t *testing.T) {
+	input := `let x = 1;
+let f = fn(n) { x = x + n; x };
+f(10);
+let y = f(5);`
+	rec := &Recording{}
+	program := parser.New(lexer.New(input)).ParseProgram()
+	env := object.NewEnvironment()
+	New(Options{Record: rec}).Eval(context.Background(), program, env)
+
+	steps := rec.Steps()
+	expected := []struct {
+		statement string
+		depth     int
+		changes   string
+	}{
+		{"let x = 1;", 0, "x: <nil> -> 1"},
+		{"let f = fn(n) (x = (x + n))x;", 0, "f: <nil> -> fn"},
+		{"f(10)", 0, ""},
+		{"(x = (x + n))", 1, "x: 1 -> 11"},
+		{"x", 1, ""},
+		{"let y = f(5);", 0, "y: <nil> -> 16"},
+		{"(x = (x + n))", 1, "x: 11 -> 16"},
+		{"x", 1, ""},
+	}
+	if len(steps) != len(expected) {
+		t.Fatalf("wrong number of steps. want=%d, got=%d", len(expected), len(steps))
+	}
+	for i, want := range expected {
+		step := steps[i]
+		var changes []string
+		for _, c := range step.Changes {
+			old := "<nil>"
+			if c.Old != nil {
+				old = c.Old.Inspect()
+			}
+			new := c.New.Inspect()
+			if c.New.Type() == object.FUNCTION_OBJ {
+				new = "fn"
+			}
+			changes = append(changes, fmt.Sprintf("%s: %s -> %s", c.Name, old, new))
+		}
+		if step.Statement.String() != want.statement || step.Depth != want.depth ||
+			strings.Join(changes, ", ") != want.changes {
+			t.Errorf("steps[%d] = %q at depth %d changing %q, want %+v",
+				i, step.Statement, step.Depth, changes, want)
+		}
+	}
+
+	values := []struct {
+		step     int
+		env      *object.Environment
+		name     string
+		expected interface{}
+	}{
+		{0, env, "x", nil},
+		{1, env, "x", 1},
+		{4, env, "x", 11},
+		{6, env, "x", 11},
+		{7, env, "x", 16},
+		{len(steps), env, "x", 16},
+		{6, env, "y", nil},
+		{len(steps), env, "y", 16},
+		{4, steps[4].Env, "n", 10},
+		{4, steps[4].Env, "x", 11},
+		{7, steps[7].Env, "n", 5},
+	}
+	for _, v := range values {
+		obj, ok := rec.ValueAt(v.step, v.env, v.name)
+		switch expected := v.expected.(type) {
+		case int:
+			if !ok {
+				t.Errorf("%s at step %d is not bound", v.name, v.step)
+				continue
+			}
+			testIntegerObject(t, obj, int64(expected))
+		default:
+			if ok {
+				t.Errorf("%s at step %d = %s, want it unbound", v.name, v.step, obj.Inspect())
+			}
+		}
+	}
+}
+
+func TestTrace(t *testing.T) {
+	input := `let f = fn(n) { defer len("x"); if (n == 0) { 1 + true } else { f(n - 1) } };
+let g = fn(h) { h(1) };
+g(fn(x) { x });
+eachCase([1], fn(c) { c });
+f(1)`
+	trace := &Trace{}
+	program := parser.New(lexer.NewFile("trace.mky", input)).ParseProgram()
+	New(Options{Trace: trace}).Eval(context.Background(), program, object.NewEnvironment())
+
+	var out bytes.Buffer
+	if err := trace.WriteJSON(&out); err != nil {
+		t.Fatal(err)
+	}
+	var file struct {
+		TraceEvents []struct {
+			Name, Cat, Ph string
+			TS, Dur       float64
+			Args          map[string]string
+		}
+	}
+	if err := json.Unmarshal(out.Bytes(), &file); err != nil {
+		t.Fatal(err)
+	}
+
+	// Spans are recorded as calls finish, innermost first.
+	expected := []struct {
+		name, cat, pos, err string
+	}{
+		{"h", "function", "trace.mky:2:18", ""},
+		{"g", "function", "trace.mky:3:2", ""},
+		{"fn@trace.mky:4:21", "function", "", ""},
+		{"eachCase", "builtin", "trace.mky:4:9", ""},
+		{"len", "builtin", "trace.mky:1:26", ""},
+		{"f", "function", "trace.mky:1:66", "type mismatch: INTEGER + BOOLEAN"},
+		{"len", "builtin", "trace.mky:1:26", ""},
+		{"f", "function", "trace.mky:5:2", "type mismatch: INTEGER + BOOLEAN"},
+	}
+	if len(file.TraceEvents) != len(expected) || trace.Len() != len(expected) {
+		t.Fatalf("wrong number of events. want=%d, got=%d", len(expected), len(file.TraceEvents))
+	}
+	for i, want := range expected {
+		got := file.TraceEvents[i]
+		if got.Name != want.name || got.Cat != want.cat || got.Ph != "X" ||
+			got.Args["pos"] != want.pos || got.Args["error"] != want.err || got.TS < 0 || got.Dur < 0 {
+			t.Errorf("events[%d] = %+v, want %+v", i, got, want)
+		}
+	}
+	if outer, inner := file.TraceEvents[7], file.TraceEvents[5]; inner.TS < outer.TS ||
+		inner.TS+inner.Dur > outer.TS+outer.Dur {
+		t.Errorf("span %+v isn't inside %+v", inner, outer)
+	}
+}
+
+func TestHashShorthand(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{`let name = 1; let age = 2; let h = {name, age}; h["name"] * 10 + h["age"]`, 12},
+		{`let f = fn(x) { {x} }; f(3)["x"]`, 3},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testIntegerObject(t, evaluated, tt.expected)
+	}
+}
+
+func TestBlockStatementAtStatementStart(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{`{ let x = 2; x * 3 }`, 6},
+		{`{ let x = 2; }; x`, 2},
+		{`let f = fn() { { return 4; } 5 }; f()`, 4},
+		{`{ let k = "a"; }; {"a": 7}[k]`, 7},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testIntegerObject(t, evaluated, tt.expected)
+	}
+}
+
+func TestResourceLimits(t *testing.T) {
+	tests := []struct {
+		input    string
+		opts     Options
+		expected interface{}
+	}{
+		{`let a = 1; let b = 2; a + b`, Options{MaxSteps: 3}, 3},
+		{`let a = 1; let b = 2; a + b`, Options{MaxSteps: 2},
+			errorMessage("step limit exceeded: ran more than 2 statements")},
+		{`let f = fn() { 1; 2 }; f()`, Options{MaxSteps: 3},
+			errorMessage("step limit exceeded: ran more than 3 statements")},
+		{`while (true) {}`, Options{MaxSteps: 1000},
+			errorMessage("step limit exceeded: ran more than 1000 statements")},
+		{`for (;;) {}`, Options{MaxSteps: 1000},
+			errorMessage("step limit exceeded: ran more than 1000 statements")},
+		{`for (;true;) {}`, Options{MaxSteps: 1000},
+			errorMessage("step limit exceeded: ran more than 1000 statements")},
+		{`for (x in [1, 2, 3]) {}`, Options{MaxSteps: 2},
+			errorMessage("step limit exceeded: ran more than 2 statements")},
+		{`len("abc" + "def")`, Options{MaxAllocBytes: 100}, 6},
+		{`let s = "abcdefgh"; let t = s + s + s + s; t + t`, Options{MaxAllocBytes: 100},
+			errorMessage("memory limit exceeded: allocated more than 100 bytes")},
+		{`len([1, 2, 3])`, Options{MaxAllocBytes: 80}, 3},
+		{`[1, 2, 3, 4]`, Options{MaxAllocBytes: 80},
+			errorMessage("memory limit exceeded: allocated more than 80 bytes")},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEvalWithOptions(tt.input, tt.opts)
+		testBuiltinResult(t, tt.input, evaluated, tt.expected)
+	}
+
+	// The strings glob makes count as well as the array holding them.
+	dir := t.TempDir()
+	for i := 0; i < 20; i++ {
+		if err := os.WriteFile(filepath.Join(dir, fmt.Sprintf("file%02d.mky", i)), nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	input := fmt.Sprintf("len(glob(%q))", filepath.Join(dir, "*"))
+	evaluated := testEvalWithOptions(input, Options{Capabilities: CapIO, MaxAllocBytes: 1000})
+	testBuiltinResult(t, input, evaluated, errorMessage("memory limit exceeded: allocated more than 1000 bytes"))
+	evaluated = testEvalWithOptions(input, Options{Capabilities: CapIO, MaxAllocBytes: 10000})
+	testBuiltinResult(t, input, evaluated, 20)
+}
+
+func TestMemoize(t *testing.T) {
+	fib := `let fib = memoize(fn(n) { if (n < 2) { n } else { fib(n - 1) + fib(n - 2) } });
+`
+	tests := []struct {
+		input    string
+		opts     Options
+		expected interface{}
+	}{
+		{fib + `fib(80)`, Options{MaxSteps: 1000}, 23416728348467685},
+		{`let add = memoize(fn(a, b) { a + b }); add(1, 2) + add(1, 2) + add(2, 1)`, Options{}, 9},
+		{`memoize(fn() { "x" })()`, Options{}, "x"},
+		{`let half = memoize(fn(x) { x / 2 }); half(5); half(5.0)`, Options{}, "2.5"},
+		{`memoize(fn(x) { let y = 1; y = x; y })(3)`, Options{}, 3},
+		{`memoize(fn(x) { let puts = fn(y) { y }; puts(x) })(4)`, Options{}, 4},
+		{`memoize(1)`, Options{}, errorMessage("argument to `memoize` must be FUNCTION, got INTEGER")},
+		{`memoize(fn(x) { puts(x) })`, Options{}, errorMessage("cannot memoize a function that calls puts")},
+		{`let n = 0; memoize(fn() { n = n + 1 })`, Options{}, errorMessage("cannot memoize a function that assigns to n")},
+		{`memoize(fn(x) { now() })`, Options{}, errorMessage("cannot memoize a function that calls now")},
+		{`memoize(fn(x) { x })([1])`, Options{}, errorMessage("argument 1 of a memoized function is unhashable: ARRAY")},
+		{`memoize(fn(x) { x })(1, 2)`, Options{}, errorMessage("wrong number of arguments. got=2, want=1")},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEvalWithOptions(tt.input, tt.opts)
+		testBuiltinResult(t, tt.input, evaluated, tt.expected)
+	}
+
+	// The function can't see that show prints, so the output tells which
+	// calls were cached.
+	input := `let show = fn(x) { puts(x) };
+let f = memoize(fn(x) { show(x); x });
+f(1); f(2); f(1); f(2); f(3); f(3);`
+	for _, tt := range []struct {
+		limit    int
+		expected string
+	}{
+		{0, "1\n2\n3\n"},
+		{1, "1\n2\n2\n3\n3\n"},
+	} {
+		var out bytes.Buffer
+		testEvalWithOptions(input, Options{MaxMemoEntries: tt.limit, IO: IOStreams{Out: &out}})
+		if out.String() != tt.expected {
+			t.Errorf("MaxMemoEntries %d: wrong output. got=%q, want=%q", tt.limit, out.String(), tt.expected)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package evaluator
+
+import (
+	"bytes"
+	"errors"
+	"os/exec"
+
+	"github.com/rock619/monkey/object"
+)
+
+func (e *Evaluator) exec(args []object.Object) object.Object {
+	if len(args) != 1 && len(args) != 2 {
+		return newError("wrong number of arguments. got=%d, want=1 or 2",
+			len(args))
+	}
+	name, ok := args[0].(*object.String)
+	if !ok {
+		return newError("first argument to `exec` must be STRING, got %s",
+			args[0].Type())
+	}
+
+	var cmdArgs []string
+	if len(args) == 2 {
+		list, ok := args[1].(*object.Array)
+		if !ok {
+			return newError("second argument to `exec` must be ARRAY, got %s",
+				args[1].Type())
+		}
+		for _, arg := range list.Elements {
+			s, ok := arg.(*object.String)
+			if !ok {
+				return newError("arguments of `exec` must be STRING, got %s", arg.Type())
+			}
+			cmdArgs = append(cmdArgs, s.Value)
+		}
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(e.ctx, name.Value, cmdArgs...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	err := cmd.Run()
+	if err := e.interrupted(); err != nil {
+		return err
+	}
+	var exitErr *exec.ExitError
+	if err != nil && !errors.As(err, &exitErr) {
+		return newError("exec: %s", err)
+	}
+
+	result, _ := object.FromGo(map[string]any{
+		"stdout": stdout.String(),
+		"stderr": stderr.String(),
+		"code":   cmd.ProcessState.ExitCode(),
+	})
+	return result
+}
@@ -0,0 +1,208 @@
+package evaluator
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/rock619/monkey/object"
+)
+
+// expect returns the matchers for actual, a hash of builtins that return
+// true when actual matches and an error describing the mismatch otherwise:
+//
+//	expect(x).toEqual(y)       x and y have equal contents; for arrays and
+//	                           hashes the error lists the elements that
+//	                           differ
+//	expect(x).toContain(y)     the array x has an element equal to y, the
+//	                           hash x has the key y or the string x has the
+//	                           substring y
+//	expect(f).toThrow()        calling f returns an error
+//	expect(f).toThrow(msg)     ... whose message contains msg
+func (e *Evaluator) expect(actual object.Object) object.Object {
+	matchers := map[string]object.BuiltinFunction{
+		"toEqual": func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+			if deepEqual(actual, args[0], object.Visited{}) {
+				return TRUE
+			}
+			if lines := diff(actual, args[0]); lines != nil {
+				return newError("%s", describeDiff(lines))
+			}
+			return match(false, "expected %s to equal %s", actual, args[0])
+		},
+		"toContain": func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+			return e.toContain(actual, args[0])
+		},
+		"toThrow": func(args ...object.Object) object.Object {
+			if len(args) > 1 {
+				return newError("wrong number of arguments. got=%d, want=0 or 1", len(args))
+			}
+			return e.toThrow(actual, args)
+		},
+	}
+
+	hash := &object.Hash{Pairs: make(map[object.HashKey]object.HashPair, len(matchers))}
+	for name, fn := range matchers {
+		key := &object.String{Value: name}
+		hash.Pairs[key.HashKey()] = object.HashPair{Key: key, Value: &object.Builtin{Fn: fn}}
+	}
+	return hash
+}
+
+// match returns true if ok and otherwise an error formatted with the
+// inspected values.
+func match(ok bool, format string, values ...object.Object) object.Object {
+	if ok {
+		return TRUE
+	}
+	args := make([]interface{}, len(values))
+	for i, v := range values {
+		args[i] = object.InspectElement(v)
+	}
+	return newError(format, args...)
+}
+
+func (e *Evaluator) toContain(actual, elem object.Object) object.Object {
+	switch actual := actual.(type) {
+	case *object.Array:
+		for _, el := range actual.Elements {
+			if deepEqual(el, elem, object.Visited{}) {
+				return TRUE
+			}
+		}
+		return match(false, "expected %s to contain %s", actual, elem)
+	case *object.Hash:
+		key, ok := elem.(object.Hashable)
+		if !ok {
+			return newError("unusable as hash key: %s", elem.Type())
+		}
+		_, found := actual.Pairs[key.HashKey()]
+		return match(found, "expected %s to have the key %s", actual, elem)
+	case *object.String:
+		sub, ok := elem.(*object.String)
+		if !ok {
+			return newError("argument to `toContain` on a STRING must be STRING, got %s", elem.Type())
+		}
+		return match(strings.Contains(actual.Value, sub.Value), "expected %s to contain %s", actual, elem)
+	default:
+		return newError("`toContain` requires an ARRAY, HASH or STRING, got %s", actual.Type())
+	}
+}
+
+func (e *Evaluator) toThrow(fn object.Object, args []object.Object) object.Object {
+	if err := checkCallback("toThrow", fn, 0); err != nil {
+		return err
+	}
+	var want *object.String
+	if len(args) == 1 {
+		s, ok := args[0].(*object.String)
+		if !ok {
+			return newError("argument to `toThrow` must be STRING, got %s", args[0].Type())
+		}
+		want = s
+	}
+
+	result := e.applyFunction(fn, nil)
+	if e.ctx.Err() != nil {
+		return result
+	}
+	err, ok := result.(*object.Error)
+	if !ok {
+		if result == nil {
+			result = NULL
+		}
+		return match(false, "expected an error, got %s", result)
+	}
+	if want != nil && !strings.Contains(err.Message, want.Value) {
+		return match(false, "expected an error containing %s, got %s",
+			want, &object.String{Value: err.Message})
+	}
+	return TRUE
+}
+
+// eachCase calls fn with every element of cases and stops at the first
+// error, which it prefixes with the number and value of the case.
+func (e *Evaluator) eachCase(args []object.Object) object.Object {
+	if len(args) != 2 {
+		return newError("wrong number of arguments. got=%d, want=2", len(args))
+	}
+	cases, ok := args[0].(*object.Array)
+	if !ok {
+		return newError("first argument to `eachCase` must be ARRAY, got %s", args[0].Type())
+	}
+	if err := checkCallback("eachCase", args[1], 1); err != nil {
+		return err
+	}
+
+	for i, c := range cases.Elements {
+		result := e.applyFunction(args[1], []object.Object{c})
+		if err, ok := result.(*object.Error); ok && e.ctx.Err() == nil {
+			return &object.Error{
+				Message: fmt.Sprintf("case %d (%s): %s", i, object.InspectElement(c), err.Message),
+				Pos:     err.Pos,
+			}
+		}
+		if isError(result) {
+			return result
+		}
+	}
+	return NULL
+}
+
+// deepEqual reports whether a and b have equal contents. Values other than
+// integers, floats, times, durations, strings, booleans, null, arrays and
+// hashes are equal only to themselves, and so are arrays and hashes that
+// contain themselves.
+func deepEqual(a, b object.Object, visited object.Visited) bool {
+	if a == b {
+		return true
+	}
+	switch a := a.(type) {
+	case *object.Integer:
+		b, ok := b.(*object.Integer)
+		return ok && a.Value == b.Value
+	case *object.Float:
+		b, ok := b.(*object.Float)
+		return ok && a.Value == b.Value
+	case *object.Time:
+		b, ok := b.(*object.Time)
+		return ok && a.Value.Equal(b.Value)
+	case *object.Duration:
+		b, ok := b.(*object.Duration)
+		return ok && a.Value == b.Value
+	case *object.String:
+		b, ok := b.(*object.String)
+		return ok && a.Value == b.Value
+	case *object.Array:
+		b, ok := b.(*object.Array)
+		if !ok || len(a.Elements) != len(b.Elements) || visited.Enter(a) {
+			return false
+		}
+		defer visited.Leave(a)
+		for i := range a.Elements {
+			if !deepEqual(a.Elements[i], b.Elements[i], visited) {
+				return false
+			}
+		}
+		return true
+	case *object.Hash:
+		b, ok := b.(*object.Hash)
+		if !ok || len(a.Pairs) != len(b.Pairs) || visited.Enter(a) {
+			return false
+		}
+		defer visited.Leave(a)
+		for key, pa := range a.Pairs {
+			pb, ok := b.Pairs[key]
+			if !ok || !deepEqual(pa.Value, pb.Value, visited) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
@@ -0,0 +1,119 @@
+package evaluator
+
+import (
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/rock619/monkey/object"
+)
+
+// numberFormat is the second argument of formatNumber.
+type numberFormat struct {
+	// decimals is the number of digits after the decimal point.
+	decimals int
+	// thousands separates groups of three digits, if not empty.
+	thousands string
+	// point is the decimal point.
+	point string
+}
+
+var defaultNumberFormat = numberFormat{point: "."}
+
+// parseNumberFormat reads a hash with the optional keys "decimals",
+// "thousands" and "point".
+func parseNumberFormat(obj object.Object) (numberFormat, object.Object) {
+	format := defaultNumberFormat
+
+	hash, ok := obj.(*object.Hash)
+	if !ok {
+		return format, newError("options to `formatNumber` must be HASH, got %s", obj.Type())
+	}
+	for _, pair := range hash.Pairs {
+		key, _ := pair.Key.(*object.String)
+		switch {
+		case key == nil:
+			return format, newError("unknown option to `formatNumber`: %s", pair.Key.Inspect())
+		case key.Value == "decimals":
+			n, ok := pair.Value.(*object.Integer)
+			if !ok || n.Value < 0 || n.Value > 20 {
+				return format, newError("option decimals must be an INTEGER from 0 to 20, got %s",
+					object.InspectElement(pair.Value))
+			}
+			format.decimals = int(n.Value)
+		case key.Value == "thousands" || key.Value == "point":
+			s, ok := pair.Value.(*object.String)
+			if !ok {
+				return format, newError("option %s must be STRING, got %s", key.Value, pair.Value.Type())
+			}
+			if key.Value == "thousands" {
+				format.thousands = s.Value
+			} else {
+				format.point = s.Value
+			}
+		default:
+			return format, newError("unknown option to `formatNumber`: %q", key.Value)
+		}
+	}
+	return format, nil
+}
+
+// integer formats n, padding the decimals with zeros.
+func (f numberFormat) integer(n int64) string {
+	var out strings.Builder
+
+	digits := strconv.FormatInt(n, 10)
+	if n < 0 {
+		out.WriteByte('-')
+		digits = digits[1:]
+	}
+	out.WriteString(f.group(digits))
+
+	if f.decimals > 0 {
+		out.WriteString(f.point)
+		out.WriteString(strings.Repeat("0", f.decimals))
+	}
+	return out.String()
+}
+
+// float formats x rounded to the decimals. Infinities and NaN are shown as
+// +Inf, -Inf and NaN.
+func (f numberFormat) float(x float64) string {
+	if math.IsInf(x, 0) || math.IsNaN(x) {
+		return strconv.FormatFloat(x, 'f', -1, 64)
+	}
+
+	var out strings.Builder
+	digits := strconv.FormatFloat(x, 'f', f.decimals, 64)
+	if digits[0] == '-' {
+		out.WriteByte('-')
+		digits = digits[1:]
+	}
+	whole, fraction, _ := strings.Cut(digits, ".")
+	out.WriteString(f.group(whole))
+
+	if f.decimals > 0 {
+		out.WriteString(f.point)
+		out.WriteString(fraction)
+	}
+	return out.String()
+}
+
+// group inserts the thousands separator into a string of digits.
+func (f numberFormat) group(digits string) string {
+	if f.thousands == "" || len(digits) <= 3 {
+		return digits
+	}
+
+	var out strings.Builder
+	first := len(digits) % 3
+	if first == 0 {
+		first = 3
+	}
+	out.WriteString(digits[:first])
+	for i := first; i < len(digits); i += 3 {
+		out.WriteString(f.thousands)
+		out.WriteString(digits[i : i+3])
+	}
+	return out.String()
+}
@@ -0,0 +1,62 @@
+package evaluator
+
+import (
+	"github.com/rock619/monkey/ast"
+	"github.com/rock619/monkey/object"
+)
+
+// maxFreeEnvs bounds the number of environments kept for reuse.
+const maxFreeEnvs = 256
+
+// maxEscapes bounds the number of function bodies createsClosures
+// remembers, which would otherwise grow with every input of a REPL session.
+const maxEscapes = 4096
+
+// createsClosures reports whether evaluating body may capture the
+// environment of the call, which it does only by evaluating a function
+// literal. The environments of calls to other functions can be reused once
+// the call returns. The answer is cached per body, until the cache fills
+// up and starts over.
+func (e *Evaluator) createsClosures(body *ast.BlockStatement) bool {
+	if escapes, ok := e.escapes[body]; ok {
+		return escapes
+	}
+	if len(e.escapes) >= maxEscapes {
+		clear(e.escapes)
+	}
+
+	escapes := false
+	ast.Inspect(body, func(n ast.Node) bool {
+		if _, ok := n.(*ast.FunctionLiteral); ok {
+			escapes = true
+		}
+		return !escapes
+	})
+	e.escapes[body] = escapes
+	return escapes
+}
+
+// newCallEnv returns an environment for a call of fn with args, reusing a
+// released one if possible.
+func (e *Evaluator) newCallEnv(fn *object.Function, args []object.Object) *object.Environment {
+	n := len(e.freeEnvs)
+	if n == 0 {
+		return extendFunctionEnv(fn, args)
+	}
+
+	env := e.freeEnvs[n-1]
+	e.freeEnvs = e.freeEnvs[:n-1]
+	env.Reset(fn.Env)
+	for i, param := range fn.Parameters {
+		env.Set(param.Value, args[i])
+	}
+	return env
+}
+
+// releaseCallEnv makes env, which nothing refers to any more, available to
+// newCallEnv.
+func (e *Evaluator) releaseCallEnv(env *object.Environment) {
+	if len(e.freeEnvs) < maxFreeEnvs {
+		e.freeEnvs = append(e.freeEnvs, env)
+	}
+}
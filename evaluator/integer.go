@@ -0,0 +1,27 @@
+package evaluator
+
+import "github.com/rock619/monkey/object"
+
+// Integers in [minCachedInteger, maxCachedInteger] are preallocated and
+// shared. This is safe because Integer objects are never modified, and it
+// saves an allocation for most loop counters and arithmetic results.
+const (
+	minCachedInteger = -128
+	maxCachedInteger = 1023
+)
+
+var cachedIntegers = func() []object.Integer {
+	integers := make([]object.Integer, maxCachedInteger-minCachedInteger+1)
+	for i := range integers {
+		integers[i].Value = int64(i + minCachedInteger)
+	}
+	return integers
+}()
+
+// newInteger returns an Integer holding value.
+func newInteger(value int64) *object.Integer {
+	if value >= minCachedInteger && value <= maxCachedInteger {
+		return &cachedIntegers[value-minCachedInteger]
+	}
+	return &object.Integer{Value: value}
+}
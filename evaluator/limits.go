@@ -0,0 +1,87 @@
+package evaluator
+
+import (
+	"github.com/rock619/monkey/ast"
+	"github.com/rock619/monkey/object"
+)
+
+// step is called before each statement and each iteration of a loop, at
+// node. It fails when the context of the evaluation is done or the step
+// budget of MaxSteps is spent.
+func (e *Evaluator) step(node ast.Node) *object.Error {
+	if e.opts.MaxSteps > 0 {
+		e.steps++
+		if e.steps > e.opts.MaxSteps {
+			err := newError("step limit exceeded: ran more than %d statements", e.opts.MaxSteps)
+			err.Pos = node.Pos()
+			return err
+		}
+	}
+	return e.interrupted()
+}
+
+// charge adds the size of obj, the value of node, to the bytes allocated
+// and fails once they exceed MaxAllocBytes. Node is nil for the results of
+// builtins, which are charged with all the values they hold, because a
+// builtin such as glob or exec may have made every one of them. Values a
+// builtin passes through are charged again, which errs on the safe side.
+func (e *Evaluator) charge(node ast.Node, obj object.Object) object.Object {
+	var size int64
+	switch node.(type) {
+	case nil:
+		size = deepSize(obj, object.Visited{})
+	case *ast.StringLiteral, *ast.ArrayLiteral, *ast.HashLiteral, *ast.InfixExpression:
+		size = sizeOf(obj)
+	}
+	if size == 0 {
+		return obj
+	}
+
+	e.allocated += size
+	if e.allocated > e.opts.MaxAllocBytes {
+		return newError("memory limit exceeded: allocated more than %d bytes", e.opts.MaxAllocBytes)
+	}
+	return obj
+}
+
+// sizeOf estimates the bytes taken by obj itself, not counting the values
+// it holds. Values other than strings, arrays and hashes are free.
+func sizeOf(obj object.Object) int64 {
+	switch obj := obj.(type) {
+	case *object.String:
+		return 16 + int64(len(obj.Value))
+	case *object.Array:
+		return 24 + 16*int64(len(obj.Elements))
+	case *object.Hash:
+		return 48 + 64*int64(len(obj.Pairs))
+	default:
+		return 0
+	}
+}
+
+// deepSize is sizeOf obj and of every value it holds, each counted once
+// even if it is held in several places or holds itself.
+func deepSize(obj object.Object, visited object.Visited) int64 {
+	switch obj := obj.(type) {
+	case *object.Array:
+		if visited.Enter(obj) {
+			return 0
+		}
+		size := sizeOf(obj)
+		for _, el := range obj.Elements {
+			size += deepSize(el, visited)
+		}
+		return size
+	case *object.Hash:
+		if visited.Enter(obj) {
+			return 0
+		}
+		size := sizeOf(obj)
+		for _, pair := range obj.Pairs {
+			size += deepSize(pair.Key, visited) + deepSize(pair.Value, visited)
+		}
+		return size
+	default:
+		return sizeOf(obj)
+	}
+}
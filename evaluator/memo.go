@@ -0,0 +1,149 @@
+package evaluator
+
+import (
+	"github.com/rock619/monkey/ast"
+	"github.com/rock619/monkey/object"
+)
+
+// DefaultMaxMemoEntries is the limit on results cached by memoize used
+// when Options.MaxMemoEntries is zero.
+const DefaultMaxMemoEntries = 100000
+
+// effectBuiltins are the builtins whose calls make a function impure.
+var effectBuiltins = map[string]bool{
+	"puts": true, "print": true, "log": true, "readLine": true,
+	"exec": true, "glob": true, "now": true,
+}
+
+// A memoKey identifies an argument of a memoized call. It holds the type of
+// the argument as well as its hash key, since 5 and 5.0 share a key but
+// needn't give the same result.
+type memoKey struct {
+	typ object.ObjectType
+	key object.HashKey
+}
+
+// memoNode caches the results of calls whose arguments start with the
+// keys leading to it, one level per argument.
+type memoNode struct {
+	result object.Object
+	next   map[memoKey]*memoNode
+}
+
+func (n *memoNode) lookup(keys []memoKey) object.Object {
+	for _, key := range keys {
+		if n = n.next[key]; n == nil {
+			return nil
+		}
+	}
+	return n.result
+}
+
+func (n *memoNode) store(keys []memoKey, result object.Object) {
+	for _, key := range keys {
+		next := n.next[key]
+		if next == nil {
+			if n.next == nil {
+				n.next = make(map[memoKey]*memoNode)
+			}
+			next = &memoNode{}
+			n.next[key] = next
+		}
+		n = next
+	}
+	n.result = result
+}
+
+// memoize returns a builtin that calls fn, caching its result for every
+// combination of arguments. Arguments must be hashable, and errors aren't
+// cached. Once the results of all memoized functions reach MaxMemoEntries
+// new ones are no longer cached.
+func (e *Evaluator) memoize(args []object.Object) object.Object {
+	if len(args) != 1 {
+		return newError("wrong number of arguments. got=%d, want=1", len(args))
+	}
+	fn, ok := args[0].(*object.Function)
+	if !ok {
+		return newError("argument to `memoize` must be FUNCTION, got %s", args[0].Type())
+	}
+	if reason := impurity(fn); reason != "" {
+		return newError("cannot memoize a function that %s", reason)
+	}
+
+	limit := e.opts.MaxMemoEntries
+	if limit == 0 {
+		limit = DefaultMaxMemoEntries
+	}
+	root := &memoNode{}
+	return &object.Builtin{Fn: func(args ...object.Object) object.Object {
+		if len(args) != len(fn.Parameters) {
+			return newError("wrong number of arguments. got=%d, want=%d",
+				len(args), len(fn.Parameters))
+		}
+
+		keys := make([]memoKey, len(args))
+		for i, arg := range args {
+			key, ok := arg.(object.Hashable)
+			if !ok {
+				return newError("argument %d of a memoized function is unhashable: %s",
+					i+1, arg.Type())
+			}
+			keys[i] = memoKey{typ: arg.Type(), key: key.HashKey()}
+		}
+		if result := root.lookup(keys); result != nil {
+			return result
+		}
+
+		result := e.applyFunction(fn, args)
+		if result == nil {
+			result = NULL
+		}
+		if !isError(result) && e.memoEntries < limit {
+			root.store(keys, result)
+			e.memoEntries++
+		}
+		return result
+	}}
+}
+
+// impurity returns what makes calls of fn depend on more than their
+// arguments, or "" if nothing does as far as can be seen: assigning to a
+// variable defined outside fn, or calling a builtin for input or output.
+// It can't see what other functions fn calls do.
+func impurity(fn *object.Function) string {
+	locals := make(map[string]bool)
+	for _, param := range fn.Parameters {
+		locals[param.Value] = true
+	}
+	ast.Inspect(fn.Body, func(node ast.Node) bool {
+		switch n := node.(type) {
+		case *ast.LetStatement:
+			locals[n.Name.Value] = true
+		case *ast.FunctionLiteral:
+			for _, param := range n.Parameters {
+				locals[param.Value] = true
+			}
+		}
+		return true
+	})
+
+	var reason string
+	ast.Inspect(fn.Body, func(node ast.Node) bool {
+		if reason != "" {
+			return false
+		}
+		switch n := node.(type) {
+		case *ast.AssignExpression:
+			if !locals[n.Name.Value] {
+				reason = "assigns to " + n.Name.Value
+			}
+		case *ast.CallExpression:
+			if ident, ok := n.Function.(*ast.Identifier); ok &&
+				effectBuiltins[ident.Value] && !locals[ident.Value] {
+				reason = "calls " + ident.Value
+			}
+		}
+		return reason == ""
+	})
+	return reason
+}
@@ -0,0 +1,146 @@
+package evaluator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/rock619/monkey/lexer"
+	"github.com/rock619/monkey/lib"
+	"github.com/rock619/monkey/object"
+	"github.com/rock619/monkey/parser"
+)
+
+// importModule loads the module name, from the standard library or else
+// from ModulePath, and returns its exports: a hash of every top-level name
+// that doesn't start with "_". Each module is evaluated at most once per
+// Evaluator.
+func (e *Evaluator) importModule(name string) object.Object {
+	if module, ok := e.modules[name]; ok {
+		return module
+	}
+
+	env, err := e.loadModule(name)
+	if err != nil {
+		return err
+	}
+
+	module := &object.Hash{Pairs: make(map[object.HashKey]object.HashPair)}
+	for _, export := range exports(env) {
+		value, _ := env.Get(export)
+		key := &object.String{Value: export}
+		module.Pairs[key.HashKey()] = object.HashPair{Key: key, Value: value}
+	}
+
+	e.modules[name] = module
+	return module
+}
+
+// exports returns the names defined by a module: those that don't start
+// with "_".
+func exports(env *object.Environment) []string {
+	names := []string{}
+	for _, name := range env.Names() {
+		if !strings.HasPrefix(name, "_") {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// loadModule evaluates the module name in a new environment and returns
+// the environment.
+func (e *Evaluator) loadModule(name string) (*object.Environment, *object.Error) {
+	file := name + ".mky"
+	syntax, _ := parser.LookupDialect(parser.DefaultDialect)
+	src, ok := lib.Source(name)
+	if !ok {
+		path, err := FindModule(e.opts.ModulePath, name)
+		if err != nil {
+			return nil, newError("%s", err)
+		}
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return nil, newError("module %s: %s", name, err)
+		}
+		file, src, syntax = path, string(b), e.opts.Syntax
+	}
+
+	p := parser.NewWithOptions(lexer.NewFile(file, src), syntax)
+	program := p.ParseProgram()
+	if errors := p.ErrorList(); len(errors) != 0 {
+		return nil, newError("module %s: %s", name, parser.ErrorList(errors))
+	}
+
+	env := object.NewEnvironment()
+	if err, ok := e.eval(program, env).(*object.Error); ok {
+		return nil, err
+	}
+	return env, nil
+}
+
+// NewEnvironment returns an environment for a program. With the Prelude
+// option it is enclosed by an environment holding the prelude module, so
+// that programs can shadow the names of the prelude but not change them.
+// Loading the prelude counts toward no limits of the program.
+func (e *Evaluator) NewEnvironment() *object.Environment {
+	if !e.opts.Prelude {
+		return object.NewEnvironment()
+	}
+
+	if e.prelude == nil {
+		env, err := e.loadPrelude()
+		if err != nil {
+			// The prelude is embedded and tested, so this is a bug.
+			panic(err.Inspect())
+		}
+
+		e.prelude = object.NewEnvironment()
+		for _, export := range exports(env) {
+			value, _ := env.Get(export)
+			e.prelude.Set(export, value)
+		}
+		e.prelude.Freeze()
+	}
+	return object.NewEnclosedEnvironment(e.prelude)
+}
+
+// loadPrelude loads the prelude module with MaxSteps and MaxAllocBytes
+// lifted and without the context of the last evaluation, and leaves the
+// budgets of the program as they were.
+func (e *Evaluator) loadPrelude() (*object.Environment, *object.Error) {
+	opts, ctx, steps, allocated := e.opts, e.ctx, e.steps, e.allocated
+	defer func() {
+		e.opts, e.ctx, e.steps, e.allocated = opts, ctx, steps, allocated
+	}()
+
+	e.opts.MaxSteps, e.opts.MaxAllocBytes = 0, 0
+	e.ctx = context.Background()
+	return e.loadModule("prelude")
+}
+
+// FindModule returns the path of the file name.mky in the first of dirs
+// that has one. name may contain slashes to refer to a subdirectory, but
+// must not lead outside the directories.
+func FindModule(dirs []string, name string) (string, error) {
+	file := filepath.FromSlash(name) + ".mky"
+	if !filepath.IsLocal(file) {
+		return "", fmt.Errorf("invalid module name: %s", name)
+	}
+
+	for _, dir := range dirs {
+		path := filepath.Join(dir, file)
+		info, err := os.Stat(path)
+		if err == nil && info.Mode().IsRegular() {
+			return path, nil
+		}
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return "", fmt.Errorf("module %s: %w", name, err)
+		}
+	}
+	return "", fmt.Errorf("module not found: %s", name)
+}
@@ -0,0 +1,51 @@
+package evaluator
+
+import (
+	"bytes"
+	"io"
+)
+
+// lineWriter holds what is written to it and passes it on up to the last
+// newline, so that a slow writer such as a network connection gets whole
+// lines instead of every print. The first error writing out is kept and
+// returned from then on.
+type lineWriter struct {
+	w   io.Writer
+	buf []byte
+	err error
+}
+
+func (lw *lineWriter) Write(p []byte) (int, error) {
+	if lw.err != nil {
+		return 0, lw.err
+	}
+	lw.buf = append(lw.buf, p...)
+	if i := bytes.LastIndexByte(lw.buf, '\n'); i >= 0 {
+		lw.writeOut(i + 1)
+	}
+	return len(p), lw.err
+}
+
+// Flush writes out everything held, complete line or not.
+func (lw *lineWriter) Flush() error {
+	if lw.err == nil && len(lw.buf) > 0 {
+		lw.writeOut(len(lw.buf))
+	}
+	return lw.err
+}
+
+func (lw *lineWriter) writeOut(n int) {
+	_, lw.err = lw.w.Write(lw.buf[:n])
+	lw.buf = lw.buf[:copy(lw.buf, lw.buf[n:])]
+}
+
+// Flush writes out the output held with BufferOutput. Eval and Call flush
+// before they return, whether the evaluation succeeded, failed, was
+// interrupted or panicked, so output is lost only if the process dies
+// during one.
+func (e *Evaluator) Flush() error {
+	if lw, ok := e.out.(*lineWriter); ok {
+		return lw.Flush()
+	}
+	return nil
+}
@@ -0,0 +1,135 @@
+package evaluator
+
+import (
+	"github.com/rock619/monkey/ast"
+	"github.com/rock619/monkey/object"
+)
+
+// A Recording is the history of an evaluation: every statement run, in
+// order, with the variables each one changed. It lets a debugger step
+// backwards and show the values variables had at an earlier statement. Set
+// it as Options.Record; recording keeps every value ever bound alive, so
+// it suits small programs such as exercises.
+type Recording struct {
+	steps []Step
+	// changes holds every change in the order made, whereas the changes
+	// of a let statement come after those of the functions it calls.
+	changes []Change
+	// open holds the indexes of the steps whose statements are running,
+	// innermost last.
+	open []int
+}
+
+// A Step is a statement run during a recorded evaluation.
+type Step struct {
+	Statement ast.Statement
+	// Env is the environment the statement ran in.
+	Env *object.Environment
+	// Depth is the number of function calls in progress.
+	Depth int
+	// Changes are the bindings made by the statement itself, not by the
+	// statements of the functions it called.
+	Changes []Change
+}
+
+// A Change is a binding made by a let statement or an assignment.
+type Change struct {
+	// Env is the environment that holds the binding.
+	Env  *object.Environment
+	Name string
+	// Old is the previous value, or nil if the let statement declared Name
+	// in Env.
+	Old, New object.Object
+
+	// at is the number of steps begun when the change was made.
+	at int
+}
+
+// Steps returns the steps recorded so far, oldest first.
+func (r *Recording) Steps() []Step {
+	return append([]Step(nil), r.steps...)
+}
+
+// ValueAt returns the value name had in env just before step i ran, with i
+// equal to the number of steps for the current value. It reports false if
+// name wasn't bound then.
+func (r *Recording) ValueAt(i int, env *object.Environment, name string) (object.Object, bool) {
+	type binding struct {
+		env  *object.Environment
+		name string
+	}
+	// undone holds the values of the bindings changed since step i began,
+	// as they were before the first such change.
+	undone := make(map[binding]object.Object)
+	for c := len(r.changes) - 1; c >= 0 && r.changes[c].at > i; c-- {
+		undone[binding{r.changes[c].Env, r.changes[c].Name}] = r.changes[c].Old
+	}
+
+	for ; env != nil; env = env.Outer() {
+		if obj, ok := undone[binding{env, name}]; ok {
+			if obj == nil {
+				continue
+			}
+			return obj, true
+		}
+		if obj, ok := env.GetLocal(name); ok {
+			return obj, true
+		}
+	}
+	return nil, false
+}
+
+func (r *Recording) begin(stmt ast.Statement, env *object.Environment, depth int) {
+	r.open = append(r.open, len(r.steps))
+	r.steps = append(r.steps, Step{Statement: stmt, Env: env, Depth: depth})
+}
+
+func (r *Recording) end() {
+	r.open = r.open[:len(r.open)-1]
+}
+
+// change records the binding of name to val in env, which holds it, by
+// the innermost statement running.
+func (r *Recording) change(env *object.Environment, name string, old, val object.Object) {
+	if len(r.steps) == 0 {
+		return
+	}
+	step := len(r.steps) - 1
+	if len(r.open) > 0 {
+		step = r.open[len(r.open)-1]
+	}
+	c := Change{Env: env, Name: name, Old: old, New: val, at: len(r.steps)}
+	r.steps[step].Changes = append(r.steps[step].Changes, c)
+	r.changes = append(r.changes, c)
+}
+
+// evalStatement evaluates a statement of a program or block, recording it
+// with the Record option.
+func (e *Evaluator) evalStatement(stmt ast.Statement, env *object.Environment) object.Object {
+	if e.opts.Record == nil {
+		return e.eval(stmt, env)
+	}
+	e.opts.Record.begin(stmt, env, e.frames)
+	defer e.opts.Record.end()
+	return e.eval(stmt, env)
+}
+
+// assign assigns val to name like env.Assign, recording the change with
+// the Record option.
+func (e *Evaluator) assign(env *object.Environment, name string, val object.Object) error {
+	if e.opts.Record == nil {
+		return env.Assign(name, val)
+	}
+
+	declaring := env
+	old, ok := declaring.GetLocal(name)
+	for !ok && declaring.Outer() != nil {
+		declaring = declaring.Outer()
+		old, ok = declaring.GetLocal(name)
+	}
+	if err := env.Assign(name, val); err != nil {
+		return err
+	}
+	e.opts.Record.change(declaring, name, old, val)
+	return nil
+}
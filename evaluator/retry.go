@@ -0,0 +1,94 @@
+package evaluator
+
+import (
+	"time"
+
+	"github.com/rock619/monkey/object"
+)
+
+// backoff is the first argument of retryWithBackoff.
+type backoff struct {
+	// times is the maximum number of calls.
+	times int64
+	// delay is the wait before the second call, multiplied by factor for
+	// every call after it up to maxDelay, if that isn't zero.
+	delay    time.Duration
+	factor   int64
+	maxDelay time.Duration
+}
+
+var defaultBackoff = backoff{times: 3, delay: 100 * time.Millisecond, factor: 2}
+
+// parseBackoff reads a hash with the optional keys "times", "delay",
+// "factor" and "maxDelay", delays being durations or milliseconds.
+func parseBackoff(obj object.Object) (backoff, object.Object) {
+	b := defaultBackoff
+
+	hash, ok := obj.(*object.Hash)
+	if !ok {
+		return b, newError("options to `retryWithBackoff` must be HASH, got %s", obj.Type())
+	}
+	for _, pair := range hash.Pairs {
+		key, _ := pair.Key.(*object.String)
+		if key == nil {
+			return b, newError("unknown option to `retryWithBackoff`: %s", pair.Key.Inspect())
+		}
+		n, ok := pair.Value.(*object.Integer)
+		switch key.Value {
+		case "times", "factor":
+			if !ok || n.Value < 1 {
+				return b, newError("option %s must be a positive INTEGER, got %s",
+					key.Value, object.InspectElement(pair.Value))
+			}
+			if key.Value == "times" {
+				b.times = n.Value
+			} else {
+				b.factor = n.Value
+			}
+		case "delay", "maxDelay":
+			d, ok := toDuration(pair.Value)
+			if !ok || d < 0 {
+				return b, newError("option %s must be a non-negative INTEGER or DURATION, got %s",
+					key.Value, object.InspectElement(pair.Value))
+			}
+			if key.Value == "delay" {
+				b.delay = d
+			} else {
+				b.maxDelay = d
+			}
+		default:
+			return b, newError("unknown option to `retryWithBackoff`: %q", key.Value)
+		}
+	}
+	return b, nil
+}
+
+// retry calls fn, a function of no arguments, until it returns something
+// other than an error, at most b.times times, waiting between calls as b
+// says. It returns the last result. Interruptions aren't retried.
+func (e *Evaluator) retry(b backoff, fn object.Object) object.Object {
+	delay := b.delay
+	for i := int64(1); ; i++ {
+		result := e.applyFunction(fn, nil)
+		if result == nil {
+			return NULL
+		}
+		if !isError(result) || i == b.times || e.ctx.Err() != nil {
+			return result
+		}
+
+		if delay > 0 {
+			timer := time.NewTimer(delay)
+			select {
+			case <-e.ctx.Done():
+				timer.Stop()
+				return e.interrupted()
+			case <-timer.C:
+			}
+			delay *= time.Duration(b.factor)
+			if b.maxDelay > 0 {
+				delay = min(delay, b.maxDelay)
+			}
+		}
+	}
+}
@@ -0,0 +1,227 @@
+package evaluator
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/rock619/monkey/ast"
+	"github.com/rock619/monkey/object"
+	"github.com/rock619/monkey/parser"
+)
+
+// snapshotVersion is the version of the snapshot format written by
+// Snapshot. Restore rejects other versions.
+const snapshotVersion = 1
+
+type snapshot struct {
+	Version  int               `json:"version"`
+	Bindings []snapshotBinding `json:"bindings"`
+}
+
+type snapshotBinding struct {
+	Name  string        `json:"name"`
+	Value snapshotValue `json:"value"`
+}
+
+// A snapshotValue is a value of the type named by Type. Functions are
+// stored as their source, and floats as strings since JSON has no
+// infinities or NaN.
+type snapshotValue struct {
+	Type     object.ObjectType `json:"type"`
+	Int      int64             `json:"int,omitempty"`
+	String   string            `json:"string,omitempty"`
+	Bool     bool              `json:"bool,omitempty"`
+	Elements []snapshotValue   `json:"elements,omitempty"`
+	Pairs    []snapshotPair    `json:"pairs,omitempty"`
+	Source   string            `json:"source,omitempty"`
+}
+
+type snapshotPair struct {
+	Key   snapshotValue `json:"key"`
+	Value snapshotValue `json:"value"`
+}
+
+// Snapshot writes the names bound in env itself, not in the environments
+// enclosing it, with their values to w as JSON, so that Restore can bind
+// them again after a restart. Integers, strings, booleans, null, and
+// arrays and hashes of them can be saved, and so can functions defined in
+// env, which are saved as their source. Names bound to other values, such
+// as builtins, closures over local variables or arrays and hashes that
+// contain themselves, are skipped and returned.
+func Snapshot(w io.Writer, env *object.Environment) (skipped []string, err error) {
+	names := env.LocalNames()
+	sort.Strings(names)
+
+	s := snapshot{Version: snapshotVersion, Bindings: []snapshotBinding{}}
+	for _, name := range names {
+		obj, _ := env.Get(name)
+		value, ok := snapshotOf(obj, env, object.Visited{})
+		if !ok {
+			skipped = append(skipped, name)
+			continue
+		}
+		s.Bindings = append(s.Bindings, snapshotBinding{Name: name, Value: value})
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return skipped, enc.Encode(s)
+}
+
+func snapshotOf(obj object.Object, env *object.Environment, visited object.Visited) (snapshotValue, bool) {
+	v := snapshotValue{Type: obj.Type()}
+	switch obj := obj.(type) {
+	case *object.Integer:
+		v.Int = obj.Value
+	case *object.Float:
+		v.String = strconv.FormatFloat(obj.Value, 'g', -1, 64)
+	case *object.Time:
+		v.String = obj.Inspect()
+	case *object.Duration:
+		v.Int = int64(obj.Value)
+	case *object.String:
+		v.String = obj.Value
+	case *object.Boolean:
+		v.Bool = obj.Value
+	case *object.Null:
+	case *object.Array:
+		if visited.Enter(obj) {
+			return v, false
+		}
+		defer visited.Leave(obj)
+
+		v.Elements = make([]snapshotValue, len(obj.Elements))
+		for i, elem := range obj.Elements {
+			ev, ok := snapshotOf(elem, env, visited)
+			if !ok {
+				return v, false
+			}
+			v.Elements[i] = ev
+		}
+	case *object.Hash:
+		if visited.Enter(obj) {
+			return v, false
+		}
+		defer visited.Leave(obj)
+
+		pairs := make([]object.HashPair, 0, len(obj.Pairs))
+		for _, pair := range obj.Pairs {
+			pairs = append(pairs, pair)
+		}
+		sort.Slice(pairs, func(i, j int) bool {
+			return pairs[i].Key.Inspect() < pairs[j].Key.Inspect()
+		})
+		for _, pair := range pairs {
+			key, ok := snapshotOf(pair.Key, env, visited)
+			if !ok {
+				return v, false
+			}
+			value, ok := snapshotOf(pair.Value, env, visited)
+			if !ok {
+				return v, false
+			}
+			v.Pairs = append(v.Pairs, snapshotPair{Key: key, Value: value})
+		}
+	case *object.Function:
+		// Only functions closing over env itself can be recreated from
+		// their source by evaluating it in env.
+		if obj.Env != env {
+			return v, false
+		}
+		v.Source = ast.Source(&ast.FunctionLiteral{Parameters: obj.Parameters, Body: obj.Body})
+	default:
+		return v, false
+	}
+	return v, true
+}
+
+// Restore binds the names saved by Snapshot in env. Functions are evaluated
+// in env, so that they can refer to each other.
+func Restore(r io.Reader, env *object.Environment) error {
+	var s snapshot
+	if err := json.NewDecoder(r).Decode(&s); err != nil {
+		return fmt.Errorf("restore: %w", err)
+	}
+	if s.Version != snapshotVersion {
+		return fmt.Errorf("restore: unsupported snapshot version %d", s.Version)
+	}
+
+	for _, b := range s.Bindings {
+		obj, err := restoreValue(b.Value, env)
+		if err != nil {
+			return fmt.Errorf("restore %s: %w", b.Name, err)
+		}
+		env.Set(b.Name, obj)
+	}
+	return nil
+}
+
+func restoreValue(v snapshotValue, env *object.Environment) (object.Object, error) {
+	switch v.Type {
+	case object.INTEGER_OBJ:
+		return newInteger(v.Int), nil
+	case object.FLOAT_OBJ:
+		f, err := strconv.ParseFloat(v.String, 64)
+		if err != nil {
+			return nil, err
+		}
+		return &object.Float{Value: f}, nil
+	case object.DURATION_OBJ:
+		return &object.Duration{Value: time.Duration(v.Int)}, nil
+	case object.TIME_OBJ:
+		t, err := time.Parse(time.RFC3339Nano, v.String)
+		if err != nil {
+			return nil, err
+		}
+		return &object.Time{Value: t}, nil
+	case object.STRING_OBJ:
+		return &object.String{Value: v.String}, nil
+	case object.BOOLEAN_OBJ:
+		return nativeBoolToBooleanObject(v.Bool), nil
+	case object.NULL_OBJ:
+		return NULL, nil
+	case object.ARRAY_OBJ:
+		elements := make([]object.Object, len(v.Elements))
+		for i, ev := range v.Elements {
+			elem, err := restoreValue(ev, env)
+			if err != nil {
+				return nil, err
+			}
+			elements[i] = elem
+		}
+		return &object.Array{Elements: elements}, nil
+	case object.HASH_OBJ:
+		pairs := make(map[object.HashKey]object.HashPair, len(v.Pairs))
+		for _, pv := range v.Pairs {
+			key, err := restoreValue(pv.Key, env)
+			if err != nil {
+				return nil, err
+			}
+			hashable, ok := key.(object.Hashable)
+			if !ok {
+				return nil, fmt.Errorf("unusable as hash key: %s", key.Type())
+			}
+			value, err := restoreValue(pv.Value, env)
+			if err != nil {
+				return nil, err
+			}
+			pairs[hashable.HashKey()] = object.HashPair{Key: key, Value: value}
+		}
+		return &object.Hash{Pairs: pairs}, nil
+	case object.FUNCTION_OBJ:
+		exp, err := parser.ParseExpressionString(v.Source)
+		if err != nil {
+			return nil, err
+		}
+		fn, ok := exp.(*ast.FunctionLiteral)
+		if !ok {
+			return nil, fmt.Errorf("not a function: %s", v.Source)
+		}
+		return &object.Function{Parameters: fn.Parameters, Body: fn.Body, Env: env}, nil
+	}
+	return nil, fmt.Errorf("cannot restore a value of type %s", v.Type)
+}
@@ -0,0 +1,110 @@
+package evaluator
+
+import (
+	"maps"
+	"time"
+
+	"github.com/rock619/monkey/ast"
+	"github.com/rock619/monkey/object"
+)
+
+// Stats describes the resources used by an Evaluator, collected with the
+// Stats option.
+type Stats struct {
+	// Nodes is the number of syntax tree nodes evaluated.
+	Nodes int
+	// MaxDepth is the deepest nesting of function calls reached.
+	MaxDepth int
+	// Allocations counts the values of each type created by literals,
+	// operators and builtins. Shared values such as true, null and small
+	// integers aren't counted.
+	Allocations map[object.ObjectType]int
+	// ScriptTime and BuiltinTime divide the time spent in Eval and Call
+	// between script code and builtins, including the functions builtins
+	// call back.
+	ScriptTime  time.Duration
+	BuiltinTime time.Duration
+}
+
+// Stats returns the resources used by e since it was created or since the
+// last ResetStats. It returns the zero Stats without the Stats option.
+func (e *Evaluator) Stats() Stats {
+	if e.stats == nil {
+		return Stats{}
+	}
+	s := *e.stats
+	s.Allocations = maps.Clone(s.Allocations)
+	return s
+}
+
+// ResetStats clears the Stats of e, so that each run can be measured on its
+// own.
+func (e *Evaluator) ResetStats() {
+	if e.stats != nil {
+		e.stats = newStats()
+	}
+}
+
+func newStats() *Stats {
+	return &Stats{Allocations: make(map[object.ObjectType]int)}
+}
+
+// timeEval adds the time until the returned func is called to the script
+// time of e, less the time spent in builtins meanwhile.
+func (e *Evaluator) timeEval() func() {
+	if e.stats == nil || e.builtinDepth > 0 {
+		return func() {}
+	}
+	start, builtins := time.Now(), e.stats.BuiltinTime
+	return func() {
+		e.stats.ScriptTime += time.Since(start) - (e.stats.BuiltinTime - builtins)
+	}
+}
+
+// countNode records the evaluation of node to obj.
+func (e *Evaluator) countNode(node ast.Node, obj object.Object) {
+	e.stats.Nodes++
+	switch node.(type) {
+	case *ast.IntegerLiteral, *ast.FloatLiteral, *ast.DurationLiteral, *ast.StringLiteral, *ast.ArrayLiteral, *ast.HashLiteral,
+		*ast.FunctionLiteral, *ast.PrefixExpression, *ast.InfixExpression:
+		e.countAllocation(obj)
+	}
+}
+
+func (e *Evaluator) countAllocation(obj object.Object) {
+	switch obj := obj.(type) {
+	case nil, *object.Null, *object.Boolean:
+		return
+	case *object.Integer:
+		if obj.Value >= minCachedInteger && obj.Value <= maxCachedInteger &&
+			obj == &cachedIntegers[obj.Value-minCachedInteger] {
+			return
+		}
+	}
+	e.stats.Allocations[obj.Type()]++
+}
+
+// callBuiltin calls fn with args, timing it when stats are collected and
+// charging its result to MaxAllocBytes.
+func (e *Evaluator) callBuiltin(fn *object.Builtin, args []object.Object) object.Object {
+	if e.opts.MaxAllocBytes > 0 {
+		return e.charge(nil, e.timeBuiltin(fn, args))
+	}
+	return e.timeBuiltin(fn, args)
+}
+
+func (e *Evaluator) timeBuiltin(fn *object.Builtin, args []object.Object) object.Object {
+	if e.stats == nil {
+		return fn.Fn(args...)
+	}
+
+	e.builtinDepth++
+	start := time.Now()
+	result := fn.Fn(args...)
+	e.builtinDepth--
+	if e.builtinDepth == 0 {
+		e.stats.BuiltinTime += time.Since(start)
+	}
+	e.countAllocation(result)
+	return result
+}
@@ -0,0 +1,186 @@
+package evaluator
+
+import (
+	"time"
+
+	"github.com/rock619/monkey/object"
+)
+
+// parseTime reads a time in RFC 3339 format, or in the layout of Go's time
+// package given as the second argument.
+func parseTime(args []object.Object) object.Object {
+	if len(args) != 1 && len(args) != 2 {
+		return newError("wrong number of arguments. got=%d, want=1 or 2", len(args))
+	}
+	s, ok := args[0].(*object.String)
+	if !ok {
+		return newError("first argument to `parseTime` must be STRING, got %s", args[0].Type())
+	}
+	layout, err := timeLayout("parseTime", args)
+	if err != nil {
+		return err
+	}
+
+	t, parseErr := time.Parse(layout, s.Value)
+	if parseErr != nil {
+		return newError("parseTime: %s", parseErr)
+	}
+	return &object.Time{Value: t}
+}
+
+// formatTime formats a time in RFC 3339 format, or in the layout of Go's
+// time package given as the second argument.
+func formatTime(args []object.Object) object.Object {
+	if len(args) != 1 && len(args) != 2 {
+		return newError("wrong number of arguments. got=%d, want=1 or 2", len(args))
+	}
+	t, ok := args[0].(*object.Time)
+	if !ok {
+		return newError("first argument to `formatTime` must be TIME, got %s", args[0].Type())
+	}
+	layout, err := timeLayout("formatTime", args)
+	if err != nil {
+		return err
+	}
+	return &object.String{Value: t.Value.Format(layout)}
+}
+
+func timeLayout(name string, args []object.Object) (string, *object.Error) {
+	if len(args) < 2 {
+		return time.RFC3339Nano, nil
+	}
+	layout, ok := args[1].(*object.String)
+	if !ok {
+		return "", newError("second argument to `%s` must be STRING, got %s", name, args[1].Type())
+	}
+	return layout.Value, nil
+}
+
+// addDuration returns a time a duration or a number of milliseconds after
+// another, or before it if negative.
+func addDuration(args []object.Object) object.Object {
+	if len(args) != 2 {
+		return newError("wrong number of arguments. got=%d, want=2", len(args))
+	}
+	t, ok := args[0].(*object.Time)
+	if !ok {
+		return newError("first argument to `addDuration` must be TIME, got %s", args[0].Type())
+	}
+	d, ok := toDuration(args[1])
+	if !ok {
+		return newError("second argument to `addDuration` must be INTEGER or DURATION, got %s", args[1].Type())
+	}
+	return &object.Time{Value: t.Value.Add(d)}
+}
+
+// timeOperands reports whether operator applied to left and right is for
+// evalTimeInfixExpression: any operator on two times or two durations, and
+// arithmetic on a time or duration and a duration or integer. Comparing
+// values of different types is left to evalInfixExpression.
+func timeOperands(operator string, left, right object.Object) bool {
+	if !isTemporal(left) && !isTemporal(right) {
+		return false
+	}
+	if left.Type() == right.Type() {
+		return true
+	}
+	switch operator {
+	case "+", "-", "*", "/":
+		return (isTemporal(left) || left.Type() == object.INTEGER_OBJ) &&
+			(isTemporal(right) || right.Type() == object.INTEGER_OBJ)
+	}
+	return false
+}
+
+func isTemporal(obj object.Object) bool {
+	return obj.Type() == object.TIME_OBJ || obj.Type() == object.DURATION_OBJ
+}
+
+// evalTimeInfixExpression does arithmetic on times and durations: the
+// difference of two times is a duration, a time plus or minus a duration
+// is a time, and durations add up and scale by integers. Times and
+// durations compare with their own kind.
+func evalTimeInfixExpression(operator string, left, right object.Object) object.Object {
+	switch left := left.(type) {
+	case *object.Time:
+		switch right := right.(type) {
+		case *object.Time:
+			a, b := left.Value, right.Value
+			switch operator {
+			case "-":
+				return &object.Duration{Value: a.Sub(b)}
+			case "<":
+				return nativeBoolToBooleanObject(a.Before(b))
+			case ">":
+				return nativeBoolToBooleanObject(a.After(b))
+			case "==":
+				return nativeBoolToBooleanObject(a.Equal(b))
+			case "!=":
+				return nativeBoolToBooleanObject(!a.Equal(b))
+			}
+		case *object.Duration:
+			switch operator {
+			case "+":
+				return &object.Time{Value: left.Value.Add(right.Value)}
+			case "-":
+				return &object.Time{Value: left.Value.Add(-right.Value)}
+			}
+		}
+	case *object.Duration:
+		switch right := right.(type) {
+		case *object.Time:
+			if operator == "+" {
+				return &object.Time{Value: right.Value.Add(left.Value)}
+			}
+		case *object.Duration:
+			a, b := left.Value, right.Value
+			switch operator {
+			case "+":
+				return &object.Duration{Value: a + b}
+			case "-":
+				return &object.Duration{Value: a - b}
+			case "/":
+				if b == 0 {
+					return newError("division by zero: %s / %s", a, b)
+				}
+				return newInteger(int64(a / b))
+			case "<":
+				return nativeBoolToBooleanObject(a < b)
+			case ">":
+				return nativeBoolToBooleanObject(a > b)
+			case "==":
+				return nativeBoolToBooleanObject(a == b)
+			case "!=":
+				return nativeBoolToBooleanObject(a != b)
+			}
+		case *object.Integer:
+			switch operator {
+			case "*":
+				return &object.Duration{Value: left.Value * time.Duration(right.Value)}
+			case "/":
+				if right.Value == 0 {
+					return newError("division by zero: %s / 0", left.Value)
+				}
+				return &object.Duration{Value: left.Value / time.Duration(right.Value)}
+			}
+		}
+	case *object.Integer:
+		if right, ok := right.(*object.Duration); ok && operator == "*" {
+			return &object.Duration{Value: time.Duration(left.Value) * right.Value}
+		}
+	}
+	return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+}
+
+// toDuration returns a duration given as a DURATION or as an INTEGER
+// number of milliseconds.
+func toDuration(obj object.Object) (time.Duration, bool) {
+	switch obj := obj.(type) {
+	case *object.Duration:
+		return obj.Value, true
+	case *object.Integer:
+		return time.Duration(obj.Value) * time.Millisecond, true
+	default:
+		return 0, false
+	}
+}
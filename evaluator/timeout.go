@@ -0,0 +1,60 @@
+package evaluator
+
+import (
+	"context"
+	"errors"
+
+	"github.com/rock619/monkey/object"
+)
+
+// withTimeout calls a function of no arguments under a context that is
+// done after a duration or a number of milliseconds, or when the evaluation
+// as a whole is interrupted. It returns the result of the function, or a
+// timeout error if the time ran out first.
+func (e *Evaluator) withTimeout(args []object.Object) object.Object {
+	if len(args) != 2 {
+		return newError("wrong number of arguments. got=%d, want=2",
+			len(args))
+	}
+	timeout, ok := toDuration(args[0])
+	if !ok || timeout < 0 {
+		return newError("first argument to `withTimeout` must be a non-negative INTEGER or DURATION, got %s",
+			args[0].Inspect())
+	}
+	if err := checkCallback("withTimeout", args[1], 0); err != nil {
+		return err
+	}
+
+	parent := e.ctx
+	ctx, cancel := context.WithTimeout(parent, timeout)
+	defer cancel()
+	e.ctx = ctx
+	defer func() { e.ctx = parent }()
+
+	result := e.applyFunction(args[1], nil)
+	if isError(result) && errors.Is(ctx.Err(), context.DeadlineExceeded) && parent.Err() == nil {
+		return newError("timeout after %s", timeout)
+	}
+	if result == nil {
+		return NULL
+	}
+	return result
+}
+
+// checkCallback returns an error unless fn, the last argument to the
+// builtin name, is a builtin or a function of params parameters.
+func checkCallback(name string, fn object.Object, params int) *object.Error {
+	switch fn := fn.(type) {
+	case *object.Builtin:
+		return nil
+	case *object.Function:
+		if len(fn.Parameters) == params {
+			return nil
+		}
+		return newError("function passed to `%s` must take %d arguments, takes %d",
+			name, params, len(fn.Parameters))
+	default:
+		return newError("last argument to `%s` must be FUNCTION, got %s",
+			name, fn.Type())
+	}
+}
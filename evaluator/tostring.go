@@ -0,0 +1,134 @@
+package evaluator
+
+import (
+	"context"
+	"strings"
+
+	"github.com/rock619/monkey/object"
+)
+
+// ToStringKey is the key under which a hash may hold a function, taking
+// the hash itself as its only argument, that returns how the hash is shown
+// by puts, print and log and when it's added to a string, also as an
+// element of an array or hash.
+const ToStringKey = "toString"
+
+// ToString returns obj as puts prints it, calling the toString functions
+// of obj and of the values it holds. Like Call, it stops with an error
+// object as soon as ctx is done.
+func (e *Evaluator) ToString(ctx context.Context, obj object.Object) (string, *object.Error) {
+	e.ctx = ctx
+	defer e.Flush()
+	defer e.timeEval()()
+	s, err := e.toString(obj)
+	if err != nil {
+		return "", err.(*object.Error)
+	}
+	return s, nil
+}
+
+// toString returns obj as text: the result of its toString function if it
+// is a hash that has one, its Inspect output otherwise, except that the
+// elements of arrays and hashes are shown by their toString functions too.
+// The error is an error object to return instead.
+func (e *Evaluator) toString(obj object.Object) (string, object.Object) {
+	return e.display(obj, false, object.Visited{})
+}
+
+// display is toString for obj, which is an element of an array or hash if
+// nested is true. Nested values without a toString function appear as
+// object.InspectElement shows them.
+func (e *Evaluator) display(obj object.Object, nested bool, visited object.Visited) (string, object.Object) {
+	if fn, ok := toStringFunction(obj); ok {
+		result := e.applyFunction(fn, []object.Object{obj})
+		switch result := result.(type) {
+		case *object.String:
+			return result.Value, nil
+		case *object.Error:
+			return "", result
+		default:
+			return "", newError("%s must return STRING, got %s", ToStringKey, typeOf(result))
+		}
+	}
+
+	switch obj := obj.(type) {
+	case *object.Array:
+		if visited.Enter(obj) {
+			return "[...]", nil
+		}
+		defer visited.Leave(obj)
+
+		elements := make([]string, len(obj.Elements))
+		for i, el := range obj.Elements {
+			s, err := e.display(el, true, visited)
+			if err != nil {
+				return "", err
+			}
+			elements[i] = s
+		}
+		return "[" + strings.Join(elements, ", ") + "]", nil
+	case *object.Hash:
+		if visited.Enter(obj) {
+			return "{...}", nil
+		}
+		defer visited.Leave(obj)
+
+		pairs := make([]string, 0, len(obj.Pairs))
+		for _, pair := range obj.SortedPairs() {
+			key, err := e.display(pair.Key, true, visited)
+			if err != nil {
+				return "", err
+			}
+			value, err := e.display(pair.Value, true, visited)
+			if err != nil {
+				return "", err
+			}
+			pairs = append(pairs, key+": "+value)
+		}
+		return "{" + strings.Join(pairs, ", ") + "}", nil
+	}
+
+	if nested {
+		return object.InspectElement(obj), nil
+	}
+	return obj.Inspect(), nil
+}
+
+// toStringFunction returns the toString function of obj, if it has one.
+func toStringFunction(obj object.Object) (object.Object, bool) {
+	hash, ok := obj.(*object.Hash)
+	if !ok {
+		return nil, false
+	}
+	pair, ok := hash.Pairs[(&object.String{Value: ToStringKey}).HashKey()]
+	if !ok {
+		return nil, false
+	}
+	switch fn := pair.Value.(type) {
+	case *object.Function:
+		return fn, len(fn.Parameters) == 1
+	case *object.Builtin:
+		return fn, true
+	}
+	return nil, false
+}
+
+// toStrings is toString for a list of objects.
+func (e *Evaluator) toStrings(objs []object.Object) ([]string, object.Object) {
+	s := make([]string, len(objs))
+	for i, obj := range objs {
+		str, err := e.toString(obj)
+		if err != nil {
+			return nil, err
+		}
+		s[i] = str
+	}
+	return s, nil
+}
+
+func typeOf(obj object.Object) object.ObjectType {
+	if obj == nil {
+		return object.NULL_OBJ
+	}
+	return obj.Type()
+}
@@ -0,0 +1,89 @@
+package evaluator
+
+import (
+	"encoding/json"
+	"io"
+	"time"
+
+	"github.com/rock619/monkey/object"
+)
+
+// A Trace records a span for every call of a function or builtin. Set it
+// as Options.Trace and export it with WriteJSON for viewers such as
+// chrome://tracing and Perfetto, which show the spans as a flame graph.
+type Trace struct {
+	start  time.Time
+	events []traceEvent
+}
+
+// traceEvent is a complete event of the Trace Event Format.
+type traceEvent struct {
+	Name string `json:"name"`
+	Cat  string `json:"cat"`
+	Ph   string `json:"ph"`
+	// TS and Dur are in microseconds.
+	TS   float64           `json:"ts"`
+	Dur  float64           `json:"dur"`
+	PID  int               `json:"pid"`
+	TID  int               `json:"tid"`
+	Args map[string]string `json:"args,omitempty"`
+}
+
+// Len returns the number of spans recorded.
+func (t *Trace) Len() int {
+	return len(t.events)
+}
+
+// WriteJSON writes the spans recorded so far as a JSON trace file.
+func (t *Trace) WriteJSON(w io.Writer) error {
+	events := t.events
+	if events == nil {
+		events = []traceEvent{}
+	}
+	return json.NewEncoder(w).Encode(struct {
+		TraceEvents     []traceEvent `json:"traceEvents"`
+		DisplayTimeUnit string       `json:"displayTimeUnit"`
+	}{events, "ms"})
+}
+
+func (t *Trace) since(start time.Time) float64 {
+	return float64(start.Sub(t.start).Nanoseconds()) / 1e3
+}
+
+// traceCall calls fn with args, recording a span named after the callee
+// of the call expression being evaluated, if any.
+func (e *Evaluator) traceCall(fn object.Object, args []object.Object) object.Object {
+	t := e.opts.Trace
+	callee := e.callee
+	e.callee = nil
+
+	event := traceEvent{Ph: "X", PID: 1, TID: 1}
+	switch fn := fn.(type) {
+	case *object.Function:
+		event.Cat = "function"
+		event.Name = "fn@" + fn.Body.Pos().String()
+	case *object.Builtin:
+		event.Cat = "builtin"
+		event.Name = "builtin"
+	}
+	if callee != nil {
+		event.Name = callee.String()
+		event.Args = map[string]string{"pos": e.callPos.String()}
+	}
+
+	start := time.Now()
+	if t.start.IsZero() {
+		t.start = start
+	}
+	result := e.apply(fn, args)
+	event.TS = t.since(start)
+	event.Dur = float64(time.Since(start).Nanoseconds()) / 1e3
+	if err, ok := result.(*object.Error); ok {
+		if event.Args == nil {
+			event.Args = map[string]string{}
+		}
+		event.Args["error"] = err.Message
+	}
+	t.events = append(t.events, event)
+	return result
+}
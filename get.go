@@ -0,0 +1,273 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	// vendorDir is the directory of a project that monkey get copies
+	// packages into.
+	vendorDir = "vendor"
+	// lockFile records the packages in vendorDir and their commits, which
+	// monkey get without arguments vendors again.
+	lockFile = "monkey.lock"
+)
+
+// A lock is an entry of the lock file.
+type lock struct {
+	path, version, commit string
+}
+
+// get vendors the packages in args, given as host/owner/repo[@version],
+// into the project in the current directory. A package is cloned with git
+// from https://host/owner/repo, and its .mky files are copied to
+// vendor/host/owner/repo, from where they can be imported as
+// "host/owner/repo/module". The commit of every package is recorded in
+// monkey.lock. Without args, every package in monkey.lock is vendored
+// again at its recorded commit, so that a fresh checkout of the project
+// gets exactly the code it was written against.
+func get(args []string) int {
+	locks, err := readLocks(lockFile)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if len(args) == 0 {
+		if len(locks) == 0 {
+			fmt.Fprintln(os.Stderr, "usage: monkey get [package[@version]...]")
+			return 2
+		}
+		return restoreAll(locks)
+	}
+
+	status := 0
+	for _, arg := range args {
+		l, err := fetch(arg)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", arg, err)
+			status = 1
+			continue
+		}
+		locks[l.path] = l
+		fmt.Printf("%s %s %s\n", l.path, l.version, l.commit)
+	}
+
+	if err := writeLocks(lockFile, locks); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return status
+}
+
+// fetch clones the package arg and replaces its vendored copy.
+func fetch(arg string) (lock, error) {
+	pkg, version, _ := strings.Cut(arg, "@")
+	if err := checkPackagePath(pkg); err != nil {
+		return lock{}, err
+	}
+
+	tmp, err := os.MkdirTemp("", "monkey-get-")
+	if err != nil {
+		return lock{}, err
+	}
+	defer os.RemoveAll(tmp)
+
+	clone := []string{"clone", "--quiet", "--depth", "1"}
+	if version != "" {
+		clone = append(clone, "--branch", version)
+	}
+	if _, err := git("", append(clone, "https://"+pkg, tmp)...); err != nil {
+		return lock{}, err
+	}
+	commit, err := git(tmp, "rev-parse", "HEAD")
+	if err != nil {
+		return lock{}, err
+	}
+
+	if err := replaceVendored(pkg, tmp); err != nil {
+		return lock{}, err
+	}
+
+	if version == "" {
+		version = "latest"
+	}
+	return lock{path: pkg, version: version, commit: commit}, nil
+}
+
+// restoreAll vendors every package in locks at its recorded commit.
+func restoreAll(locks map[string]lock) int {
+	paths := make([]string, 0, len(locks))
+	for p := range locks {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+
+	status := 0
+	for _, p := range paths {
+		l := locks[p]
+		if err := restore(l); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", l.path, err)
+			status = 1
+			continue
+		}
+		fmt.Printf("%s %s %s\n", l.path, l.version, l.commit)
+	}
+	return status
+}
+
+// restore clones the package of l and replaces its vendored copy with the
+// recorded commit.
+func restore(l lock) error {
+	tmp, err := os.MkdirTemp("", "monkey-get-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+
+	if _, err := git("", "clone", "--quiet", "--no-checkout", "https://"+l.path, tmp); err != nil {
+		return err
+	}
+	if _, err := git(tmp, "checkout", "--quiet", "--detach", l.commit); err != nil {
+		return err
+	}
+	return replaceVendored(l.path, tmp)
+}
+
+// replaceVendored replaces the vendored copy of pkg with the modules of
+// the clone in dir.
+func replaceVendored(pkg, dir string) error {
+	dest := filepath.Join(vendorDir, filepath.FromSlash(pkg))
+	if err := os.RemoveAll(dest); err != nil {
+		return err
+	}
+	return copyModules(dir, dest)
+}
+
+// checkPackagePath reports whether pkg has the form host/owner/repo.
+func checkPackagePath(pkg string) error {
+	elems := strings.Split(pkg, "/")
+	if len(elems) != 3 || path.Clean(pkg) != pkg || !filepath.IsLocal(filepath.FromSlash(pkg)) {
+		return errors.New("package path must be of the form host/owner/repo")
+	}
+	for _, elem := range elems {
+		if elem == "" || strings.HasPrefix(elem, "-") || strings.HasPrefix(elem, ".") {
+			return errors.New("package path must be of the form host/owner/repo")
+		}
+	}
+	return nil
+}
+
+// isCommit reports whether s is a full git commit hash, in SHA-1 or
+// SHA-256.
+func isCommit(s string) bool {
+	if len(s) != 40 && len(s) != 64 {
+		return false
+	}
+	for _, c := range s {
+		if !strings.ContainsRune("0123456789abcdef", c) {
+			return false
+		}
+	}
+	return true
+}
+
+// git runs git in dir and returns its trimmed output.
+func git(dir string, args ...string) (string, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("git %s: %s", args[0], msg)
+		}
+		return "", fmt.Errorf("git %s: %w", args[0], err)
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+// copyModules copies the .mky files under src to dest, keeping their
+// relative paths and skipping hidden directories such as .git.
+func copyModules(src, dest string) error {
+	return filepath.WalkDir(src, func(file string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if file != src && strings.HasPrefix(d.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if filepath.Ext(file) != ".mky" || !d.Type().IsRegular() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(src, file)
+		if err != nil {
+			return err
+		}
+		b, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dest, rel)
+		if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+			return err
+		}
+		return os.WriteFile(target, b, 0o644)
+	})
+}
+
+// readLocks reads the lock file name, which has a line "path version
+// commit" for every package. A missing file has no entries.
+func readLocks(name string) (map[string]lock, error) {
+	locks := make(map[string]lock)
+	f, err := os.Open(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return locks, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	s := bufio.NewScanner(f)
+	for line := 1; s.Scan(); line++ {
+		fields := strings.Fields(s.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 3 || checkPackagePath(fields[0]) != nil || !isCommit(fields[2]) {
+			return nil, fmt.Errorf("%s:%d: malformed entry", name, line)
+		}
+		locks[fields[0]] = lock{path: fields[0], version: fields[1], commit: fields[2]}
+	}
+	return locks, s.Err()
+}
+
+// writeLocks writes locks to the lock file name, sorted by path.
+func writeLocks(name string, locks map[string]lock) error {
+	paths := make([]string, 0, len(locks))
+	for p := range locks {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+
+	var b strings.Builder
+	for _, p := range paths {
+		l := locks[p]
+		fmt.Fprintf(&b, "%s %s %s\n", l.path, l.version, l.commit)
+	}
+	return os.WriteFile(name, []byte(b.String()), 0o644)
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestCheckPackagePath(t *testing.T) {
+	tests := []struct {
+		pkg   string
+		valid bool
+	}{
+		{"github.com/owner/repo", true},
+		{"example.com/a/b-c.d", true},
+		{"github.com/owner", false},
+		{"github.com/owner/repo/sub", false},
+		{"github.com//repo", false},
+		{"github.com/owner/../repo", false},
+		{"/github.com/owner/repo", false},
+		{"github.com/owner/repo/", false},
+		{"github.com/-owner/repo", false},
+		{"github.com/owner/.repo", false},
+		{"", false},
+	}
+
+	for _, tt := range tests {
+		if err := checkPackagePath(tt.pkg); (err == nil) != tt.valid {
+			t.Errorf("checkPackagePath(%q) = %v, want valid=%t", tt.pkg, err, tt.valid)
+		}
+	}
+}
+
+func TestLocks(t *testing.T) {
+	name := filepath.Join(t.TempDir(), lockFile)
+
+	locks, err := readLocks(name)
+	if err != nil || len(locks) != 0 {
+		t.Fatalf("readLocks of a missing file = %v, %v", locks, err)
+	}
+
+	locks = map[string]lock{
+		"github.com/b/b": {"github.com/b/b", "latest", "0123456789abcdef0123456789abcdef01234567"},
+		"github.com/a/a": {"github.com/a/a", "v1.0.0", "89abcdef0123456789abcdef0123456789abcdef"},
+	}
+	if err := writeLocks(name, locks); err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "github.com/a/a v1.0.0 89abcdef0123456789abcdef0123456789abcdef\n" +
+		"github.com/b/b latest 0123456789abcdef0123456789abcdef01234567\n"
+	if string(b) != expected {
+		t.Errorf("wrong lock file. got=%q, want=%q", b, expected)
+	}
+
+	read, err := readLocks(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(read, locks) {
+		t.Errorf("readLocks = %v, want %v", read, locks)
+	}
+}
+
+func TestReadLocksErrors(t *testing.T) {
+	tests := []string{
+		"github.com/a/a v1\n",
+		"github.com/a/a v1 0123 extra\n",
+		"github.com/a v1 0123456789abcdef0123456789abcdef01234567\n",
+		"github.com/a/a v1 --upload-pack=evil\n",
+		"\ngithub.com/a/a v1 0123456789ABCDEF0123456789ABCDEF01234567\n",
+	}
+
+	for _, input := range tests {
+		name := filepath.Join(t.TempDir(), lockFile)
+		if err := os.WriteFile(name, []byte(input), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := readLocks(name); err == nil {
+			t.Errorf("readLocks(%q): no error", input)
+		}
+	}
+}
+
+// TestRestore vendors a package at the commit recorded for it, rather than
+// the latest one. Git is pointed at a local repository instead of https.
+func TestRestore(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not found")
+	}
+
+	parent := t.TempDir()
+	repo := filepath.Join(parent, "repo")
+	if err := os.Mkdir(repo, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	gitIn := func(args ...string) string {
+		t.Helper()
+		out, err := git(repo, args...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return out
+	}
+	commit := func(content string) string {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(repo, "m.mky"), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		gitIn("add", "m.mky")
+		gitIn("-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "--quiet", "-m", content)
+		return gitIn("rev-parse", "HEAD")
+	}
+	gitIn("init", "--quiet")
+	first := commit("let v = 1;")
+	commit("let v = 2;")
+
+	t.Setenv("GIT_CONFIG_COUNT", "1")
+	t.Setenv("GIT_CONFIG_KEY_0", "url."+parent+"/.insteadOf")
+	t.Setenv("GIT_CONFIG_VALUE_0", "https://example.com/owner/")
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	l := lock{path: "example.com/owner/repo", version: "latest", commit: first}
+	if err := restore(l); err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(filepath.Join(vendorDir, "example.com", "owner", "repo", "m.mky"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "let v = 1;" {
+		t.Errorf("wrong vendored module %q", b)
+	}
+}
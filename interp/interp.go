@@ -0,0 +1,145 @@
+// Package interp runs Monkey source with presets for embedding, such as a
+// sandbox for scripts from untrusted users in playgrounds and services.
+package interp
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/rock619/monkey/evaluator"
+	"github.com/rock619/monkey/lexer"
+	"github.com/rock619/monkey/object"
+	"github.com/rock619/monkey/parser"
+	"github.com/rock619/monkey/token"
+)
+
+// Limits bound the resources a sandboxed script may use on each run. Zero
+// fields take the value of DefaultLimits; there is no way to lift a limit.
+type Limits struct {
+	// Steps is the number of statements a run may execute.
+	Steps int
+	// Memory is the total size in bytes of the strings, arrays and hashes
+	// a run may create. See evaluator.Options.MaxAllocBytes.
+	Memory int64
+	// Frames is how deeply function calls may nest.
+	Frames int
+	// Timeout is how long a run may take.
+	Timeout time.Duration
+	// Source is the size of a script in bytes.
+	Source int
+}
+
+// DefaultLimits suit short programs such as exercises and examples.
+var DefaultLimits = Limits{
+	Steps:   1_000_000,
+	Memory:  64 << 20,
+	Frames:  1000,
+	Timeout: 5 * time.Second,
+	Source:  64 << 10,
+}
+
+func (l Limits) orDefault() Limits {
+	if l.Steps <= 0 {
+		l.Steps = DefaultLimits.Steps
+	}
+	if l.Memory <= 0 {
+		l.Memory = DefaultLimits.Memory
+	}
+	if l.Frames <= 0 {
+		l.Frames = DefaultLimits.Frames
+	}
+	if l.Timeout <= 0 {
+		l.Timeout = DefaultLimits.Timeout
+	}
+	if l.Source <= 0 {
+		l.Source = DefaultLimits.Source
+	}
+	return l
+}
+
+// A Sandbox runs scripts one after another in a shared environment, so
+// that later scripts see what earlier ones defined, as in a notebook or a
+// REPL. Scripts get no capabilities, so they can't touch files, the network
+// or other programs or read the clock, and they read an empty standard
+// input. What they print is captured instead of written to the standard
+// output. Since the language has no other source of randomness, a sequence
+// of scripts always gives the same results.
+//
+// A Sandbox must not be used by more than one goroutine at a time.
+type Sandbox struct {
+	limits Limits
+	syntax parser.Options
+	env    *object.Environment
+}
+
+// A Result is the outcome of a run.
+type Result struct {
+	// Value is the value of the script, or nil if it failed.
+	Value object.Object
+	// Output is what the script printed, including before it failed.
+	Output string
+}
+
+// NewSandboxed returns a Sandbox that enforces limits on every run.
+func NewSandboxed(limits Limits) *Sandbox {
+	limits = limits.orDefault()
+	syntax, _ := parser.LookupDialect(parser.DefaultDialect)
+	syntax.MaxBytes = limits.Source
+
+	s := &Sandbox{limits: limits, syntax: syntax}
+	s.env = s.evaluator(&bytes.Buffer{}).NewEnvironment()
+	return s
+}
+
+// evaluator returns a new Evaluator writing to out, so that the budgets of
+// Limits apply to each run on its own.
+func (s *Sandbox) evaluator(out *bytes.Buffer) *evaluator.Evaluator {
+	return evaluator.New(evaluator.Options{
+		Prelude:       true,
+		MaxFrames:     s.limits.Frames,
+		MaxSteps:      s.limits.Steps,
+		MaxAllocBytes: s.limits.Memory,
+		Syntax:        s.syntax,
+		IO:            evaluator.IOStreams{In: strings.NewReader(""), Out: out, Err: out},
+	})
+}
+
+// Run parses and evaluates src. name is used as the file name in the
+// positions of errors. A syntax error is returned as a parser.ErrorList and
+// a runtime error, including an exceeded limit, as a *parser.Error. So is
+// a panic during evaluation, which would otherwise take down the host.
+func (s *Sandbox) Run(ctx context.Context, name, src string) (result Result, err error) {
+	p := parser.NewWithOptions(lexer.NewFile(name, src), s.syntax)
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return Result{}, parser.ErrorList(p.ErrorList())
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.limits.Timeout)
+	defer cancel()
+
+	var out bytes.Buffer
+	defer func() {
+		if r := recover(); r != nil {
+			result = Result{Output: out.String()}
+			err = &parser.Error{
+				Pos: token.Position{Filename: name},
+				Msg: fmt.Sprintf("panic during evaluation: %v", r),
+			}
+		}
+	}()
+
+	value := s.evaluator(&out).Eval(ctx, program, s.env)
+	result = Result{Output: out.String()}
+	if err, ok := value.(*object.Error); ok {
+		return result, &parser.Error{Pos: err.Pos, Msg: err.Message}
+	}
+	if value == nil {
+		value = object.NullValue
+	}
+	result.Value = value
+	return result, nil
+}
@@ -0,0 +1,154 @@
+package interp
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rock619/monkey/object"
+	"github.com/rock619/monkey/parser"
+)
+
+func TestSandbox(t *testing.T) {
+	s := NewSandboxed(Limits{})
+	ctx := context.Background()
+
+	result, err := s.Run(ctx, "a.mky", `let double = fn(x) { x * 2 }; puts("hello"); double(2)`)
+	if err != nil {
+		t.Fatalf("Run: %s", err)
+	}
+	if result.Value.Inspect() != "4" || result.Output != "hello\n" {
+		t.Errorf("got %+v", result)
+	}
+
+	// Later scripts see earlier definitions and the prelude.
+	result, err = s.Run(ctx, "b.mky", `map([1, 2], double)`)
+	if err != nil {
+		t.Fatalf("Run: %s", err)
+	}
+	if result.Value.Inspect() != "[2, 4]" || result.Output != "" {
+		t.Errorf("got %+v", result)
+	}
+
+	result, err = s.Run(ctx, "c.mky", `let x = 1; puts("before"); exec("ls", [])`)
+	if err == nil || err.Error() != "c.mky:1:32: `exec` requires the exec capability" {
+		t.Errorf("wrong error: %v", err)
+	}
+	if result.Value != nil || result.Output != "before\n" {
+		t.Errorf("got %+v", result)
+	}
+
+	_, err = s.Run(ctx, "d.mky", `let = 1`)
+	if _, ok := err.(parser.ErrorList); !ok {
+		t.Errorf("wrong error for a syntax error: %#v", err)
+	}
+}
+
+func TestSandboxDeterministic(t *testing.T) {
+	src := `let h = {"c": 3, "a": 1, "b": 2, 1: [{"y": 2, "x": 1}]}; puts(h); h`
+	expected := `{1: [{"x": 1, "y": 2}], "a": 1, "b": 2, "c": 3}`
+
+	for i := 0; i < 10; i++ {
+		result, err := NewSandboxed(Limits{}).Run(context.Background(), "a.mky", src)
+		if err != nil {
+			t.Fatalf("Run: %s", err)
+		}
+		if result.Output != expected+"\n" || result.Value.Inspect() != expected {
+			t.Fatalf("run %d: got %+v, want %s", i, result, expected)
+		}
+	}
+}
+
+func TestSandboxPreludeLists(t *testing.T) {
+	s := NewSandboxed(Limits{})
+	src := `let list = import("list");
+let xs = range(0, 1000);
+let evens = filter(map(xs, fn(x) { x * 2 }), fn(x) { x % 4 == 0 });
+[len(xs), len(evens), reduce(evens, 0, fn(a, x) { a + x }), list["reverse"](xs)[0], list["contains"](xs, 999)]`
+
+	result, err := s.Run(context.Background(), "a.mky", src)
+	if err != nil {
+		t.Fatalf("Run: %s", err)
+	}
+	if got := result.Value.Inspect(); got != "[1000, 500, 499000, 999, true]" {
+		t.Errorf("wrong result: %s", got)
+	}
+}
+
+func TestSandboxLimits(t *testing.T) {
+	tests := []struct {
+		limits Limits
+		src    string
+		err    string
+	}{
+		{Limits{Source: 10}, `let x = 100;`,
+			"1:1: input too large: 12 bytes (the limit is 10)"},
+		{Limits{Steps: 100}, `let f = fn(n) { if (n == 0) { 0 } else { f(n - 1) } }; f(200)`,
+			"1:17: step limit exceeded: ran more than 100 statements"},
+		{Limits{Frames: 50}, `let f = fn(n) { f(n + 1) }; f(0)`,
+			"1:18: stack overflow: too many nested calls"},
+		{Limits{Memory: 1000}, `let f = fn(s, n) { if (n == 0) { s } else { f(s + s, n - 1) } }; len(f("ab", 20))`,
+			"1:49: memory limit exceeded: allocated more than 1000 bytes"},
+		{Limits{Steps: 5}, `1; 2; 3; 4; 5; 6`,
+			"1:16: step limit exceeded: ran more than 5 statements"},
+		{Limits{Memory: 10}, `"abcdefgh"`,
+			"1:1: memory limit exceeded: allocated more than 10 bytes"},
+		{Limits{Timeout: time.Nanosecond}, `let f = fn(n) { if (n == 0) { 0 } else { f(n - 1) } }; f(100)`,
+			"evaluation interrupted: context deadline exceeded"},
+	}
+
+	for _, tt := range tests {
+		s := NewSandboxed(tt.limits)
+		_, err := s.Run(context.Background(), "", tt.src)
+		if err == nil {
+			t.Errorf("%s: no error", tt.src)
+			continue
+		}
+		// The position where a timeout strikes varies.
+		if got := err.Error(); !strings.HasSuffix(got, tt.err) {
+			t.Errorf("%s: wrong error %q, want %q", tt.src, got, tt.err)
+		}
+	}
+}
+
+func TestSandboxRuntimeFaults(t *testing.T) {
+	s := NewSandboxed(Limits{})
+	// Stands in for a fault in a builtin that would otherwise crash the host.
+	s.env.Set("crash", &object.Builtin{Fn: func(args ...object.Object) object.Object {
+		var m map[string]int
+		m["x"] = 1
+		return nil
+	}})
+
+	tests := []struct {
+		src    string
+		err    string
+		output string
+	}{
+		{`1 / 0`, "f.mky:1:3: division by zero: 1 / 0", ""},
+		{`let n = 0; 10 % n`, "f.mky:1:15: modulo by zero: 10 % 0", ""},
+		{`now()`, "f.mky:1:4: `now` requires the clock capability", ""},
+		{`puts("a"); [1, 2][0](3)`, "f.mky:1:21: not a function: INTEGER", "a\n"},
+		{`puts("a"); crash()`, "f.mky: panic during evaluation: assignment to entry in nil map", "a\n"},
+	}
+
+	for _, tt := range tests {
+		result, err := s.Run(context.Background(), "f.mky", tt.src)
+		if err == nil || err.Error() != tt.err {
+			t.Errorf("%s: wrong error %v, want %q", tt.src, err, tt.err)
+		}
+		if _, ok := err.(*parser.Error); !ok {
+			t.Errorf("%s: error is not *parser.Error. got=%T", tt.src, err)
+		}
+		if result.Value != nil || result.Output != tt.output {
+			t.Errorf("%s: got %+v", tt.src, result)
+		}
+	}
+
+	// The sandbox is still usable after a fault.
+	result, err := s.Run(context.Background(), "g.mky", `1 + 1`)
+	if err != nil || result.Value.Inspect() != "2" {
+		t.Errorf("got %+v, %v", result, err)
+	}
+}
@@ -0,0 +1,103 @@
+package lexer
+
+import (
+	"strings"
+
+	"github.com/rock619/monkey/token"
+)
+
+// heredocStart reports whether a heredoc such as
+//
+//	<<<END
+//	text
+//	END
+//
+// starts at the current character.
+func (l *Lexer) heredocStart() bool {
+	rest := l.input[l.position:]
+	return strings.HasPrefix(rest, "<<<") && len(rest) > 3 && isLetter(rest[3])
+}
+
+// readHeredoc reads a heredoc and returns its text: the lines between the
+// line of the opening marker and the closing marker, which is alone on its
+// line apart from indentation and the tokens that follow it. The
+// indentation of the closing marker is removed from every line, so that
+// the text can be indented along with the code around it.
+func (l *Lexer) readHeredoc(pos token.Position) token.Token {
+	for i := 0; i < len("<<<"); i++ {
+		l.readChar()
+	}
+	marker := l.readIdentifier()
+	tok := token.Token{Type: token.STRING}
+
+	for l.ch == ' ' || l.ch == '\t' || l.ch == '\r' {
+		l.readChar()
+	}
+	if l.ch != '\n' {
+		tok.Type = token.ILLEGAL
+		tok.Literal = "<<<" + marker
+		l.addError(pos, "heredoc marker %s must end its line", marker)
+		return tok
+	}
+	l.readChar()
+
+	var lines []string
+	for {
+		if l.ch == 0 {
+			tok.Type = token.ILLEGAL
+			tok.Literal = "<<<" + marker
+			l.addError(pos, "unterminated heredoc: no closing %s", marker)
+			return tok
+		}
+
+		start := l.position
+		for l.ch != '\n' && l.ch != 0 {
+			l.readChar()
+		}
+		line := l.input[start:l.position]
+
+		indent := line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+		if rest := line[len(indent):]; strings.HasPrefix(rest, marker) &&
+			(len(rest) == len(marker) || !isLetter(rest[len(marker)]) && !isDigit(rest[len(marker)])) {
+			// Back up to the end of the marker, so that what follows it
+			// is lexed as usual.
+			l.rewind(start + len(indent) + len(marker))
+			text, ok := dedent(lines, indent)
+			if !ok {
+				l.addError(pos, "heredoc line is indented less than its closing %s", marker)
+			}
+			tok.Literal = text
+			return tok
+		}
+
+		lines = append(lines, strings.TrimSuffix(line, "\r"))
+		if l.ch == '\n' {
+			l.readChar()
+		}
+	}
+}
+
+// rewind moves back to offset on the current line.
+func (l *Lexer) rewind(offset int) {
+	l.column -= l.position - offset + 1
+	l.ch = 0
+	l.readPosition = offset
+	l.readChar()
+}
+
+// dedent joins lines after removing indent from each of them. It reports
+// whether every line that isn't blank starts with indent.
+func dedent(lines []string, indent string) (string, bool) {
+	ok := true
+	for i, line := range lines {
+		switch {
+		case strings.HasPrefix(line, indent):
+			lines[i] = line[len(indent):]
+		case strings.TrimLeft(line, " \t") == "":
+			lines[i] = ""
+		default:
+			ok = false
+		}
+	}
+	return strings.Join(lines, "\n"), ok
+}
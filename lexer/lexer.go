@@ -1,28 +1,96 @@
 package lexer
 
 import (
+	"fmt"
+	"math"
 	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
 
 	"github.com/rock619/monkey/token"
 )
 
+// Error describes malformed input at a position in the source.
+type Error struct {
+	Pos token.Position
+	Msg string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s: %s", e.Pos, e.Msg)
+}
+
 type Lexer struct {
+	filename     string
 	input        string
 	position     int
 	readPosition int
 	ch           byte
+
+	line   int
+	column int
+
+	errors []*Error
+
+	operators   map[string]token.TokenType
+	identifiers map[string]bool
+	trivia      bool
+	scheme      bool
+
+	// ahead holds the tokens read by Peek but not yet by NextToken.
+	ahead []token.Token
 }
 
 func New(input string) *Lexer {
-	l := &Lexer{input: input}
+	return NewFile("", input)
+}
+
+// NewFile returns a Lexer for input read from filename. The filename is
+// recorded in the positions of tokens and errors.
+func NewFile(filename, input string) *Lexer {
+	l := &Lexer{filename: filename, input: input, line: 1}
 	l.readChar()
 	return l
 }
 
 func (l *Lexer) NextToken() token.Token {
+	if len(l.ahead) > 0 {
+		tok := l.ahead[0]
+		l.ahead = l.ahead[1:]
+		return tok
+	}
+	return l.scan()
+}
+
+// scan reads the next token from the input.
+func (l *Lexer) scan() token.Token {
 	var tok token.Token
 
+	if l.trivia {
+		pos := token.Position{Filename: l.filename, Line: l.line, Column: l.column}
+		start := l.position
+		switch {
+		case isWhitespace(l.ch):
+			l.skipWhitespace()
+			return token.Token{Type: token.WHITESPACE, Literal: l.input[start:l.position], Pos: pos}
+		case l.commentStart():
+			l.skipComment()
+			return token.Token{Type: token.COMMENT, Literal: l.input[start:l.position], Pos: pos}
+		}
+	}
 	l.skipWhitespace()
+	for l.commentStart() {
+		l.skipComment()
+		l.skipWhitespace()
+	}
+	pos := token.Position{Filename: l.filename, Line: l.line, Column: l.column}
+
+	if tok, ok := l.readOperator(); ok {
+		tok.Pos = pos
+		return tok
+	}
 
 	switch l.ch {
 	case '=':
@@ -51,7 +119,14 @@ func (l *Lexer) NextToken() token.Token {
 		tok = newToken(token.ASTERISK, l.ch)
 	case '/':
 		tok = newToken(token.SLASH, l.ch)
+	case '%':
+		tok = newToken(token.PERCENT, l.ch)
 	case '<':
+		if l.heredocStart() {
+			tok = l.readHeredoc(pos)
+			tok.Pos = pos
+			return tok
+		}
 		tok = newToken(token.LT, l.ch)
 	case '>':
 		tok = newToken(token.GT, l.ch)
@@ -77,36 +152,176 @@ func (l *Lexer) NextToken() token.Token {
 	case '"':
 		tok.Type = token.STRING
 		tok.Literal = l.readString()
+		if l.ch == 0 {
+			l.addError(pos, "unterminated string literal")
+		}
 	case ':':
 		tok = newToken(token.COLON, l.ch)
+	case '.':
+		tok = newToken(token.DOT, l.ch)
 	default:
 		switch {
 		case isLetter(l.ch):
 			tok.Literal = l.readIdentifier()
 			tok.Type = token.LookupIdent(tok.Literal)
+			if l.identifiers[tok.Literal] {
+				tok.Type = token.IDENT
+			}
+			tok.Pos = pos
 			return tok
 		case isDigit(l.ch):
 			tok.Type = token.INT
 			tok.Literal = l.readNumber()
+			if l.ch == '.' && isDigit(l.peekChar()) || l.ch == 'e' || l.ch == 'E' {
+				tok.Type = token.FLOAT
+				tok.Literal += l.readFraction()
+			}
+			switch {
+			case isLetter(l.ch) || !isDigit(tok.Literal[len(tok.Literal)-1]):
+				tok.Literal += l.readIdentifier()
+				if isDuration(tok.Literal) {
+					tok.Type = token.DURATION
+					break
+				}
+				tok.Type = token.ILLEGAL
+				l.addError(pos, "invalid numeric literal %q", tok.Literal)
+			case tok.Type == token.INT && !fitsInt64(tok.Literal):
+				tok.Type = token.ILLEGAL
+				l.addError(pos, "integer literal too large: %s (the maximum is %d)",
+					tok.Literal, int64(math.MaxInt64))
+			case tok.Type == token.FLOAT && !fitsFloat64(tok.Literal):
+				tok.Type = token.ILLEGAL
+				l.addError(pos, "float literal out of range: %s", tok.Literal)
+			}
+			tok.Pos = pos
 			return tok
 		default:
-			tok = newToken(token.ILLEGAL, l.ch)
+			r, size := utf8.DecodeRuneInString(l.input[l.position:])
+			for i := 1; i < size; i++ {
+				l.readChar()
+			}
+			tok = token.Token{Type: token.ILLEGAL, Literal: string(r)}
+			l.addError(pos, "invalid character %q", r)
 		}
 	}
 
 	l.readChar()
+	tok.Pos = pos
 	return tok
 }
 
+// NewAt returns a Lexer that starts reading input at pos rather than at
+// its beginning. Positions of the returned tokens are relative to the start
+// of input and carry the filename of pos.
+func NewAt(input string, pos token.Position) *Lexer {
+	offset := 0
+	for line := 1; line < pos.Line && offset < len(input); line++ {
+		next := strings.IndexByte(input[offset:], '\n')
+		if next < 0 {
+			offset = len(input)
+			break
+		}
+		offset += next + 1
+	}
+	offset = min(offset+pos.Column-1, len(input))
+
+	l := &Lexer{
+		filename:     pos.Filename,
+		input:        input,
+		readPosition: offset,
+		line:         pos.Line,
+		column:       pos.Column - 1,
+	}
+	l.readChar()
+	return l
+}
+
+// RegisterOperator makes the lexer emit a token of type t for literal,
+// which takes precedence over the built-in tokens starting with the same
+// characters. It must be called before the first token is read.
+func (l *Lexer) RegisterOperator(literal string, t token.TokenType) {
+	if l.operators == nil {
+		l.operators = make(map[string]token.TokenType)
+	}
+	l.operators[literal] = t
+}
+
+// Unreserve makes l read the keyword word as an identifier, for dialects
+// without the syntax it introduces. It must be called before the first
+// token is read.
+func (l *Lexer) Unreserve(word string) {
+	if l.identifiers == nil {
+		l.identifiers = make(map[string]bool)
+	}
+	l.identifiers[word] = true
+}
+
+// SchemeIdentifiers makes l accept identifiers in the style of Scheme,
+// such as empty? and set-car!: a dash followed by a letter or digit
+// continues an identifier, and a ? or ! may end one. Arithmetic such as
+// a-b then needs spaces around the operator. It must be called before the
+// first token is read.
+func (l *Lexer) SchemeIdentifiers() {
+	l.scheme = true
+}
+
+// readOperator reads the longest registered operator at the current
+// position, if any.
+func (l *Lexer) readOperator() (token.Token, bool) {
+	var tok token.Token
+	if l.position >= len(l.input) {
+		return tok, false
+	}
+	for literal, t := range l.operators {
+		if len(literal) > len(tok.Literal) && strings.HasPrefix(l.input[l.position:], literal) {
+			tok = token.Token{Type: t, Literal: literal}
+		}
+	}
+	if tok.Literal == "" {
+		return tok, false
+	}
+
+	for i := 0; i < len(tok.Literal); i++ {
+		l.readChar()
+	}
+	return tok, true
+}
+
+// Size returns the length of the input in bytes.
+func (l *Lexer) Size() int {
+	return len(l.input)
+}
+
+// Errors returns the problems found in the input read so far.
+func (l *Lexer) Errors() []*Error {
+	return l.errors
+}
+
+func (l *Lexer) addError(pos token.Position, format string, a ...any) {
+	l.errors = append(l.errors, &Error{Pos: pos, Msg: fmt.Sprintf(format, a...)})
+}
+
+// readIdentifier reads a letter followed by letters and digits, such as
+// encodeBase64, or a Scheme identifier if enabled.
 func (l *Lexer) readIdentifier() string {
 	position := l.position
-	for isLetter(l.ch) {
+	for isLetter(l.ch) || isDigit(l.ch) ||
+		l.scheme && l.ch == '-' && (isLetter(l.peekChar()) || isDigit(l.peekChar())) {
+		l.readChar()
+	}
+	if l.scheme && (l.ch == '?' || l.ch == '!' && l.peekChar() != '=') {
 		l.readChar()
 	}
 	return l.input[position:l.position]
 }
 
 func (l *Lexer) readChar() {
+	if l.ch == '\n' {
+		l.line++
+		l.column = 0
+	}
+	l.column++
+
 	if l.readPosition >= len(l.input) {
 		l.ch = 0
 	} else {
@@ -116,6 +331,26 @@ func (l *Lexer) readChar() {
 	l.readPosition++
 }
 
+// fitsInt64 reports whether the decimal digits fit in an int64.
+func fitsInt64(digits string) bool {
+	digits = strings.TrimLeft(digits, "0")
+	const max = "9223372036854775807"
+	return len(digits) < len(max) || len(digits) == len(max) && digits <= max
+}
+
+// fitsFloat64 reports whether the float literal is finite as a float64.
+func fitsFloat64(literal string) bool {
+	_, err := strconv.ParseFloat(literal, 64)
+	return err == nil
+}
+
+// isDuration reports whether a numeric literal followed by letters is a
+// duration such as 100ms or 1h30m, in the syntax of Go's time.ParseDuration.
+func isDuration(literal string) bool {
+	_, err := time.ParseDuration(literal)
+	return err == nil
+}
+
 func (l *Lexer) readNumber() string {
 	position := l.position
 	for isDigit(l.ch) {
@@ -124,12 +359,71 @@ func (l *Lexer) readNumber() string {
 	return l.input[position:l.position]
 }
 
+// readFraction reads the fraction and exponent of a float literal after its
+// integer digits: ".5", "e3", ".25e-2".
+func (l *Lexer) readFraction() string {
+	position := l.position
+	if l.ch == '.' {
+		l.readChar()
+		l.readNumber()
+	}
+	if l.ch == 'e' || l.ch == 'E' {
+		l.readChar()
+		if (l.ch == '+' || l.ch == '-') && isDigit(l.peekChar()) {
+			l.readChar()
+		}
+		l.readNumber()
+	}
+	return l.input[position:l.position]
+}
+
 func (l *Lexer) skipWhitespace() {
-	for slices.Contains([]byte{' ', '\t', '\n', '\r'}, l.ch) {
+	for isWhitespace(l.ch) {
 		l.readChar()
 	}
 }
 
+func (l *Lexer) commentStart() bool {
+	return l.ch == '/' && (l.peekChar() == '/' || l.peekChar() == '*')
+}
+
+// skipComment skips a // comment up to, but not including, the end of its
+// line, or a block comment. Block comments nest, so that code containing
+// them can be commented out.
+func (l *Lexer) skipComment() {
+	if l.peekChar() == '/' {
+		for l.ch != '\n' && l.ch != 0 {
+			l.readChar()
+		}
+		return
+	}
+
+	pos := token.Position{Filename: l.filename, Line: l.line, Column: l.column}
+	depth := 0
+	for {
+		switch {
+		case l.ch == 0:
+			l.addError(pos, "unterminated block comment")
+			return
+		case l.ch == '/' && l.peekChar() == '*':
+			depth++
+			l.readChar()
+		case l.ch == '*' && l.peekChar() == '/':
+			depth--
+			l.readChar()
+			if depth == 0 {
+				l.readChar()
+				return
+			}
+		}
+		l.readChar()
+	}
+}
+
+func isWhitespace(ch byte) bool {
+	return slices.Contains([]byte{' ', '\t', '\n', '\r'}, ch)
+}
+
 func (l *Lexer) peekChar() byte {
 	if l.readPosition >= len(l.input) {
 		return 0
@@ -2,6 +2,7 @@ package lexer
 
 import (
 	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/rock619/monkey/token"
@@ -16,7 +17,7 @@ let add = fn(x, y) {
 }
 
 let result = add(five, ten);
-!-/*5;
+!-/ *5;
 5 < 10 > 5;
 
 if (5 < 10) {
@@ -31,6 +32,8 @@ if (5 < 10) {
 "foo bar"
 [1, 2];
 {"foo": "bar"}
+user.name
+x1_y2
 `
 
 	tests := []struct {
@@ -122,6 +125,10 @@ if (5 < 10) {
 		{token.COLON, ":"},
 		{token.STRING, "bar"},
 		{token.RBRACE, "}"},
+		{token.IDENT, "user"},
+		{token.DOT, "."},
+		{token.IDENT, "name"},
+		{token.IDENT, "x1_y2"},
 
 		{token.EOF, ""},
 	}
@@ -137,3 +144,333 @@ if (5 < 10) {
 		})
 	}
 }
+
+func TestNextTokenPosition(t *testing.T) {
+	input := "let x = 5;\n  x + \"a\nb\";\n"
+
+	tests := []struct {
+		expectedType token.TokenType
+		expectedPos  token.Position
+	}{
+		{token.LET, token.Position{Line: 1, Column: 1}},
+		{token.IDENT, token.Position{Line: 1, Column: 5}},
+		{token.ASSIGN, token.Position{Line: 1, Column: 7}},
+		{token.INT, token.Position{Line: 1, Column: 9}},
+		{token.SEMICOLON, token.Position{Line: 1, Column: 10}},
+		{token.IDENT, token.Position{Line: 2, Column: 3}},
+		{token.PLUS, token.Position{Line: 2, Column: 5}},
+		{token.STRING, token.Position{Line: 2, Column: 7}},
+		{token.SEMICOLON, token.Position{Line: 3, Column: 3}},
+		{token.EOF, token.Position{Line: 4, Column: 1}},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		assert.Equal(t, tt.expectedType, tok.Type, "tests[%d]", i)
+		assert.Equal(t, tt.expectedPos, tok.Pos, "tests[%d]", i)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedType  token.TokenType
+		expectedError string
+	}{
+		{`"abc`, token.STRING, `1:1: unterminated string literal`},
+		{`@`, token.ILLEGAL, `1:1: invalid character '@'`},
+		{`  é`, token.ILLEGAL, `1:3: invalid character 'é'`},
+		{`123abc`, token.ILLEGAL, `1:1: invalid numeric literal "123abc"`},
+		{`9223372036854775808`, token.ILLEGAL,
+			`1:1: integer literal too large: 9223372036854775808 (the maximum is 9223372036854775807)`},
+		{`123456789012345678901234567890`, token.ILLEGAL,
+			`1:1: integer literal too large: 123456789012345678901234567890 (the maximum is 9223372036854775807)`},
+		{`1.5x`, token.ILLEGAL, `1:1: invalid numeric literal "1.5x"`},
+		{`5sec`, token.ILLEGAL, `1:1: invalid numeric literal "5sec"`},
+		{`2e`, token.ILLEGAL, `1:1: invalid numeric literal "2e"`},
+		{`1e999`, token.ILLEGAL, `1:1: float literal out of range: 1e999`},
+		{"1 /* a /* b */", token.INT, `1:3: unterminated block comment`},
+		{"<<<END", token.ILLEGAL, `1:1: heredoc marker END must end its line`},
+		{"<<<END\nabc\nENDING", token.ILLEGAL, `1:1: unterminated heredoc: no closing END`},
+		{"<<<END\n a\n\tEND", token.STRING, `1:1: heredoc line is indented less than its closing END`},
+	}
+
+	for _, tt := range tests {
+		l := New(tt.input)
+		tok := l.NextToken()
+
+		assert.Equal(t, tt.expectedType, tok.Type, "input=%q", tt.input)
+		assert.Equal(t, token.EOF, l.NextToken().Type, "input=%q", tt.input)
+		if assert.Len(t, l.Errors(), 1, "input=%q", tt.input) {
+			assert.Equal(t, tt.expectedError, l.Errors()[0].Error())
+		}
+	}
+}
+
+func TestHeredoc(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"<<<END\nEND", ""},
+		{"<<<END\nsay \"hi\"\nEND", `say "hi"`},
+		{"<<<END  \r\none\r\n\r\ntwo\r\nEND", "one\n\ntwo"},
+		{"<<<EOS\n\t\tif (x) {\n\t\t\ty\n\n\t\t}\n\t\tEOS", "if (x) {\n\ty\n\n}"},
+		{"<<<END\nEND_NOT\n END1\nEND", "END_NOT\n END1"},
+	}
+
+	for _, tt := range tests {
+		l := New(tt.input)
+		tok := l.NextToken()
+
+		assert.Equal(t, token.STRING, tok.Type, "input=%q", tt.input)
+		assert.Equal(t, tt.expected, tok.Literal, "input=%q", tt.input)
+		assert.Equal(t, token.EOF, l.NextToken().Type, "input=%q", tt.input)
+		assert.Empty(t, l.Errors(), "input=%q", tt.input)
+	}
+
+	l := New("let s = <<<END\n  a\n  END;\nx << <y")
+	expected := []struct {
+		typ token.TokenType
+		pos token.Position
+	}{
+		{token.LET, token.Position{Line: 1, Column: 1}},
+		{token.IDENT, token.Position{Line: 1, Column: 5}},
+		{token.ASSIGN, token.Position{Line: 1, Column: 7}},
+		{token.STRING, token.Position{Line: 1, Column: 9}},
+		{token.SEMICOLON, token.Position{Line: 3, Column: 6}},
+		{token.IDENT, token.Position{Line: 4, Column: 1}},
+		{token.LT, token.Position{Line: 4, Column: 3}},
+		{token.LT, token.Position{Line: 4, Column: 4}},
+		{token.LT, token.Position{Line: 4, Column: 6}},
+		{token.IDENT, token.Position{Line: 4, Column: 7}},
+		{token.EOF, token.Position{Line: 4, Column: 8}},
+	}
+	for i, e := range expected {
+		tok := l.NextToken()
+		assert.Equal(t, e.typ, tok.Type, "tokens[%d]", i)
+		assert.Equal(t, e.pos, tok.Pos, "tokens[%d]", i)
+	}
+}
+
+func TestSchemeIdentifiers(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"empty?(xs)", []string{"empty?", "(", "xs", ")"}},
+		{"set-car! x", []string{"set-car!", "x"}},
+		{"vector-ref2 a-1", []string{"vector-ref2", "a-1"}},
+		{"a - b a- b -a", []string{"a", "-", "b", "a", "-", "b", "-", "a"}},
+		{"a!=b a?!", []string{"a", "!=", "b", "a?", "!"}},
+		{"let-x", []string{"let-x"}},
+	}
+
+	for _, tt := range tests {
+		l := New(tt.input)
+		l.SchemeIdentifiers()
+
+		var literals []string
+		for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+			literals = append(literals, tok.Literal)
+		}
+		assert.Equal(t, tt.expected, literals, "input=%q", tt.input)
+		assert.Empty(t, l.Errors(), "input=%q", tt.input)
+	}
+
+	l := New("empty?")
+	assert.Equal(t, "empty", l.NextToken().Literal)
+}
+
+func TestFloat(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []token.Token
+	}{
+		{"3.14", []token.Token{{Type: token.FLOAT, Literal: "3.14"}}},
+		{"1e9 2E-3 0.5e+2", []token.Token{
+			{Type: token.FLOAT, Literal: "1e9"},
+			{Type: token.FLOAT, Literal: "2E-3"},
+			{Type: token.FLOAT, Literal: "0.5e+2"},
+		}},
+		{"1.len", []token.Token{
+			{Type: token.INT, Literal: "1"},
+			{Type: token.DOT, Literal: "."},
+			{Type: token.IDENT, Literal: "len"},
+		}},
+		{"5s 100ms 1h30m 1.5h", []token.Token{
+			{Type: token.DURATION, Literal: "5s"},
+			{Type: token.DURATION, Literal: "100ms"},
+			{Type: token.DURATION, Literal: "1h30m"},
+			{Type: token.DURATION, Literal: "1.5h"},
+		}},
+		{"2e-x", []token.Token{
+			{Type: token.ILLEGAL, Literal: "2e"},
+			{Type: token.MINUS, Literal: "-"},
+			{Type: token.IDENT, Literal: "x"},
+		}},
+	}
+
+	for _, tt := range tests {
+		l := New(tt.input)
+
+		var tokens []token.Token
+		for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+			tok.Pos = token.Position{}
+			tokens = append(tokens, tok)
+		}
+		assert.Equal(t, tt.expected, tokens, "input=%q", tt.input)
+	}
+}
+
+func TestComments(t *testing.T) {
+	input := `// a program
+let x = 10 / 2; // halved
+// let y = 1;
+x //
+// at the end`
+	l := New(input)
+
+	var tokens []token.Token
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		tokens = append(tokens, tok)
+	}
+	assert.Empty(t, l.Errors())
+	assert.Equal(t, []token.Token{
+		{Type: token.LET, Literal: "let", Pos: token.Position{Line: 2, Column: 1}},
+		{Type: token.IDENT, Literal: "x", Pos: token.Position{Line: 2, Column: 5}},
+		{Type: token.ASSIGN, Literal: "=", Pos: token.Position{Line: 2, Column: 7}},
+		{Type: token.INT, Literal: "10", Pos: token.Position{Line: 2, Column: 9}},
+		{Type: token.SLASH, Literal: "/", Pos: token.Position{Line: 2, Column: 12}},
+		{Type: token.INT, Literal: "2", Pos: token.Position{Line: 2, Column: 14}},
+		{Type: token.SEMICOLON, Literal: ";", Pos: token.Position{Line: 2, Column: 15}},
+		{Type: token.IDENT, Literal: "x", Pos: token.Position{Line: 4, Column: 1}},
+	}, tokens)
+}
+
+func TestBlockComments(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"a /* b */ c", []string{"a", "c"}},
+		{"a/**/b", []string{"a", "b"}},
+		{"a /* b /* c */ d */ e", []string{"a", "e"}},
+		{"a /* b\n// c */ d", []string{"a", "d"}},
+		{"a // b /* c\nd */", []string{"a", "d", "*", "/"}},
+		{"a /* b */ // c\nd", []string{"a", "d"}},
+		{"a /*/ b */ c", []string{"a", "c"}},
+		{"a * / b", []string{"a", "*", "/", "b"}},
+	}
+
+	for _, tt := range tests {
+		l := New(tt.input)
+
+		var literals []string
+		for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+			literals = append(literals, tok.Literal)
+		}
+		assert.Equal(t, tt.expected, literals, "input=%q", tt.input)
+		assert.Empty(t, l.Errors(), "input=%q", tt.input)
+	}
+
+	spans, errs := Spans("", "x /* a /* b */ */\n")
+	assert.Empty(t, errs)
+	if assert.Len(t, spans, 5) {
+		assert.Equal(t, token.COMMENT, spans[2].Type)
+		assert.Equal(t, "/* a /* b */ */", spans[2].Literal)
+	}
+}
+
+func TestPeek(t *testing.T) {
+	l := New("let x = @;")
+
+	assert.Equal(t, token.LET, l.Peek(0).Type)
+	assert.Equal(t, token.ILLEGAL, l.Peek(3).Type)
+	assert.Len(t, l.Errors(), 1)
+	assert.Equal(t, token.EOF, l.Peek(10).Type)
+	assert.Equal(t, token.IDENT, l.Peek(1).Type)
+
+	var types []token.TokenType
+	l.Tokens()(func(tok token.Token) bool {
+		types = append(types, tok.Type)
+		return true
+	})
+	assert.Equal(t, []token.TokenType{
+		token.LET, token.IDENT, token.ASSIGN, token.ILLEGAL, token.SEMICOLON, token.EOF,
+	}, types)
+	assert.Len(t, l.Errors(), 1)
+
+	l = New("a b c")
+	var literals []string
+	l.Tokens()(func(tok token.Token) bool {
+		literals = append(literals, tok.Literal)
+		return tok.Literal != "b"
+	})
+	assert.Equal(t, []string{"a", "b"}, literals)
+	assert.Equal(t, "c", l.NextToken().Literal)
+}
+
+func TestNewFile(t *testing.T) {
+	l := NewFile("a.mky", "x\n@")
+
+	assert.Equal(t, token.Position{Filename: "a.mky", Line: 1, Column: 1}, l.NextToken().Pos)
+	assert.Equal(t, token.Position{Filename: "a.mky", Line: 2, Column: 1}, l.NextToken().Pos)
+	if assert.Len(t, l.Errors(), 1) {
+		assert.Equal(t, "a.mky:2:1: invalid character '@'", l.Errors()[0].Error())
+	}
+}
+
+func TestUnreserve(t *testing.T) {
+	l := New("defer fn")
+	l.Unreserve("defer")
+
+	tok := l.NextToken()
+	assert.Equal(t, token.IDENT, tok.Type)
+	assert.Equal(t, "defer", tok.Literal)
+	assert.Equal(t, token.FUNCTION, l.NextToken().Type)
+}
+
+func TestFitsInt64(t *testing.T) {
+	assert.True(t, fitsInt64("0"))
+	assert.True(t, fitsInt64("9223372036854775807"))
+	assert.True(t, fitsInt64("0009223372036854775807"))
+	assert.False(t, fitsInt64("9223372036854775808"))
+	assert.False(t, fitsInt64("10000000000000000000"))
+}
+
+func TestSpans(t *testing.T) {
+	input := "let s = \"a b\";\n\tputs(s , 12)  // twelve\n"
+	spans, errs := Spans("x.mky", input)
+	assert.Empty(t, errs)
+
+	var rebuilt strings.Builder
+	var types []token.TokenType
+	for i, span := range spans {
+		if i > 0 {
+			assert.Equal(t, spans[i-1].End, span.Start, "gap before %s", span.Type)
+		}
+		rebuilt.WriteString(input[span.Start:span.End])
+		types = append(types, span.Type)
+	}
+	assert.Equal(t, input, rebuilt.String())
+	assert.Equal(t, []token.TokenType{
+		token.LET, token.WHITESPACE, token.IDENT, token.WHITESPACE, token.ASSIGN, token.WHITESPACE,
+		token.STRING, token.SEMICOLON, token.WHITESPACE, token.IDENT, token.LPAREN, token.IDENT,
+		token.WHITESPACE, token.COMMA, token.WHITESPACE, token.INT, token.RPAREN, token.WHITESPACE,
+		token.COMMENT, token.WHITESPACE, token.EOF,
+	}, types)
+
+	ws := spans[8]
+	assert.Equal(t, "\n\t", ws.Literal)
+	assert.Equal(t, token.Position{Filename: "x.mky", Line: 1, Column: 15}, ws.Pos)
+	assert.Equal(t, token.Position{Filename: "x.mky", Line: 2, Column: 2}, spans[9].Pos)
+	assert.Equal(t, "// twelve", spans[18].Literal)
+	assert.Equal(t, len(input), spans[len(spans)-1].Start)
+
+	_, errs = Spans("", `"open`)
+	assert.Len(t, errs, 1)
+}
@@ -0,0 +1,31 @@
+package lexer
+
+import "github.com/rock619/monkey/token"
+
+// Peek returns the token n places ahead without consuming it: Peek(0) is
+// the token the next call of NextToken returns. Past the end of the input
+// it returns EOF. Errors in the tokens peeked at are reported by Errors
+// right away.
+func (l *Lexer) Peek(n int) token.Token {
+	for len(l.ahead) <= n {
+		l.ahead = append(l.ahead, l.scan())
+	}
+	return l.ahead[n]
+}
+
+// Tokens returns an iterator over the remaining tokens, up to and
+// including EOF. It can be ranged over from Go 1.23:
+//
+//	for tok := range l.Tokens() {
+//		...
+//	}
+func (l *Lexer) Tokens() func(yield func(token.Token) bool) {
+	return func(yield func(token.Token) bool) {
+		for {
+			tok := l.NextToken()
+			if !yield(tok) || tok.Type == token.EOF {
+				return
+			}
+		}
+	}
+}
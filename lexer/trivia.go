@@ -0,0 +1,42 @@
+package lexer
+
+import "github.com/rock619/monkey/token"
+
+// KeepTrivia makes l return whitespace as WHITESPACE tokens instead of
+// skipping it, for tools such as formatters and highlighters that must
+// reproduce the source exactly. Parsers don't accept trivia. It must be
+// called before the first token is read.
+func (l *Lexer) KeepTrivia() {
+	l.trivia = true
+}
+
+// A Span is a token together with the byte offsets of its text in the
+// input, which for strings includes the quotes.
+type Span struct {
+	token.Token
+	Start, End int
+}
+
+// Spans splits input into tokens, including trivia, up to and including
+// EOF. The spans cover the input without gaps or overlaps, so that
+// concatenating input[Start:End] for each reconstructs it.
+func Spans(filename, input string) ([]Span, []*Error) {
+	l := NewFile(filename, input)
+	l.KeepTrivia()
+
+	var spans []Span
+	for {
+		start := l.offset()
+		tok := l.NextToken()
+		spans = append(spans, Span{Token: tok, Start: start, End: l.offset()})
+		if tok.Type == token.EOF {
+			return spans, l.Errors()
+		}
+	}
+}
+
+// offset returns the offset of the next character to read, which is past
+// the tokens read by Peek.
+func (l *Lexer) offset() int {
+	return min(l.position, len(l.input))
+}
@@ -0,0 +1,35 @@
+// Package lib is the part of the Monkey standard library that is written
+// in Monkey itself. Each module is a source file embedded into the binary
+// and loaded with the import builtin.
+package lib
+
+import (
+	"embed"
+	"path"
+	"sort"
+	"strings"
+)
+
+//go:embed *.mky
+var files embed.FS
+
+// Source returns the source of the module name.
+func Source(name string) (string, bool) {
+	src, err := files.ReadFile(name + ".mky")
+	if err != nil {
+		return "", false
+	}
+	return string(src), true
+}
+
+// Modules returns the sorted names of all modules.
+func Modules() []string {
+	entries, _ := files.ReadDir(".")
+
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		names = append(names, strings.TrimSuffix(entry.Name(), path.Ext(entry.Name())))
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,25 @@
+package lib
+
+import (
+	"testing"
+
+	"github.com/rock619/monkey/lexer"
+	"github.com/rock619/monkey/parser"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestModulesParse(t *testing.T) {
+	assert.Equal(t, []string{"func", "list", "prelude", "strings"}, Modules())
+
+	for _, name := range Modules() {
+		src, ok := Source(name)
+		assert.True(t, ok, name)
+
+		p := parser.New(lexer.NewFile(name+".mky", src))
+		p.ParseProgram()
+		assert.Empty(t, p.Errors(), name)
+	}
+
+	_, ok := Source("no such module")
+	assert.False(t, ok)
+}
@@ -1,20 +1,91 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"os"
 	"os/user"
+	"strings"
 
+	"github.com/rock619/monkey/evaluator"
+	"github.com/rock619/monkey/parser"
 	"github.com/rock619/monkey/repl"
 )
 
+var (
+	maxElements  = flag.Int("max-elements", 100, "maximum number of array elements or hash pairs to print (0 for no limit)")
+	maxStringLen = flag.Int("max-string-len", 1000, "maximum number of bytes of a string to print (0 for no limit)")
+	pager        = flag.String("pager", os.Getenv("PAGER"), "command to page results longer than -pager-threshold bytes")
+	pagerLimit   = flag.Int("pager-threshold", 4096, "output length in bytes above which the pager is used")
+	dialect      = flag.String("dialect", parser.DefaultDialect, "language dialect: "+strings.Join(parser.Dialects(), ", "))
+	extTruthy    = flag.Bool("extended-truthiness", false, "treat 0, \"\", [] and {} as false in conditions")
+	checked      = flag.Bool("checked-arithmetic", false, "report integer overflow as an error instead of wrapping around")
+	quiet        = flag.Bool("quiet", false, "omit the greeting and print parser errors without the monkey face")
+	noPrelude    = flag.Bool("no-prelude", false, "don't provide the prelude functions such as map and filter")
+	schemeIdents = flag.Bool("scheme-identifiers", false, "accept identifiers such as empty? and set-car!")
+	allow        = flag.String("allow", "", "comma-separated capabilities granted to scripts: io, exec, clock")
+)
+
 func main() {
-	user, err := user.Current()
+	flag.Parse()
+
+	syntax, ok := parser.LookupDialect(*dialect)
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown dialect %q\n", *dialect)
+		os.Exit(2)
+	}
+	syntax.SchemeIdentifiers = *schemeIdents
+
+	capabilities, err := evaluator.ParseCapabilities(*allow)
 	if err != nil {
-		panic(err)
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
 	}
 
-	fmt.Printf("Hello %s! This is the Monkey programming language!\n", user.Username)
-	fmt.Println("Feel free to type in commands")
-	repl.Start(os.Stdin, os.Stdout)
+	opts := evaluator.Options{
+		ExtendedTruthiness: *extTruthy,
+		CheckedArithmetic:  *checked,
+		Prelude:            !*noPrelude,
+		Capabilities:       capabilities,
+		Syntax:             syntax,
+	}
+
+	switch flag.Arg(0) {
+	case "get":
+		os.Exit(get(flag.Args()[1:]))
+	case "init":
+		os.Exit(initProject(flag.Args()[1:]))
+	case "tutor":
+		repl.Tutor(os.Stdin, os.Stdout)
+		return
+	case "run":
+		os.Exit(run(flag.Args()[1:], syntax, opts))
+	case "serve":
+		os.Exit(serve(flag.Args()[1:], syntax, opts))
+	case "attach":
+		os.Exit(attach(flag.Args()[1:]))
+	case "vet":
+		os.Exit(vet(flag.Args()[1:], syntax))
+	case "template":
+		os.Exit(render(flag.Args()[1:], syntax, opts))
+	}
+
+	if !*quiet {
+		user, err := user.Current()
+		if err != nil {
+			panic(err)
+		}
+
+		fmt.Printf("Hello %s! This is the Monkey programming language!\n", user.Username)
+		fmt.Println("Feel free to type in commands")
+	}
+	repl.StartWithOptions(os.Stdin, os.Stdout, repl.Options{
+		MaxElements:    *maxElements,
+		MaxStringLen:   *maxStringLen,
+		Pager:          *pager,
+		PagerThreshold: *pagerLimit,
+		Syntax:         syntax,
+		Eval:           opts,
+		Quiet:          *quiet,
+	})
 }
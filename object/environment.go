@@ -1,5 +1,10 @@
 package object
 
+import (
+	"errors"
+	"maps"
+)
+
 func NewEnclosedEnvironment(outer *Environment) *Environment {
 	env := NewEnvironment()
 	env.outer = outer
@@ -12,8 +17,9 @@ func NewEnvironment() *Environment {
 }
 
 type Environment struct {
-	store map[string]Object
-	outer *Environment
+	store  map[string]Object
+	outer  *Environment
+	frozen bool
 }
 
 func (e *Environment) Get(name string) (Object, bool) {
@@ -24,7 +30,89 @@ func (e *Environment) Get(name string) (Object, bool) {
 	return obj, ok
 }
 
+// GetLocal returns the value of name if it is bound in e itself, not in
+// the environments enclosing it.
+func (e *Environment) GetLocal(name string) (Object, bool) {
+	obj, ok := e.store[name]
+	return obj, ok
+}
+
+// Outer returns the environment enclosing e, or nil.
+func (e *Environment) Outer() *Environment {
+	return e.outer
+}
+
 func (e *Environment) Set(name string, val Object) Object {
 	e.store[name] = val
 	return val
 }
+
+var (
+	ErrUndeclared = errors.New("undeclared identifier")
+	ErrReadOnly   = errors.New("read-only identifier")
+)
+
+// Reset empties e and makes outer enclose it, so that e can be reused once
+// nothing refers to it any more.
+func (e *Environment) Reset(outer *Environment) {
+	clear(e.store)
+	e.outer = outer
+	e.frozen = false
+}
+
+// Assign changes the value of name in the environment that declares it,
+// which may enclose e. Every closure sharing that environment sees the new
+// value. It fails with ErrUndeclared if name isn't declared and with
+// ErrReadOnly if it is declared by a frozen environment.
+func (e *Environment) Assign(name string, val Object) error {
+	for env := e; env != nil; env = env.outer {
+		if _, ok := env.store[name]; !ok {
+			continue
+		}
+		if env.frozen {
+			return ErrReadOnly
+		}
+		env.store[name] = val
+		return nil
+	}
+	return ErrUndeclared
+}
+
+// Freeze makes the names declared by e read-only for Assign. Enclosed
+// environments may still shadow them.
+func (e *Environment) Freeze() {
+	e.frozen = true
+}
+
+// Copy returns an environment with the bindings of e in which names can be
+// assigned without changing e. Frozen environments are shared rather than
+// copied, since nothing can assign their names.
+func (e *Environment) Copy() *Environment {
+	if e == nil || e.frozen {
+		return e
+	}
+	return &Environment{store: maps.Clone(e.store), outer: e.outer.Copy()}
+}
+
+// LocalNames returns the names bound in e itself, not in the environments
+// enclosing it.
+func (e *Environment) LocalNames() []string {
+	names := make([]string, 0, len(e.store))
+	for name := range e.store {
+		names = append(names, name)
+	}
+	return names
+}
+
+// Names returns every name visible from e, including those bound in
+// enclosing environments.
+func (e *Environment) Names() []string {
+	names := []string{}
+	for name := range e.store {
+		names = append(names, name)
+	}
+	if e.outer != nil {
+		names = append(names, e.outer.Names()...)
+	}
+	return names
+}
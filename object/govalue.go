@@ -0,0 +1,400 @@
+package object
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"time"
+)
+
+const GO_VALUE_OBJ = "GO_VALUE"
+
+// GoValue hands a live Go value, typically a pointer to a struct, to
+// scripts. Scripts read its exported fields and call its exported methods
+// with member expressions such as v.Name and v.Greet("x"), but only those
+// named in Allow. Values are converted with FromGo and ToGo; structs reached
+// through a GoValue are wrapped in turn and share its Allow list.
+type GoValue struct {
+	Value any
+	// Allow lists the fields and methods scripts may use.
+	Allow []string
+}
+
+func (g *GoValue) Type() ObjectType { return GO_VALUE_OBJ }
+func (g *GoValue) Inspect() string  { return fmt.Sprintf("<go %T>", g.Value) }
+
+// Member returns the field or method name of g. A method is returned as a
+// builtin that converts its arguments and results. A method whose last
+// result is a non-nil error returns that error as an error object.
+func (g *GoValue) Member(name string) (Object, error) {
+	if !slices.Contains(g.Allow, name) {
+		return nil, fmt.Errorf("%T has no member %s", g.Value, name)
+	}
+
+	v := reflect.ValueOf(g.Value)
+	if method := v.MethodByName(name); method.IsValid() {
+		return &Builtin{Fn: g.method(name, method)}, nil
+	}
+
+	for v.Kind() == reflect.Pointer && !v.IsNil() {
+		v = v.Elem()
+	}
+	if v.Kind() == reflect.Struct {
+		if field, ok := v.Type().FieldByName(name); ok && field.IsExported() {
+			return g.fromGo(v.FieldByIndex(field.Index))
+		}
+	}
+	return nil, fmt.Errorf("%T has no member %s", g.Value, name)
+}
+
+func (g *GoValue) method(name string, method reflect.Value) BuiltinFunction {
+	typ := method.Type()
+
+	return func(args ...Object) Object {
+		if typ.IsVariadic() && len(args) < typ.NumIn()-1 ||
+			!typ.IsVariadic() && len(args) != typ.NumIn() {
+			return &Error{Message: fmt.Sprintf("wrong number of arguments to %s. got=%d, want=%d",
+				name, len(args), typ.NumIn())}
+		}
+
+		in := make([]reflect.Value, len(args))
+		for i, arg := range args {
+			argType := typ.In(min(i, typ.NumIn()-1))
+			if typ.IsVariadic() && i >= typ.NumIn()-1 {
+				argType = argType.Elem()
+			}
+			v, err := toGo(arg, argType, Visited{})
+			if err != nil {
+				return &Error{Message: fmt.Sprintf("argument %d to %s: %s", i+1, name, err)}
+			}
+			in[i] = v
+		}
+
+		out := method.Call(in)
+		if n := len(out); n > 0 && typ.Out(n-1) == errorType {
+			if err, _ := out[n-1].Interface().(error); err != nil {
+				return &Error{Message: err.Error()}
+			}
+			out = out[:n-1]
+		}
+		if len(out) == 0 {
+			return NullValue
+		}
+
+		result, err := g.fromGo(out[0])
+		if err != nil {
+			return &Error{Message: fmt.Sprintf("result of %s: %s", name, err)}
+		}
+		return result
+	}
+}
+
+// fromGo converts v like FromGo, wrapping structs in a GoValue with the
+// Allow list of g.
+func (g *GoValue) fromGo(v reflect.Value) (Object, error) {
+	return fromGo(v, func(v reflect.Value) Object {
+		return &GoValue{Value: v.Interface(), Allow: g.Allow}
+	}, goVisited{})
+}
+
+var (
+	errorType    = reflect.TypeOf((*error)(nil)).Elem()
+	durationType = reflect.TypeOf(time.Duration(0))
+	timeType     = reflect.TypeOf(time.Time{})
+)
+
+// FromGo converts a Go value to a Monkey value: nil to null, booleans,
+// integers, floats and strings to BOOLEAN, INTEGER, FLOAT and STRING,
+// time.Duration and time.Time to DURATION and TIME, slices and arrays to
+// ARRAY and maps with string keys to HASH. A GoValue or any other Object
+// is returned as it is. Other values, including structs and slices or maps
+// that contain themselves, can't be converted; wrap them in a GoValue
+// instead.
+func FromGo(v any) (Object, error) {
+	if obj, ok := v.(Object); ok {
+		return obj, nil
+	}
+	return fromGo(reflect.ValueOf(v), nil, goVisited{})
+}
+
+// goVisited records the slices and maps enclosing the Go value being
+// converted, like Visited does for Monkey values. A slice is identified by
+// its length as well, since a shorter slice of the same array is another
+// value.
+type goVisited map[goVisit]bool
+
+type goVisit struct {
+	ptr uintptr
+	typ reflect.Type
+	len int
+}
+
+// fromGo converts v like FromGo, passing structs and pointers to them to
+// wrap if it isn't nil.
+func fromGo(v reflect.Value, wrap func(reflect.Value) Object, visited goVisited) (Object, error) {
+	if !v.IsValid() {
+		return NullValue, nil
+	}
+	if v.CanInterface() {
+		if obj, ok := v.Interface().(Object); ok {
+			return obj, nil
+		}
+	}
+
+	switch v.Type() {
+	case durationType:
+		return &Duration{Value: time.Duration(v.Int())}, nil
+	case timeType:
+		if v.CanInterface() {
+			return &Time{Value: v.Interface().(time.Time)}, nil
+		}
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		return BooleanValue(v.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return &Integer{Value: v.Int()}, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if v.Uint() > 1<<63-1 {
+			return nil, fmt.Errorf("%d overflows INTEGER", v.Uint())
+		}
+		return &Integer{Value: int64(v.Uint())}, nil
+	case reflect.Float32, reflect.Float64:
+		return &Float{Value: v.Float()}, nil
+	case reflect.String:
+		return &String{Value: v.String()}, nil
+	case reflect.Interface:
+		return fromGo(v.Elem(), wrap, visited)
+	case reflect.Pointer:
+		if v.IsNil() {
+			return NullValue, nil
+		}
+		if wrap != nil && v.Elem().Kind() == reflect.Struct {
+			return wrap(v), nil
+		}
+		return fromGo(v.Elem(), wrap, visited)
+	case reflect.Struct:
+		if wrap != nil {
+			return wrap(v), nil
+		}
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice {
+			if v.IsNil() {
+				return NullValue, nil
+			}
+			visit := goVisit{v.Pointer(), v.Type(), v.Len()}
+			if visited[visit] {
+				return nil, fmt.Errorf("cannot convert %s that contains itself", v.Type())
+			}
+			visited[visit] = true
+			defer delete(visited, visit)
+		}
+		elements := make([]Object, v.Len())
+		for i := range elements {
+			elem, err := fromGo(v.Index(i), wrap, visited)
+			if err != nil {
+				return nil, err
+			}
+			elements[i] = elem
+		}
+		return &Array{Elements: elements}, nil
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			break
+		}
+		if v.IsNil() {
+			return NullValue, nil
+		}
+		visit := goVisit{v.Pointer(), v.Type(), 0}
+		if visited[visit] {
+			return nil, fmt.Errorf("cannot convert %s that contains itself", v.Type())
+		}
+		visited[visit] = true
+		defer delete(visited, visit)
+
+		pairs := make(map[HashKey]HashPair, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			value, err := fromGo(iter.Value(), wrap, visited)
+			if err != nil {
+				return nil, err
+			}
+			key := &String{Value: iter.Key().String()}
+			pairs[key.HashKey()] = HashPair{Key: key, Value: value}
+		}
+		return &Hash{Pairs: pairs}, nil
+	}
+
+	return nil, fmt.Errorf("cannot convert %s to a Monkey value", v.Type())
+}
+
+// ToGo converts obj to a Go value of type T, reversing FromGo. Integers
+// and floats must fit T, and the element and value types of slices and
+// maps are converted recursively. When T is an interface type, null becomes
+// nil, BOOLEAN bool, INTEGER int64, FLOAT float64, STRING string, DURATION
+// time.Duration, TIME time.Time, ARRAY []any, HASH with string keys
+// map[string]any and a GoValue the value it holds. Functions, and arrays
+// and hashes that contain themselves, can't be converted.
+func ToGo[T any](obj Object) (T, error) {
+	var zero T
+	v, err := toGo(obj, reflect.TypeOf(&zero).Elem(), Visited{})
+	if err != nil {
+		return zero, err
+	}
+	return v.Interface().(T), nil
+}
+
+// ToGoValue is ToGo for a type only known at run time.
+func ToGoValue(obj Object, typ reflect.Type) (reflect.Value, error) {
+	return toGo(obj, typ, Visited{})
+}
+
+var anyType = reflect.TypeOf((*any)(nil)).Elem()
+
+func toGo(obj Object, typ reflect.Type, visited Visited) (reflect.Value, error) {
+	if reflect.TypeOf(obj).AssignableTo(typ) && typ != anyType {
+		return reflect.ValueOf(obj), nil
+	}
+
+	if typ.Kind() == reflect.Interface {
+		if obj == NullValue {
+			return reflect.Zero(typ), nil
+		}
+		dt := defaultType(obj)
+		if dt == nil || !dt.AssignableTo(typ) {
+			return reflect.Value{}, fmt.Errorf("cannot use %s as %s", obj.Type(), typ)
+		}
+		v, err := toGo(obj, dt, visited)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		result := reflect.New(typ).Elem()
+		result.Set(v)
+		return result, nil
+	}
+
+	switch obj := obj.(type) {
+	case *Null:
+		switch typ.Kind() {
+		case reflect.Pointer, reflect.Slice, reflect.Map, reflect.Func, reflect.Chan:
+			return reflect.Zero(typ), nil
+		}
+	case *Boolean:
+		if typ.Kind() == reflect.Bool {
+			return reflect.ValueOf(obj.Value).Convert(typ), nil
+		}
+	case *Integer:
+		switch typ.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			v := reflect.New(typ).Elem()
+			if v.OverflowInt(obj.Value) {
+				return reflect.Value{}, fmt.Errorf("%d overflows %s", obj.Value, typ)
+			}
+			v.SetInt(obj.Value)
+			return v, nil
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+			v := reflect.New(typ).Elem()
+			if obj.Value < 0 || v.OverflowUint(uint64(obj.Value)) {
+				return reflect.Value{}, fmt.Errorf("%d overflows %s", obj.Value, typ)
+			}
+			v.SetUint(uint64(obj.Value))
+			return v, nil
+		}
+	case *Float:
+		if typ.Kind() == reflect.Float32 || typ.Kind() == reflect.Float64 {
+			v := reflect.New(typ).Elem()
+			if v.OverflowFloat(obj.Value) {
+				return reflect.Value{}, fmt.Errorf("%s overflows %s", obj.Inspect(), typ)
+			}
+			v.SetFloat(obj.Value)
+			return v, nil
+		}
+	case *Duration:
+		if typ == durationType {
+			return reflect.ValueOf(obj.Value), nil
+		}
+	case *Time:
+		if typ == timeType {
+			return reflect.ValueOf(obj.Value), nil
+		}
+	case *String:
+		if typ.Kind() == reflect.String {
+			return reflect.ValueOf(obj.Value).Convert(typ), nil
+		}
+	case *Array:
+		if typ.Kind() == reflect.Slice {
+			if visited.Enter(obj) {
+				return reflect.Value{}, fmt.Errorf("cannot convert an ARRAY that contains itself")
+			}
+			defer visited.Leave(obj)
+
+			v := reflect.MakeSlice(typ, len(obj.Elements), len(obj.Elements))
+			for i, elem := range obj.Elements {
+				ev, err := toGo(elem, typ.Elem(), visited)
+				if err != nil {
+					return reflect.Value{}, fmt.Errorf("element %d: %w", i, err)
+				}
+				v.Index(i).Set(ev)
+			}
+			return v, nil
+		}
+	case *Hash:
+		if typ.Kind() == reflect.Map && typ.Key().Kind() == reflect.String {
+			if visited.Enter(obj) {
+				return reflect.Value{}, fmt.Errorf("cannot convert a HASH that contains itself")
+			}
+			defer visited.Leave(obj)
+
+			v := reflect.MakeMapWithSize(typ, len(obj.Pairs))
+			for _, pair := range obj.Pairs {
+				key, ok := pair.Key.(*String)
+				if !ok {
+					return reflect.Value{}, fmt.Errorf("cannot use %s key %s in %s",
+						pair.Key.Type(), pair.Key.Inspect(), typ)
+				}
+				ev, err := toGo(pair.Value, typ.Elem(), visited)
+				if err != nil {
+					return reflect.Value{}, fmt.Errorf("key %q: %w", key.Value, err)
+				}
+				v.SetMapIndex(reflect.ValueOf(key.Value).Convert(typ.Key()), ev)
+			}
+			return v, nil
+		}
+	case *GoValue:
+		v := reflect.ValueOf(obj.Value)
+		if v.IsValid() && v.Type().AssignableTo(typ) {
+			return v, nil
+		}
+	}
+
+	return reflect.Value{}, fmt.Errorf("cannot use %s as %s", obj.Type(), typ)
+}
+
+// defaultType returns the Go type obj converts to when the target is an
+// interface, or nil if there is none.
+func defaultType(obj Object) reflect.Type {
+	switch obj := obj.(type) {
+	case *Boolean:
+		return reflect.TypeOf(false)
+	case *Integer:
+		return reflect.TypeOf(int64(0))
+	case *Float:
+		return reflect.TypeOf(float64(0))
+	case *String:
+		return reflect.TypeOf("")
+	case *Duration:
+		return durationType
+	case *Time:
+		return timeType
+	case *Array:
+		return reflect.TypeOf([]any(nil))
+	case *Hash:
+		return reflect.TypeOf(map[string]any(nil))
+	case *GoValue:
+		if obj.Value != nil {
+			return reflect.TypeOf(obj.Value)
+		}
+	}
+	return nil
+}
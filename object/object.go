@@ -2,18 +2,23 @@ package object
 
 import (
 	"bytes"
+	"cmp"
 	"fmt"
-	"hash/fnv"
+	"math"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/rock619/monkey/ast"
+	"github.com/rock619/monkey/token"
 )
 
 type ObjectType string
 
 const (
 	INTEGER_OBJ      = "INTEGER"
+	FLOAT_OBJ        = "FLOAT"
 	BOOLEAN_OBJ      = "BOOLEAN"
 	NULL_OBJ         = "NULL"
 	RETURN_VALUE_OBJ = "RETURN_VALUE"
@@ -23,6 +28,8 @@ const (
 	BUILTIN_OBJ      = "BUILTIN"
 	ARRAY_OBJ        = "ARRAY"
 	HASH_OBJ         = "HASH"
+	TIME_OBJ         = "TIME"
+	DURATION_OBJ     = "DURATION"
 )
 
 type Object interface {
@@ -42,6 +49,24 @@ func (i *Integer) Inspect() string {
 	return strconv.FormatInt(i.Value, 10)
 }
 
+type Float struct {
+	Value float64
+}
+
+func (f *Float) Type() ObjectType {
+	return FLOAT_OBJ
+}
+
+// Inspect formats f in the shortest form that reads back as the same value,
+// keeping a ".0" on whole numbers so that they don't look like integers.
+func (f *Float) Inspect() string {
+	s := strconv.FormatFloat(f.Value, 'g', -1, 64)
+	if !strings.ContainsAny(s, ".eIN") {
+		s += ".0"
+	}
+	return s
+}
+
 type Boolean struct {
 	Value bool
 }
@@ -54,6 +79,22 @@ func (b *Boolean) Inspect() string {
 	return strconv.FormatBool(b.Value)
 }
 
+// The only true, false and null values. Packages creating booleans or null
+// must use these so that values can be compared by pointer.
+var (
+	TrueValue  = &Boolean{Value: true}
+	FalseValue = &Boolean{Value: false}
+	NullValue  = &Null{}
+)
+
+// BooleanValue returns TrueValue or FalseValue.
+func BooleanValue(b bool) *Boolean {
+	if b {
+		return TrueValue
+	}
+	return FalseValue
+}
+
 type Null struct{}
 
 func (n *Null) Type() ObjectType {
@@ -73,10 +114,17 @@ func (rv *ReturnValue) Inspect() string  { return rv.Value.Inspect() }
 
 type Error struct {
 	Message string
+	// Pos is the position of the node whose evaluation failed, if known.
+	Pos token.Position
 }
 
 func (e *Error) Type() ObjectType { return ERROR_OBJ }
-func (e *Error) Inspect() string  { return "ERROR: " + e.Message }
+func (e *Error) Inspect() string {
+	if e.Pos.IsValid() {
+		return "ERROR: " + e.Message + " at " + e.Pos.String()
+	}
+	return "ERROR: " + e.Message
+}
 
 type Function struct {
 	Parameters []*ast.Identifier
@@ -110,6 +158,46 @@ type String struct {
 func (s *String) Type() ObjectType { return STRING_OBJ }
 func (s *String) Inspect() string  { return s.Value }
 
+// InspectElement returns obj as it is shown inside an array or hash: like
+// Inspect, except that strings are quoted and escaped so that elements
+// containing ", " remain unambiguous.
+func InspectElement(obj Object) string {
+	return inspectElement(obj, Visited{})
+}
+
+// Visited records the arrays and hashes enclosing the value being
+// processed, so that recursive operations on values that contain
+// themselves can stop instead of looping forever.
+type Visited map[Object]bool
+
+// Enter marks obj as being processed and reports whether it already was,
+// in which case obj contains itself and must not be entered again.
+func (v Visited) Enter(obj Object) bool {
+	if v[obj] {
+		return true
+	}
+	v[obj] = true
+	return false
+}
+
+// Leave marks obj as processed.
+func (v Visited) Leave(obj Object) {
+	delete(v, obj)
+}
+
+func inspectElement(obj Object, visited Visited) string {
+	switch obj := obj.(type) {
+	case *String:
+		return strconv.Quote(obj.Value)
+	case *Array:
+		return obj.inspect(visited)
+	case *Hash:
+		return obj.inspect(visited)
+	default:
+		return obj.Inspect()
+	}
+}
+
 type BuiltinFunction func(args ...Object) Object
 
 type Builtin struct {
@@ -124,12 +212,20 @@ type Array struct {
 }
 
 func (ao *Array) Type() ObjectType { return ARRAY_OBJ }
-func (ao *Array) Inspect() string {
+func (ao *Array) Inspect() string  { return ao.inspect(Visited{}) }
+
+// inspect shows an array that contains itself as [...] where it recurs.
+func (ao *Array) inspect(visited Visited) string {
+	if visited.Enter(ao) {
+		return "[...]"
+	}
+	defer visited.Leave(ao)
+
 	var out bytes.Buffer
 
 	elements := []string{}
 	for _, e := range ao.Elements {
-		elements = append(elements, e.Inspect())
+		elements = append(elements, inspectElement(e, visited))
 	}
 
 	out.WriteString("[")
@@ -139,9 +235,19 @@ func (ao *Array) Inspect() string {
 	return out.String()
 }
 
+// A HashKey identifies a hashable value in a Hash. Keys are stable: equal
+// values have equal keys, and the key of a value is the same in every
+// process, so that hashes built by different evaluators, converted from Go
+// or restored from snapshots find each other's entries. Values of different
+// types, such as 1 and "1", never share a key, except that a float with a
+// whole value shares the key of the equal integer, as 5.0 == 5.
 type HashKey struct {
 	Type  ObjectType
 	Value uint64
+
+	// text is the value of a string key, which is compared by its text
+	// rather than a hash of it, so different strings never collide.
+	text string
 }
 
 func (b *Boolean) HashKey() HashKey {
@@ -160,11 +266,19 @@ func (i *Integer) HashKey() HashKey {
 	return HashKey{Type: i.Type(), Value: uint64(i.Value)}
 }
 
-func (s *String) HashKey() HashKey {
-	h := fnv.New64a()
-	h.Write([]byte(s.Value))
+// HashKey returns the key of f. A whole float in the range of integers has
+// the key of that integer, so h[5] and h[5.0] are the same entry; any other
+// float is keyed by its bits.
+func (f *Float) HashKey() HashKey {
+	if f.Value == math.Trunc(f.Value) && f.Value >= math.MinInt64 && f.Value < math.MaxInt64 {
+		return (&Integer{Value: int64(f.Value)}).HashKey()
+	}
+	return HashKey{Type: f.Type(), Value: math.Float64bits(f.Value)}
+}
 
-	return HashKey{Type: s.Type(), Value: h.Sum64()}
+// HashKey returns the key of s, which holds s.Value itself.
+func (s *String) HashKey() HashKey {
+	return HashKey{Type: s.Type(), text: s.Value}
 }
 
 type HashPair struct {
@@ -177,13 +291,21 @@ type Hash struct {
 }
 
 func (h *Hash) Type() ObjectType { return HASH_OBJ }
-func (h *Hash) Inspect() string {
+func (h *Hash) Inspect() string  { return h.inspect(Visited{}) }
+
+// inspect shows a hash that contains itself as {...} where it recurs.
+func (h *Hash) inspect(visited Visited) string {
+	if visited.Enter(h) {
+		return "{...}"
+	}
+	defer visited.Leave(h)
+
 	var out bytes.Buffer
 
 	pairs := []string{}
-	for _, pair := range h.Pairs {
+	for _, pair := range h.SortedPairs() {
 		pairs = append(pairs, fmt.Sprintf("%s: %s",
-			pair.Key.Inspect(), pair.Value.Inspect()))
+			inspectElement(pair.Key, visited), inspectElement(pair.Value, visited)))
 	}
 
 	out.WriteString("{")
@@ -193,6 +315,109 @@ func (h *Hash) Inspect() string {
 	return out.String()
 }
 
+// SortedPairs returns the pairs of h with their keys in the order of
+// CompareKeys, so that a hash always reads the same.
+func (h *Hash) SortedPairs() []HashPair {
+	pairs := make([]HashPair, 0, len(h.Pairs))
+	for _, pair := range h.Pairs {
+		pairs = append(pairs, pair)
+	}
+	sort.Slice(pairs, func(i, j int) bool {
+		return CompareKeys(pairs[i].Key, pairs[j].Key) < 0
+	})
+	return pairs
+}
+
+// CompareKeys orders hash keys by the name of their type, so BOOLEAN keys
+// come first and TIME keys last, and keys of a type by value: false before
+// true, numbers and durations from the smallest, strings byte by byte and
+// times from the earliest. Integers and floats are ordered together by
+// value, the float first if they are equal.
+func CompareKeys(a, b Object) int {
+	switch a := a.(type) {
+	case *Integer:
+		if b, ok := b.(*Float); ok {
+			if c := cmp.Compare(float64(a.Value), b.Value); c != 0 {
+				return c
+			}
+			return 1
+		}
+	case *Float:
+		if b, ok := b.(*Integer); ok {
+			if c := cmp.Compare(a.Value, float64(b.Value)); c != 0 {
+				return c
+			}
+			return -1
+		}
+	}
+
+	if a.Type() != b.Type() {
+		return strings.Compare(string(a.Type()), string(b.Type()))
+	}
+
+	switch a := a.(type) {
+	case *Boolean:
+		switch {
+		case a.Value == b.(*Boolean).Value:
+			return 0
+		case a.Value:
+			return 1
+		default:
+			return -1
+		}
+	case *Integer:
+		return cmp.Compare(a.Value, b.(*Integer).Value)
+	case *Float:
+		return cmp.Compare(a.Value, b.(*Float).Value)
+	case *Duration:
+		return cmp.Compare(a.Value, b.(*Duration).Value)
+	case *String:
+		return strings.Compare(a.Value, b.(*String).Value)
+	case *Time:
+		return a.Value.Compare(b.(*Time).Value)
+	default:
+		return strings.Compare(a.Inspect(), b.Inspect())
+	}
+}
+
+// Time is an instant. Times are equal, and have the same hash key, if they
+// are the same instant, whatever their locations.
+type Time struct {
+	Value time.Time
+}
+
+func (t *Time) Type() ObjectType { return TIME_OBJ }
+func (t *Time) Inspect() string  { return t.Value.Format(time.RFC3339Nano) }
+
+func (t *Time) HashKey() HashKey {
+	value := uint64(t.Value.Unix())*uint64(time.Second) + uint64(t.Value.Nanosecond())
+	return HashKey{Type: t.Type(), Value: value}
+}
+
+type Duration struct {
+	Value time.Duration
+}
+
+func (d *Duration) Type() ObjectType { return DURATION_OBJ }
+func (d *Duration) Inspect() string  { return d.Value.String() }
+
+func (d *Duration) HashKey() HashKey {
+	return HashKey{Type: d.Type(), Value: uint64(d.Value)}
+}
+
 type Hashable interface {
 	HashKey() HashKey
 }
+
+// Sized is implemented by collections. len returns Len, and with extended
+// truthiness a collection of length zero is falsy.
+type Sized interface {
+	Len() int
+}
+
+// Len returns the length of s in bytes.
+func (s *String) Len() int { return len(s.Value) }
+
+func (ao *Array) Len() int { return len(ao.Elements) }
+
+func (h *Hash) Len() int { return len(h.Pairs) }
@@ -1,6 +1,13 @@
 package object
 
-import "testing"
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
 
 func TestStringHashKey(t *testing.T) {
 	hello1 := &String{Value: "Hello World"}
@@ -20,3 +27,381 @@ func TestStringHashKey(t *testing.T) {
 		t.Errorf("strings with different content have same hash keys")
 	}
 }
+
+func TestHashKeyStability(t *testing.T) {
+	tests := []struct {
+		key      Hashable
+		expected HashKey
+	}{
+		// Golden values: keys must not change between processes or
+		// releases, as hashes built elsewhere depend on them.
+		{&String{Value: ""}, HashKey{Type: STRING_OBJ, text: ""}},
+		{&String{Value: "monkey"}, HashKey{Type: STRING_OBJ, text: "monkey"}},
+		{&String{Value: "1"}, HashKey{Type: STRING_OBJ, text: "1"}},
+		{&Integer{Value: 1}, HashKey{Type: INTEGER_OBJ, Value: 1}},
+		{&Integer{Value: -1}, HashKey{Type: INTEGER_OBJ, Value: 1<<64 - 1}},
+		{TrueValue, HashKey{Type: BOOLEAN_OBJ, Value: 1}},
+		{FalseValue, HashKey{Type: BOOLEAN_OBJ, Value: 0}},
+		{&Float{Value: 5}, HashKey{Type: INTEGER_OBJ, Value: 5}},
+		{&Float{Value: -1}, HashKey{Type: INTEGER_OBJ, Value: 1<<64 - 1}},
+		{&Float{Value: 2.5}, HashKey{Type: FLOAT_OBJ, Value: 0x4004000000000000}},
+		{&Float{Value: 1e300}, HashKey{Type: FLOAT_OBJ, Value: 0x7e37e43c8800759c}},
+	}
+
+	for _, tt := range tests {
+		for i := 0; i < 2; i++ {
+			if key := tt.key.HashKey(); key != tt.expected {
+				t.Errorf("%v: wrong key on call %d. want=%+v, got=%+v", tt.key, i+1, tt.expected, key)
+			}
+		}
+	}
+
+	// The integer 1, the string "1" and true are different keys.
+	keys := map[HashKey]bool{}
+	for _, key := range []Hashable{&Integer{Value: 1}, &String{Value: "1"}, TrueValue} {
+		keys[key.HashKey()] = true
+	}
+	if len(keys) != 3 {
+		t.Errorf("keys of different types collide: %v", keys)
+	}
+}
+
+func TestSized(t *testing.T) {
+	tests := []struct {
+		obj      Sized
+		expected int
+	}{
+		{&String{Value: ""}, 0},
+		{&String{Value: "héllo"}, 6},
+		{&Array{}, 0},
+		{&Array{Elements: []Object{&Integer{Value: 1}, &Null{}}}, 2},
+		{&Hash{Pairs: map[HashKey]HashPair{}}, 0},
+		{&Hash{Pairs: map[HashKey]HashPair{
+			(&Integer{Value: 1}).HashKey(): {Key: &Integer{Value: 1}, Value: &Null{}},
+		}}, 1},
+	}
+
+	for _, tt := range tests {
+		if got := tt.obj.Len(); got != tt.expected {
+			t.Errorf("%T.Len() wrong. got=%d, want=%d", tt.obj, got, tt.expected)
+		}
+	}
+}
+
+func TestEnvironmentAssign(t *testing.T) {
+	outer := NewEnvironment()
+	outer.Set("x", &Integer{Value: 1})
+	inner := NewEnclosedEnvironment(outer)
+
+	if err := inner.Assign("x", &Integer{Value: 2}); err != nil {
+		t.Fatalf("Assign returned %v", err)
+	}
+	if x, _ := outer.Get("x"); x.(*Integer).Value != 2 {
+		t.Errorf("x was not assigned in the declaring environment. got=%s", x.Inspect())
+	}
+
+	if err := inner.Assign("y", &Integer{Value: 1}); err != ErrUndeclared {
+		t.Errorf("wrong error for undeclared name. got=%v", err)
+	}
+
+	outer.Freeze()
+	if err := inner.Assign("x", &Integer{Value: 3}); err != ErrReadOnly {
+		t.Errorf("wrong error for frozen name. got=%v", err)
+	}
+	inner.Set("x", &Integer{Value: 4})
+	if err := inner.Assign("x", &Integer{Value: 5}); err != nil {
+		t.Errorf("shadowing name not assignable: %v", err)
+	}
+}
+
+func TestEnvironmentCopy(t *testing.T) {
+	prelude := NewEnvironment()
+	prelude.Set("p", &Integer{Value: 1})
+	prelude.Freeze()
+	env := NewEnclosedEnvironment(prelude)
+	env.Set("x", &Integer{Value: 1})
+
+	c := env.Copy()
+	if err := c.Assign("x", &Integer{Value: 2}); err != nil {
+		t.Fatalf("Assign returned %v", err)
+	}
+	if x, _ := env.Get("x"); x.(*Integer).Value != 1 {
+		t.Errorf("assigning in the copy changed the original. got=%s", x.Inspect())
+	}
+	if x, _ := c.Get("x"); x.(*Integer).Value != 2 {
+		t.Errorf("x was not assigned in the copy. got=%s", x.Inspect())
+	}
+	if err := c.Assign("p", &Integer{Value: 2}); err != ErrReadOnly {
+		t.Errorf("frozen name assignable in the copy: %v", err)
+	}
+}
+
+func TestBooleanValue(t *testing.T) {
+	if BooleanValue(true) != TrueValue || BooleanValue(false) != FalseValue {
+		t.Errorf("BooleanValue doesn't return the singletons")
+	}
+	if TrueValue.HashKey() == FalseValue.HashKey() {
+		t.Errorf("true and false have the same hash key")
+	}
+}
+
+func TestFloatInspect(t *testing.T) {
+	tests := []struct {
+		value    float64
+		expected string
+	}{
+		{1.5, "1.5"},
+		{2, "2.0"},
+		{-0.1, "-0.1"},
+		{1e21, "1e+21"},
+		{math.Inf(1), "+Inf"},
+		{math.NaN(), "NaN"},
+	}
+
+	for _, tt := range tests {
+		if got := (&Float{Value: tt.value}).Inspect(); got != tt.expected {
+			t.Errorf("Inspect of %v = %q, want %q", tt.value, got, tt.expected)
+		}
+	}
+}
+
+func TestInspectQuotesNestedStrings(t *testing.T) {
+	str := func(s string) *String { return &String{Value: s} }
+	pair := func(k, v Object) map[HashKey]HashPair {
+		return map[HashKey]HashPair{k.(Hashable).HashKey(): {Key: k, Value: v}}
+	}
+
+	tests := []struct {
+		obj      Object
+		expected string
+	}{
+		{str("a,b"), "a,b"},
+		{&Array{Elements: []Object{str("a,b"), str("c")}}, `["a,b", "c"]`},
+		{&Array{Elements: []Object{str(`say "hi"`), str("tab\there\n")}}, `["say \"hi\"", "tab\there\n"]`},
+		{&Array{Elements: []Object{&Array{Elements: []Object{str("x")}}, &Integer{Value: 1}, str("")}}, `[["x"], 1, ""]`},
+		{&Hash{Pairs: pair(str("k"), &Array{Elements: []Object{str("v")}})}, `{"k": ["v"]}`},
+		{&Hash{Pairs: pair(&Integer{Value: 1}, str("ü"))}, `{1: "ü"}`},
+	}
+
+	for _, tt := range tests {
+		if got := tt.obj.Inspect(); got != tt.expected {
+			t.Errorf("wrong Inspect. expected=%s, got=%s", tt.expected, got)
+		}
+	}
+}
+
+func TestInspectCycles(t *testing.T) {
+	arr := &Array{Elements: []Object{&Integer{Value: 1}}}
+	arr.Elements = append(arr.Elements, arr)
+	if got := arr.Inspect(); got != "[1, [...]]" {
+		t.Errorf("wrong Inspect of self-referencing array. got=%s", got)
+	}
+
+	key := &String{Value: "self"}
+	hash := &Hash{Pairs: map[HashKey]HashPair{}}
+	hash.Pairs[key.HashKey()] = HashPair{Key: key, Value: &Array{Elements: []Object{hash}}}
+	if got := hash.Inspect(); got != `{"self": [{...}]}` {
+		t.Errorf("wrong Inspect of self-referencing hash. got=%s", got)
+	}
+
+	// A value that appears twice without containing itself isn't a cycle.
+	shared := &Array{Elements: []Object{&Integer{Value: 2}}}
+	twice := &Array{Elements: []Object{shared, shared}}
+	if got := twice.Inspect(); got != "[[2], [2]]" {
+		t.Errorf("wrong Inspect of shared array. got=%s", got)
+	}
+}
+
+func TestInspectHashOrder(t *testing.T) {
+	hash := &Hash{Pairs: map[HashKey]HashPair{}}
+	for _, key := range []Hashable{
+		&String{Value: "c"}, &String{Value: "a"}, &String{Value: "b"},
+		&Integer{Value: 10}, &Integer{Value: -1}, TrueValue, FalseValue,
+		&Float{Value: 2.5}, &Float{Value: -0.5},
+	} {
+		hash.Pairs[key.HashKey()] = HashPair{Key: key.(Object), Value: &Integer{Value: 1}}
+	}
+
+	expected := `{false: 1, true: 1, -1: 1, -0.5: 1, 2.5: 1, 10: 1, "a": 1, "b": 1, "c": 1}`
+	for i := 0; i < 20; i++ {
+		if got := hash.Inspect(); got != expected {
+			t.Fatalf("wrong Inspect of hash. expected=%s, got=%s", expected, got)
+		}
+	}
+}
+
+func TestStringKeysKeepSeparateEntries(t *testing.T) {
+	texts := []string{"", "a", "b", "ab", "ba", "a\x00", "\x00a", "1", "monkey", "Monkey"}
+
+	hash := &Hash{Pairs: map[HashKey]HashPair{}}
+	for i, text := range texts {
+		key := &String{Value: text}
+		hash.Pairs[key.HashKey()] = HashPair{Key: key, Value: &Integer{Value: int64(i)}}
+	}
+
+	if len(hash.Pairs) != len(texts) {
+		t.Fatalf("different strings share entries. got=%d entries, want=%d", len(hash.Pairs), len(texts))
+	}
+	for i, text := range texts {
+		pair, ok := hash.Pairs[(&String{Value: text}).HashKey()]
+		if !ok {
+			t.Errorf("no entry for %q", text)
+			continue
+		}
+		if value := pair.Value.(*Integer).Value; value != int64(i) || pair.Key.(*String).Value != text {
+			t.Errorf("wrong entry for %q. got %s: %d", text, pair.Key.Inspect(), value)
+		}
+	}
+}
+
+func TestFromGo(t *testing.T) {
+	tests := []struct {
+		input    any
+		expected string
+	}{
+		{nil, "null"},
+		{true, "true"},
+		{int8(-3), "-3"},
+		{uint16(7), "7"},
+		{"a", "a"},
+		{[]string{"x", "y"}, `["x", "y"]`},
+		{[2]int{1, 2}, "[1, 2]"},
+		{map[string]any{"k": []any{1, nil}}, `{"k": [1, null]}`},
+		{&Integer{Value: 5}, "5"},
+		{1.5, "1.5"},
+		{float32(0.25), "0.25"},
+		{90 * time.Second, "1m30s"},
+		{time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC), "2024-01-02T03:04:05Z"},
+	}
+
+	for _, tt := range tests {
+		obj, err := FromGo(tt.input)
+		if err != nil {
+			t.Errorf("FromGo(%#v): %s", tt.input, err)
+			continue
+		}
+		if obj.Inspect() != tt.expected {
+			t.Errorf("FromGo(%#v) = %s, want %s", tt.input, obj.Inspect(), tt.expected)
+		}
+	}
+
+	for _, input := range []any{struct{}{}, map[int]int{}, uint64(1 << 63)} {
+		if _, err := FromGo(input); err == nil {
+			t.Errorf("FromGo(%#v): expected an error", input)
+		}
+	}
+}
+
+func TestToGo(t *testing.T) {
+	hash, _ := FromGo(map[string]any{"a": []any{int64(1), "b", true, nil}})
+
+	got, err := ToGo[any](hash)
+	if err != nil {
+		t.Fatalf("ToGo: %s", err)
+	}
+	if fmt.Sprint(got) != "map[a:[1 b true <nil>]]" {
+		t.Errorf("wrong result. got=%#v", got)
+	}
+
+	ints, err := ToGo[[]int8](&Array{Elements: []Object{&Integer{Value: 1}, &Integer{Value: 2}}})
+	if err != nil || len(ints) != 2 || ints[1] != 2 {
+		t.Errorf("wrong result. got=%v, %v", ints, err)
+	}
+
+	errorTests := []struct {
+		obj      Object
+		convert  func(Object) error
+		expected string
+	}{
+		{&Integer{Value: 300}, func(o Object) error { _, err := ToGo[int8](o); return err }, "300 overflows int8"},
+		{&Integer{Value: -1}, func(o Object) error { _, err := ToGo[uint](o); return err }, "-1 overflows uint"},
+		{&String{Value: "x"}, func(o Object) error { _, err := ToGo[int](o); return err }, "cannot use STRING as int"},
+		{&Function{}, func(o Object) error { _, err := ToGo[any](o); return err }, "cannot use FUNCTION as interface {}"},
+		{&Array{Elements: []Object{TrueValue}}, func(o Object) error { _, err := ToGo[[]string](o); return err },
+			"element 0: cannot use BOOLEAN as string"},
+		{&Float{Value: 1e300}, func(o Object) error { _, err := ToGo[float32](o); return err }, "1e+300 overflows float32"},
+		{&Float{Value: 1.5}, func(o Object) error { _, err := ToGo[int](o); return err }, "cannot use FLOAT as int"},
+	}
+	for _, tt := range errorTests {
+		err := tt.convert(tt.obj)
+		if err == nil || err.Error() != tt.expected {
+			t.Errorf("wrong error for %s. got=%v, want=%q", tt.obj.Inspect(), err, tt.expected)
+		}
+	}
+}
+
+func TestGoCycles(t *testing.T) {
+	slice := []any{1, nil}
+	slice[1] = slice
+	m := map[string]any{}
+	m["self"] = []any{m}
+	for _, input := range []any{slice, m} {
+		if _, err := FromGo(input); err == nil || !strings.HasSuffix(err.Error(), "that contains itself") {
+			t.Errorf("FromGo of a value containing itself: wrong error %v", err)
+		}
+	}
+
+	arr := &Array{Elements: []Object{&Integer{Value: 1}}}
+	arr.Elements = append(arr.Elements, arr)
+	key := &String{Value: "self"}
+	hash := &Hash{Pairs: map[HashKey]HashPair{}}
+	hash.Pairs[key.HashKey()] = HashPair{Key: key, Value: &Array{Elements: []Object{hash}}}
+	for _, obj := range []Object{arr, hash} {
+		if _, err := ToGo[any](obj); err == nil || !strings.HasSuffix(err.Error(), "that contains itself") {
+			t.Errorf("ToGo of a value containing itself: wrong error %v", err)
+		}
+	}
+
+	// Values that appear twice without containing themselves convert.
+	shared := []any{int64(2)}
+	got, err := FromGo([]any{shared, shared, shared[:0]})
+	if err != nil || got.Inspect() != "[[2], [2], []]" {
+		t.Errorf("FromGo of a shared slice = %v, %v", got, err)
+	}
+	twice := &Array{Elements: []Object{arr.Elements[0], arr.Elements[0]}}
+	if v, err := ToGo[[]int](twice); err != nil || len(v) != 2 {
+		t.Errorf("ToGo of a shared value = %v, %v", v, err)
+	}
+}
+
+func TestGoRoundTrip(t *testing.T) {
+	tests := []any{
+		true,
+		int64(-7),
+		1.5,
+		float32(0.25),
+		"s",
+		90 * time.Second,
+		time.Date(2024, 1, 2, 3, 4, 5, 6, time.UTC),
+		[]float64{0.5, 2},
+		map[string]time.Duration{"d": time.Millisecond},
+	}
+
+	for _, input := range tests {
+		obj, err := FromGo(input)
+		if err != nil {
+			t.Errorf("FromGo(%#v): %s", input, err)
+			continue
+		}
+		v, err := ToGoValue(obj, reflect.TypeOf(input))
+		if err != nil {
+			t.Errorf("ToGo(%s): %s", obj.Inspect(), err)
+			continue
+		}
+		if !reflect.DeepEqual(v.Interface(), input) {
+			t.Errorf("round trip of %#v gave %#v", input, v.Interface())
+		}
+	}
+
+	// Without a type to convert to, FLOAT, DURATION and TIME become
+	// float64, time.Duration and time.Time.
+	hash, _ := FromGo(map[string]any{"f": float32(0.5), "d": time.Second, "t": time.Unix(0, 0).UTC()})
+	got, err := ToGo[map[string]any](hash)
+	if err != nil {
+		t.Fatalf("ToGo: %s", err)
+	}
+	expected := map[string]any{"f": 0.5, "d": time.Second, "t": time.Unix(0, 0).UTC()}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("wrong result. got=%#v, want=%#v", got, expected)
+	}
+}
@@ -0,0 +1,91 @@
+package parser
+
+import (
+	"github.com/rock619/monkey/ast"
+	"github.com/rock619/monkey/lexer"
+	"github.com/rock619/monkey/token"
+)
+
+// PrefixParseFn parses an expression starting at p.CurToken().
+type PrefixParseFn func(p *Parser) ast.Expression
+
+// InfixParseFn parses the rest of an expression whose operator is
+// p.CurToken() and whose left operand has already been parsed.
+type InfixParseFn func(p *Parser, left ast.Expression) ast.Expression
+
+// RegisterPrefix makes p parse expressions starting with a token of type t
+// with fn, replacing any built-in parse function for t.
+func (p *Parser) RegisterPrefix(t token.TokenType, fn PrefixParseFn) {
+	p.registerPrefix(t, func() ast.Expression { return fn(p) })
+}
+
+// RegisterInfix makes p parse t as an infix operator with the given
+// precedence, such as token.SumPrec, replacing any built-in parse function
+// for t.
+func (p *Parser) RegisterInfix(t token.TokenType, precedence int, fn InfixParseFn) {
+	if p.precedences == nil {
+		p.precedences = make(map[token.TokenType]int)
+	}
+	p.precedences[t] = precedence
+	p.registerInfix(t, func(left ast.Expression) ast.Expression { return fn(p, left) })
+}
+
+// Precedence returns the precedence p uses for t as an infix operator.
+func (p *Parser) Precedence(t token.TokenType) int {
+	if prec, ok := p.precedences[t]; ok {
+		return prec
+	}
+	return t.Precedence()
+}
+
+// Precedences returns the precedence of every infix operator p knows,
+// including the registered ones.
+func (p *Parser) Precedences() map[token.TokenType]int {
+	precedences := make(map[token.TokenType]int)
+	for t := range p.infixParseFns {
+		precedences[t] = p.Precedence(t)
+	}
+	return precedences
+}
+
+// Precedences returns the precedence of every infix operator of the
+// dialect described by opts, so that tools such as formatters and
+// highlighters can use the parser's table instead of keeping a copy.
+func Precedences(opts Options) map[token.TokenType]int {
+	return NewWithOptions(lexer.New(""), opts).Precedences()
+}
+
+// The methods below give parse functions registered from outside the
+// package access to the parser's state.
+
+func (p *Parser) CurToken() token.Token  { return p.curToken }
+func (p *Parser) PeekToken() token.Token { return p.peekToken }
+func (p *Parser) NextToken()             { p.nextToken() }
+
+// PeekAhead returns the token n places after the current one without
+// consuming it, so that PeekAhead(1) is PeekToken(). It lets syntax that
+// can only be told apart further ahead be parsed without backtracking.
+func (p *Parser) PeekAhead(n int) token.Token {
+	switch {
+	case n <= 0:
+		return p.curToken
+	case n == 1:
+		return p.peekToken
+	}
+	return p.l.Peek(n - 2)
+}
+
+// ExpectPeek advances to the next token if it has type t and records an
+// error otherwise.
+func (p *Parser) ExpectPeek(t token.TokenType) bool { return p.expectPeek(t) }
+
+// ParseExpression parses an expression starting at the current token,
+// binding operators tighter than precedence.
+func (p *Parser) ParseExpression(precedence int) ast.Expression {
+	return p.parseExpression(precedence)
+}
+
+// Errorf records an error at pos.
+func (p *Parser) Errorf(pos token.Position, format string, a ...any) {
+	p.addError(pos, format, a...)
+}
@@ -0,0 +1,124 @@
+package parser
+
+import (
+	"testing"
+
+	"github.com/rock619/monkey/ast"
+	"github.com/rock619/monkey/lexer"
+	"github.com/rock619/monkey/token"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	PIPE = token.CUSTOM + iota
+	POW
+)
+
+func newExtendedParser(input string) *Parser {
+	l := lexer.New(input)
+	l.RegisterOperator("|>", PIPE)
+	l.RegisterOperator("**", POW)
+
+	p := New(l)
+
+	// x |> f(y) is f(x, y)
+	p.RegisterInfix(PIPE, token.LowestPrec+1, func(p *Parser, left ast.Expression) ast.Expression {
+		tok := p.CurToken()
+		p.NextToken()
+		right := p.ParseExpression(token.LowestPrec + 1)
+
+		call, ok := right.(*ast.CallExpression)
+		if !ok {
+			call = &ast.CallExpression{Token: tok, Function: right}
+		}
+		call.Arguments = append([]ast.Expression{left}, call.Arguments...)
+		return call
+	})
+
+	// a ** b is pow(a, b), binding tighter than * and to the right
+	p.RegisterInfix(POW, token.ProductPrec+1, func(p *Parser, left ast.Expression) ast.Expression {
+		tok := p.CurToken()
+		p.NextToken()
+		right := p.ParseExpression(token.ProductPrec)
+		return &ast.CallExpression{
+			Token:     tok,
+			Function:  &ast.Identifier{Token: tok, Value: "pow"},
+			Arguments: []ast.Expression{left, right},
+		}
+	})
+
+	return p
+}
+
+func TestRegisterInfix(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"x |> f", "f(x)"},
+		{"x |> add(1) |> mul(2)", "mul(add(x, 1), 2)"},
+		{"1 + 2 |> f", "f((1 + 2))"},
+		{"2 * 3 ** 2 ** 2", "(2 * pow(3, pow(2, 2)))"},
+	}
+
+	for _, tt := range tests {
+		p := newExtendedParser(tt.input)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		assert.Equal(t, tt.expected, program.String())
+	}
+}
+
+func TestPrecedences(t *testing.T) {
+	p := newExtendedParser("")
+
+	precedences := p.Precedences()
+	assert.Equal(t, token.SumPrec, precedences[token.PLUS])
+	assert.Equal(t, token.IndexPrec, precedences[token.LBRACKET])
+	assert.Equal(t, token.ProductPrec+1, precedences[POW])
+	assert.Equal(t, token.LowestPrec+1, p.Precedence(PIPE))
+	assert.Equal(t, token.LowestPrec, p.Precedence(token.COMMA))
+}
+
+func TestDialectPrecedences(t *testing.T) {
+	book, _ := LookupDialect(BookDialect)
+	extended, _ := LookupDialect(ExtendedDialect)
+
+	assert.Equal(t, map[token.TokenType]int{
+		token.EQ:       token.EqualsPrec,
+		token.NOT_EQ:   token.EqualsPrec,
+		token.LT:       token.LessGreaterPrec,
+		token.GT:       token.LessGreaterPrec,
+		token.PLUS:     token.SumPrec,
+		token.MINUS:    token.SumPrec,
+		token.SLASH:    token.ProductPrec,
+		token.ASTERISK: token.ProductPrec,
+		token.PERCENT:  token.ProductPrec,
+		token.LPAREN:   token.CallPrec,
+		token.LBRACKET: token.IndexPrec,
+	}, Precedences(book))
+
+	precedences := Precedences(extended)
+	assert.Equal(t, token.AssignPrec, precedences[token.ASSIGN])
+	assert.Equal(t, token.IndexPrec, precedences[token.DOT])
+}
+
+func TestPeekAhead(t *testing.T) {
+	p := New(lexer.New("a b c d"))
+
+	var literals []string
+	for n := 0; n < 6; n++ {
+		literals = append(literals, p.PeekAhead(n).Literal)
+	}
+	assert.Equal(t, []string{"a", "b", "c", "d", "", ""}, literals)
+
+	p.NextToken()
+	assert.Equal(t, "b", p.CurToken().Literal)
+	assert.Equal(t, "d", p.PeekAhead(2).Literal)
+	assert.Equal(t, token.EOF, p.PeekAhead(3).Type)
+
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+	assert.Equal(t, "bcd", program.String())
+}
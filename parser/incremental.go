@@ -0,0 +1,73 @@
+package parser
+
+import (
+	"reflect"
+
+	"github.com/rock619/monkey/ast"
+	"github.com/rock619/monkey/lexer"
+	"github.com/rock619/monkey/token"
+)
+
+// Edit describes a change to the source text at line granularity.
+type Edit struct {
+	// StartLine is the first line (1-based) that differs between the old
+	// and the new text. Everything before it must be unchanged.
+	StartLine int
+}
+
+// Reparse parses src, the text prev was parsed from with edit applied.
+// Top-level statements of prev that end before the edit are reused as they
+// are and only the rest of src is parsed again, so the cost of an edit is
+// proportional to the text after it rather than to the whole file.
+//
+// prev should come from a parse without errors; the returned errors only
+// cover the part of src that was parsed again.
+//
+// Reparse parses src in the default dialect, like New. A program parsed
+// with other options must be reparsed with ReparseWithOptions.
+func Reparse(prev *ast.Program, src string, edit Edit) (*ast.Program, []*Error) {
+	opts, _ := LookupDialect(DefaultDialect)
+	return ReparseWithOptions(prev, src, edit, opts)
+}
+
+// ReparseWithOptions is like Reparse but parses src in the language
+// described by opts, which should be the options prev was parsed with.
+func ReparseWithOptions(prev *ast.Program, src string, edit Edit, opts Options) (*ast.Program, []*Error) {
+	reused := reusableStatements(prev, edit)
+
+	start := token.Position{Line: 1, Column: 1}
+	if reused > 0 {
+		// starts before the edit, see reusableStatements
+		start = prev.Statements[reused].Pos()
+	}
+
+	p := NewWithOptions(lexer.NewAt(src, start), opts)
+	program := p.ParseProgram()
+
+	statements := make([]ast.Statement, 0, reused+len(program.Statements))
+	statements = append(statements, prev.Statements[:reused]...)
+	program.Statements = append(statements, program.Statements...)
+
+	return program, p.ErrorList()
+}
+
+// reusableStatements returns how many leading statements of prev are not
+// affected by edit. A statement is known to end before the edit when the
+// statement following it starts on an earlier line.
+func reusableStatements(prev *ast.Program, edit Edit) int {
+	n := 0
+	for i := 0; i+1 < len(prev.Statements); i++ {
+		current, next := prev.Statements[i], prev.Statements[i+1]
+		if isNil(current) || isNil(next) || next.Pos().Line >= edit.StartLine {
+			break
+		}
+		n++
+	}
+	return n
+}
+
+// isNil reports whether stmt is nil or a typed nil pointer, which the
+// parser produces for statements it couldn't parse.
+func isNil(stmt ast.Statement) bool {
+	return stmt == nil || reflect.ValueOf(stmt).IsNil()
+}
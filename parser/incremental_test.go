@@ -0,0 +1,72 @@
+package parser
+
+import (
+	"testing"
+
+	"github.com/rock619/monkey/lexer"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReparse(t *testing.T) {
+	before := `let a = 1;
+let b = fn(x) {
+  x + a
+};
+let c = b(2); let d = 3;
+c + d;`
+
+	after := `let a = 1;
+let b = fn(x) {
+  x + a
+};
+let c = b(2); let d = 3;
+let e = 4;
+c + d + e;`
+
+	p := New(lexer.New(before))
+	prev := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	program, errors := Reparse(prev, after, Edit{StartLine: 6})
+	assert.Len(t, errors, 0)
+
+	p = New(lexer.New(after))
+	expected := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	assert.Equal(t, expected.String(), program.String())
+	assert.Same(t, prev.Statements[0], program.Statements[0])
+	assert.Same(t, prev.Statements[1], program.Statements[1])
+	assert.Same(t, prev.Statements[2], program.Statements[2])
+	assert.NotSame(t, prev.Statements[3], program.Statements[3])
+	assert.Equal(t, 7, program.Statements[5].Pos().Line)
+}
+
+func TestReparseFromStart(t *testing.T) {
+	p := New(lexer.New("let a = 1;\nlet b = 2;"))
+	prev := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	program, errors := Reparse(prev, "let z = 0;\nlet a = 1;\nlet b = 2;", Edit{StartLine: 1})
+	assert.Len(t, errors, 0)
+	assert.Equal(t, "let z = 0;let a = 1;let b = 2;", program.String())
+}
+
+func TestReparseWithOptions(t *testing.T) {
+	book, _ := LookupDialect(BookDialect)
+	before := "let a = 1;\nlet b = 2;\nlet c = a;"
+	// while is an ordinary identifier in the book dialect.
+	after := "let a = 1;\nlet b = 2;\nlet c = while(a);"
+
+	p := NewWithOptions(lexer.New(before), book)
+	prev := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	program, errors := ReparseWithOptions(prev, after, Edit{StartLine: 3}, book)
+	assert.Len(t, errors, 0)
+	assert.Equal(t, "let a = 1;let b = 2;let c = while(a);", program.String())
+	assert.Same(t, prev.Statements[0], program.Statements[0])
+
+	_, errors = Reparse(prev, after, Edit{StartLine: 3})
+	assert.NotEmpty(t, errors)
+}
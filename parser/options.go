@@ -0,0 +1,109 @@
+package parser
+
+import (
+	"sort"
+	"sync"
+)
+
+// Options selects the syntax a Parser accepts, so that experimental
+// extensions can be switched on and off without forking the parser.
+type Options struct {
+	// Strict rejects input that the book's parser tolerates, such as let
+	// and return statements without a terminating semicolon.
+	Strict bool
+	// UnaryPlus accepts + as a prefix operator, as in +5.
+	UnaryPlus bool
+	// Assignment accepts name = value to change an existing variable.
+	Assignment bool
+	// MemberAccess accepts value.name to read a member of a hash or of a
+	// host value.
+	MemberAccess bool
+	// Defer accepts defer f(x); to call f when the enclosing function
+	// returns.
+	Defer bool
+	// While accepts while (cond) { ... } to run a block for as long as
+	// cond holds.
+	While bool
+	// For accepts for (let i = 0; i < n; i = i + 1) { ... } and
+	// for (x in xs) { ... } loops. Every iteration has its own loop
+	// variable, so closures made in the body keep its value.
+	For bool
+	// HashShorthand accepts {name, age} for {"name": name, "age": age}.
+	HashShorthand bool
+	// BareKeys makes an identifier before the colon in a hash literal a
+	// string key, so that {name: x} is {"name": x}. A key taken from a
+	// variable is then written in parentheses, as in {(name): x}.
+	BareKeys bool
+	// MaxDepth limits how deeply expressions and blocks may nest, so that
+	// input such as thousands of open parentheses fails with an error
+	// instead of exhausting the stack. Zero means DefaultMaxDepth.
+	MaxDepth int
+	// MaxBytes, MaxTokens and MaxStatements, if positive, limit the size
+	// of the input, so that services running untrusted scripts can reject
+	// huge ones early. The parser stops at the first limit exceeded.
+	MaxBytes      int
+	MaxTokens     int
+	MaxStatements int
+	// SchemeIdentifiers accepts identifiers such as empty? and set-car!,
+	// for languages built on top of Monkey. See lexer.SchemeIdentifiers.
+	SchemeIdentifiers bool
+}
+
+// DefaultMaxDepth is the limit on nested expressions used when
+// Options.MaxDepth is zero.
+const DefaultMaxDepth = 1000
+
+func (p *Parser) strictSemicolonError(statement string) {
+	if p.opts.Strict {
+		p.addError(p.peekToken.Pos, "missing ';' after %s statement", statement)
+	}
+}
+
+const (
+	// BookDialect is Monkey exactly as described in the book.
+	BookDialect = "book"
+	// ExtendedDialect is Monkey with the extensions of this implementation
+	// enabled, except BareKeys and SchemeIdentifiers, which change the
+	// meaning of programs such as {key: 1} and a-b.
+	ExtendedDialect = "extended"
+
+	// DefaultDialect is the dialect used by New.
+	DefaultDialect = ExtendedDialect
+)
+
+var (
+	dialectsMu sync.RWMutex
+	dialects   = map[string]Options{
+		BookDialect:     {},
+		ExtendedDialect: {UnaryPlus: true, Assignment: true, MemberAccess: true, Defer: true, While: true, For: true, HashShorthand: true},
+	}
+)
+
+// RegisterDialect makes opts available under name, replacing any dialect
+// registered under the same name before.
+func RegisterDialect(name string, opts Options) {
+	dialectsMu.Lock()
+	defer dialectsMu.Unlock()
+	dialects[name] = opts
+}
+
+// LookupDialect returns the options registered under name.
+func LookupDialect(name string) (Options, bool) {
+	dialectsMu.RLock()
+	defer dialectsMu.RUnlock()
+	opts, ok := dialects[name]
+	return opts, ok
+}
+
+// Dialects returns the sorted names of all registered dialects.
+func Dialects() []string {
+	dialectsMu.RLock()
+	defer dialectsMu.RUnlock()
+
+	names := make([]string, 0, len(dialects))
+	for name := range dialects {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,245 @@
+package parser
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/rock619/monkey/ast"
+
+	"github.com/rock619/monkey/lexer"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStrict(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"let x = 5; return x;", []string{}},
+		{"let x = 5\nx", []string{"missing ';' after let statement"}},
+		{"fn() { return 1 }", []string{"missing ';' after return statement"}},
+	}
+
+	for _, tt := range tests {
+		p := NewWithOptions(lexer.New(tt.input), Options{Strict: true})
+		p.ParseProgram()
+		assert.Equal(t, tt.expected, p.Errors(), "input=%q", tt.input)
+
+		p = New(lexer.New(tt.input))
+		p.ParseProgram()
+		checkParserErrors(t, p)
+	}
+}
+
+func TestUnaryPlus(t *testing.T) {
+	p := NewWithOptions(lexer.New("+5"), Options{UnaryPlus: true})
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+	assert.Equal(t, "(+5)", program.String())
+
+	book, _ := LookupDialect(BookDialect)
+	p = NewWithOptions(lexer.New("+5"), book)
+	p.ParseProgram()
+	assert.Equal(t, []string{"unexpected '+', expected an expression"}, p.Errors())
+}
+
+func TestAssignment(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+		errors   []string
+	}{
+		{"x = 5", "(x = 5)", nil},
+		{"x = y = 1 + 2", "(x = (y = (1 + 2)))", nil},
+		{"x = y == 1", "(x = (y == 1))", nil},
+		{"f(x = 1)", "f((x = 1))", nil},
+		{"let a = b = 2;", "let a = (b = 2);", nil},
+		{"1 + x = 5", "", []string{"cannot assign to (1 + x)"}},
+		{"a[0] = 5", "", []string{"cannot assign to (a[0])"}},
+	}
+
+	for _, tt := range tests {
+		p := NewWithOptions(lexer.New(tt.input), Options{Assignment: true})
+		program := p.ParseProgram()
+		if tt.errors != nil {
+			assert.Equal(t, tt.errors, p.Errors(), "input=%q", tt.input)
+			continue
+		}
+		checkParserErrors(t, p)
+		assert.Equal(t, tt.expected, program.String(), "input=%q", tt.input)
+	}
+
+	book, _ := LookupDialect(BookDialect)
+	p := NewWithOptions(lexer.New("x = 5"), book)
+	program := p.ParseProgram()
+	assert.Equal(t, []string{"unexpected '=', expected an expression"}, p.Errors())
+	assert.Equal(t, "x5", program.String())
+}
+
+// TestBookDialectIdentifiers checks that the keywords of extensions are
+// plain identifiers in the book dialect, as they are in the book.
+func TestBookDialectIdentifiers(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let defer = fn(x) { x }; defer(1);", "let defer = fn(x) x;defer(1)"},
+		{"let while = 1; while + 1", "let while = 1;(while + 1)"},
+		{"let for = fn(x) { x }; for(2);", "let for = fn(x) x;for(2)"},
+	}
+
+	book, _ := LookupDialect(BookDialect)
+	for _, tt := range tests {
+		p := NewWithOptions(lexer.New(tt.input), book)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+		assert.Equal(t, tt.expected, program.String(), "input=%q", tt.input)
+	}
+}
+
+func TestDialects(t *testing.T) {
+	RegisterDialect("strict", Options{Strict: true})
+	t.Cleanup(func() {
+		dialectsMu.Lock()
+		defer dialectsMu.Unlock()
+		delete(dialects, "strict")
+	})
+
+	opts, ok := LookupDialect("strict")
+	assert.True(t, ok)
+	assert.True(t, opts.Strict)
+
+	_, ok = LookupDialect("no such dialect")
+	assert.False(t, ok)
+
+	assert.Equal(t, []string{BookDialect, ExtendedDialect, "strict"}, Dialects())
+}
+
+func TestSchemeIdentifiers(t *testing.T) {
+	input := "let empty? = fn(xs) { len(xs) == 0 }; empty?(list-of-things)"
+
+	p := NewWithOptions(lexer.New(input), Options{SchemeIdentifiers: true})
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+	assert.Equal(t, "let empty? = fn(xs) (len(xs) == 0);empty?(list-of-things)", program.String())
+
+	p = New(lexer.New(input))
+	p.ParseProgram()
+	assert.NotEmpty(t, p.Errors())
+}
+
+func TestHashShorthand(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+		err      string
+	}{
+		{"{name, age}", `{"name": name, "age": age};`, ""},
+		{`{name, "a": 1, age}`, `{"name": name, "a": 1, "age": age};`, ""},
+		{"let h = {x};", `let h = {"x": x};`, ""},
+		{"{x: 1}", "{x: 1};", ""},
+		{`{x, "x": 1}`, "", `duplicate key "x" in hash literal`},
+		{"{x y}", "", "expected next token to be :, got IDENT instead"},
+		// Blocks of if and fn aren't hash literals.
+		{"if (c) { x }", "if (c) {\n\tx;\n};", ""},
+		{"fn() { x }", "fn() {\n\tx;\n};", ""},
+		{"fn() { {x} }", "fn() {\n\t{\"x\": x};\n};", ""},
+	}
+
+	for _, tt := range tests {
+		p := NewWithOptions(lexer.New(tt.input), Options{HashShorthand: true})
+		program := p.ParseProgram()
+		if tt.err != "" {
+			assert.Contains(t, p.Errors(), tt.err, "input=%q", tt.input)
+			continue
+		}
+		checkParserErrors(t, p)
+		assert.Equal(t, tt.expected, ast.Source(program), "input=%q", tt.input)
+	}
+
+	book, _ := LookupDialect(BookDialect)
+	p := NewWithOptions(lexer.New("{x}"), book)
+	p.ParseProgram()
+	assert.Contains(t, p.Errors(), "expected next token to be :, got } instead")
+}
+
+func TestBareKeys(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"{name: 1, age: 2}", `{"name": 1, "age": 2};`},
+		{"{(name): 1}", "{name: 1};"},
+		{"{name: name, x, f(x): 1}", `{"name": name, "x": x, f(x): 1};`},
+		{"{x + 1: 2}", "{(x + 1): 2};"},
+	}
+
+	for _, tt := range tests {
+		p := NewWithOptions(lexer.New(tt.input), Options{HashShorthand: true, BareKeys: true})
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+		assert.Equal(t, tt.expected, ast.Source(program), "input=%q", tt.input)
+	}
+
+	p := NewWithOptions(lexer.New("{a: 1, a: 2}"), Options{BareKeys: true})
+	p.ParseProgram()
+	assert.Equal(t, []string{`duplicate key "a" in hash literal`}, p.Errors())
+}
+
+func TestMaxDepth(t *testing.T) {
+	const n = 100000
+	inputs := []string{
+		strings.Repeat("(", n) + "1" + strings.Repeat(")", n),
+		strings.Repeat("[", n),
+		strings.Repeat("-", n) + "1",
+		strings.Repeat("f(", n),
+		strings.Repeat(`{"a": `, n) + "1",
+		strings.Repeat("fn() { ", n),
+		strings.Repeat("if (x) { ", n),
+		strings.Repeat("a = ", n) + "1",
+		"let x = 1;\n" + strings.Repeat("(", n) + "\nlet y = 2;",
+		strings.Repeat("{ let a = 1; ", n),
+		strings.Repeat("{ ", n) + strings.Repeat("} ", n),
+		strings.Repeat("while (x) { ", n),
+	}
+
+	for _, input := range inputs {
+		p := New(lexer.New(input))
+		p.ParseProgram()
+		if assert.Len(t, p.Errors(), 1, "input=%.20q", input) {
+			assert.Equal(t, "expression too deeply nested (the limit is 1000)", p.Errors()[0])
+		}
+	}
+
+	input := strings.Repeat("(", 10) + "1" + strings.Repeat(")", 10)
+	p := NewWithOptions(lexer.New(input), Options{MaxDepth: 10})
+	p.ParseProgram()
+	assert.Equal(t, []string{"expression too deeply nested (the limit is 10)"}, p.Errors())
+	assert.Equal(t, "1:11", p.ErrorList()[0].Pos.String())
+
+	p = NewWithOptions(lexer.New(input), Options{MaxDepth: 11})
+	p.ParseProgram()
+	checkParserErrors(t, p)
+}
+
+func TestInputLimits(t *testing.T) {
+	tests := []struct {
+		input    string
+		opts     Options
+		expected []string
+	}{
+		{"let x = 1;", Options{MaxBytes: 10, MaxTokens: 5, MaxStatements: 1}, []string{}},
+		{"let x = 1;", Options{MaxBytes: 9}, []string{"1:1: input too large: 10 bytes (the limit is 9)"}},
+		{"let x = 1;", Options{MaxTokens: 4}, []string{"1:10: too many tokens (the limit is 4)"}},
+		{"f(1, 2, 3, 4)", Options{MaxTokens: 5}, []string{"1:7: too many tokens (the limit is 5)"}},
+		{"1; 2;\n3;", Options{MaxStatements: 2}, []string{"2:1: too many statements (the limit is 2)"}},
+		{"fn() { 1; 2; 3 }", Options{MaxStatements: 3}, []string{"1:14: too many statements (the limit is 3)"}},
+	}
+
+	for _, tt := range tests {
+		p := NewWithOptions(lexer.New(tt.input), tt.opts)
+		program := p.ParseProgram()
+		assert.Equal(t, tt.expected, errorStrings(p.ErrorList()), "input=%q", tt.input)
+		assert.NotNil(t, program)
+	}
+}
@@ -2,70 +2,109 @@ package parser
 
 import (
 	"fmt"
+	"io"
+	"math"
+	"sort"
 	"strconv"
+	"time"
 
 	"github.com/rock619/monkey/ast"
 	"github.com/rock619/monkey/lexer"
 	"github.com/rock619/monkey/token"
 )
 
+// Precedences are defined by the token package; these names are kept for
+// existing callers.
 const (
-	_ int = iota
-	LOWEST
-	EQUALS      // ==
-	LESSGREATER // > or <
-	SUM         // +
-	PRODUCT     // *
-	PREFIX      // -X or +X
-	CALL        // myFunction(X)
-	INDEX       // array[index]
+	LOWEST      = token.LowestPrec
+	ASSIGN      = token.AssignPrec
+	EQUALS      = token.EqualsPrec
+	LESSGREATER = token.LessGreaterPrec
+	SUM         = token.SumPrec
+	PRODUCT     = token.ProductPrec
+	PREFIX      = token.PrefixPrec
+	CALL        = token.CallPrec
+	INDEX       = token.IndexPrec
 )
 
-var precedences = map[token.TokenType]int{
-	token.EQ:       EQUALS,
-	token.NOT_EQ:   EQUALS,
-	token.LT:       LESSGREATER,
-	token.GT:       LESSGREATER,
-	token.PLUS:     SUM,
-	token.MINUS:    SUM,
-	token.SLASH:    PRODUCT,
-	token.ASTERISK: PRODUCT,
-	token.LPAREN:   CALL,
-	token.LBRACKET: INDEX,
-}
-
 type (
 	prefixParseFn func() ast.Expression
 	infixParseFn  func(ast.Expression) ast.Expression
 )
 
 type Parser struct {
-	l *lexer.Lexer
+	l    *lexer.Lexer
+	opts Options
 
-	errors []string
+	errors    []*Error
+	lexErrors int // number of lexer errors already in errors
 
+	prevToken token.Token
 	curToken  token.Token
 	peekToken token.Token
 
 	prefixParseFns map[token.TokenType]prefixParseFn
 	infixParseFns  map[token.TokenType]infixParseFn
+
+	// precedences overrides token.TokenType.Precedence for operators
+	// registered with RegisterInfix.
+	precedences map[token.TokenType]int
+
+	traceOut   io.Writer
+	traceLevel int
+
+	// depth is the number of expressions being parsed, and tokens and
+	// statements count what was read so far, for the limits of Options.
+	depth      int
+	tokens     int
+	statements int
+	// halted is set once a limit was exceeded. See halt.
+	halted bool
 }
 
+// New returns a Parser for the default dialect.
 func New(l *lexer.Lexer) *Parser {
+	opts, _ := LookupDialect(DefaultDialect)
+	return NewWithOptions(l, opts)
+}
+
+// NewWithOptions returns a Parser for the language described by opts.
+func NewWithOptions(l *lexer.Lexer, opts Options) *Parser {
+	if opts.SchemeIdentifiers {
+		l.SchemeIdentifiers()
+	}
+	if !opts.Defer {
+		l.Unreserve("defer")
+	}
+	if !opts.While {
+		l.Unreserve("while")
+	}
+	if !opts.For {
+		l.Unreserve("for")
+	}
+
 	p := &Parser{
 		l:      l,
-		errors: []string{},
+		opts:   opts,
+		errors: []*Error{},
 	}
 
 	p.prefixParseFns = make(map[token.TokenType]prefixParseFn)
 	p.registerPrefix(token.IDENT, p.parseIdentifier)
 	p.registerPrefix(token.INT, p.parseIntegerLiteral)
+	p.registerPrefix(token.FLOAT, p.parseFloatLiteral)
+	p.registerPrefix(token.DURATION, p.parseDurationLiteral)
 	p.registerPrefix(token.BANG, p.parsePrefixExpression)
 	p.registerPrefix(token.MINUS, p.parsePrefixExpression)
+	if opts.UnaryPlus {
+		p.registerPrefix(token.PLUS, p.parsePrefixExpression)
+	}
 	p.registerPrefix(token.TRUE, p.parseBoolean)
 	p.registerPrefix(token.FALSE, p.parseBoolean)
 	p.registerPrefix(token.LPAREN, p.parseGroupedExpression)
 	p.registerPrefix(token.IF, p.parseIfExpression)
+	p.registerPrefix(token.WHILE, p.parseWhileExpression)
+	p.registerPrefix(token.FOR, p.parseForExpression)
 	p.registerPrefix(token.FUNCTION, p.parseFunctionLiteral)
 	p.registerPrefix(token.STRING, p.parseStringLiteral)
 	p.registerPrefix(token.LBRACKET, p.parseArrayLiteral)
@@ -76,12 +115,27 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerInfix(token.MINUS, p.parseInfixExpression)
 	p.registerInfix(token.SLASH, p.parseInfixExpression)
 	p.registerInfix(token.ASTERISK, p.parseInfixExpression)
+	p.registerInfix(token.PERCENT, p.parseInfixExpression)
 	p.registerInfix(token.EQ, p.parseInfixExpression)
 	p.registerInfix(token.NOT_EQ, p.parseInfixExpression)
 	p.registerInfix(token.LT, p.parseInfixExpression)
 	p.registerInfix(token.GT, p.parseInfixExpression)
 	p.registerInfix(token.LPAREN, p.parseCallExpression)
 	p.registerInfix(token.LBRACKET, p.parseIndexExpression)
+	if opts.MemberAccess {
+		p.registerInfix(token.DOT, p.parseMemberExpression)
+	}
+	if opts.Assignment {
+		p.registerInfix(token.ASSIGN, p.parseAssignExpression)
+		p.precedences = map[token.TokenType]int{token.ASSIGN: ASSIGN}
+	}
+
+	if p.opts.MaxBytes > 0 && l.Size() > p.opts.MaxBytes {
+		p.addError(token.Position{Line: 1, Column: 1},
+			"input too large: %d bytes (the limit is %d)", l.Size(), p.opts.MaxBytes)
+		p.halt()
+		return p
+	}
 
 	p.nextToken()
 	p.nextToken()
@@ -89,18 +143,81 @@ func New(l *lexer.Lexer) *Parser {
 	return p
 }
 
+// halt stops parsing after an input limit was exceeded: the parser sees
+// the end of the input next and reports no more errors, since the
+// statements and expressions being parsed are all missing their ends.
+func (p *Parser) halt() {
+	p.halted = true
+	p.curToken = token.Token{Type: token.EOF, Pos: p.curToken.Pos}
+	p.peekToken = p.curToken
+}
+
+// Error is a syntax error together with the position of the token that
+// caused it. Errors reported by the lexer are included as well.
+type Error = lexer.Error
+
+// Errors returns the messages of all errors found so far, in the order of
+// ErrorList.
 func (p *Parser) Errors() []string {
-	return p.errors
+	errors := p.ErrorList()
+	msgs := make([]string, len(errors))
+	for i, err := range errors {
+		msgs[i] = err.Msg
+	}
+	return msgs
+}
+
+// ErrorList returns all errors found so far along with their positions,
+// ordered by position and without duplicates, so that the output doesn't
+// depend on the order in which the parser happened to find them.
+func (p *Parser) ErrorList() []*Error {
+	errors := make([]*Error, 0, len(p.errors))
+	seen := make(map[Error]bool)
+	for _, err := range p.errors {
+		if !seen[*err] {
+			seen[*err] = true
+			errors = append(errors, err)
+		}
+	}
+	sort.SliceStable(errors, func(i, j int) bool {
+		return errors[i].Pos.Before(errors[j].Pos)
+	})
+	return errors
+}
+
+func (p *Parser) addError(pos token.Position, format string, a ...any) {
+	if p.halted {
+		return
+	}
+	p.errors = append(p.errors, &Error{Pos: pos, Msg: fmt.Sprintf(format, a...)})
 }
 
 func (p *Parser) peekError(t token.TokenType) {
-	msg := fmt.Sprintf("expected next token to be %s, got %s instead", t, p.peekToken.Type)
-	p.errors = append(p.errors, msg)
+	if p.peekTokenIs(token.ILLEGAL) {
+		// already reported by the lexer
+		return
+	}
+	p.addError(p.peekToken.Pos, "expected next token to be %s, got %s instead", t, p.peekToken.Type)
 }
 
 func (p *Parser) nextToken() {
+	p.prevToken = p.curToken
+	if p.halted {
+		return
+	}
 	p.curToken = p.peekToken
 	p.peekToken = p.l.NextToken()
+
+	p.tokens++
+	if p.opts.MaxTokens > 0 && p.tokens > p.opts.MaxTokens && !p.peekTokenIs(token.EOF) {
+		p.addError(p.peekToken.Pos, "too many tokens (the limit is %d)", p.opts.MaxTokens)
+		p.halt()
+	}
+
+	if lexErrors := p.l.Errors(); len(lexErrors) > p.lexErrors {
+		p.errors = append(p.errors, lexErrors[p.lexErrors:]...)
+		p.lexErrors = len(lexErrors)
+	}
 }
 
 func (p *Parser) ParseProgram() *ast.Program {
@@ -108,8 +225,9 @@ func (p *Parser) ParseProgram() *ast.Program {
 	program.Statements = []ast.Statement{}
 
 	for p.curToken.Type != token.EOF {
-		stmt := p.parseStatement()
-		program.Statements = append(program.Statements, stmt)
+		if stmt := p.parseStatement(); stmt != nil {
+			program.Statements = append(program.Statements, stmt)
+		}
 		p.nextToken()
 	}
 
@@ -117,20 +235,56 @@ func (p *Parser) ParseProgram() *ast.Program {
 }
 
 func (p *Parser) parseStatement() ast.Statement {
+	p.statements++
+	if p.opts.MaxStatements > 0 && p.statements > p.opts.MaxStatements {
+		p.addError(p.curToken.Pos, "too many statements (the limit is %d)", p.opts.MaxStatements)
+		p.halt()
+		return nil
+	}
+
 	switch p.curToken.Type {
 	case token.LET:
 		return p.parseLetStatement()
 	case token.RETURN:
 		return p.parseReturnStatement()
+	case token.DEFER:
+		return p.parseDeferStatement()
+	case token.LBRACE:
+		if p.blockStart() {
+			block := p.parseBlockStatement()
+			if p.peekTokenIs(token.SEMICOLON) {
+				p.nextToken()
+			}
+			return block
+		}
+		return p.parseExpressionStatement()
 	default:
 		return p.parseExpressionStatement()
 	}
 }
 
+// blockStart reports whether the { at the start of a statement opens a
+// block rather than a hash literal. Unlike JavaScript, which always reads
+// a block there, it favors hash literals: only a token that can't start a
+// key, such as let, makes it a block. So {"a": 1}, {} and {x} remain
+// hashes, while { let x = 1; f(x) } is a block.
+func (p *Parser) blockStart() bool {
+	switch p.peekToken.Type {
+	case token.LET, token.RETURN, token.DEFER:
+		return true
+	}
+	return false
+}
+
 func (p *Parser) parseLetStatement() *ast.LetStatement {
 	stmt := &ast.LetStatement{Token: p.curToken}
 
-	if !p.expectPeek(token.IDENT) {
+	if p.peekToken.Type.IsKeyword() {
+		// Report the misuse once and keep going as if it were a plain
+		// identifier, so the rest of the statement doesn't cascade.
+		p.nextToken()
+		p.reservedWordError(p.curToken)
+	} else if !p.expectPeek(token.IDENT) {
 		return nil
 	}
 
@@ -146,6 +300,8 @@ func (p *Parser) parseLetStatement() *ast.LetStatement {
 
 	if p.peekTokenIs(token.SEMICOLON) {
 		p.nextToken()
+	} else {
+		p.strictSemicolonError("let")
 	}
 
 	return stmt
@@ -175,6 +331,9 @@ func (p *Parser) parseReturnStatement() *ast.ReturnStatement {
 
 	stmt.ReturnValue = p.parseExpression(LOWEST)
 
+	if !p.peekTokenIs(token.SEMICOLON) {
+		p.strictSemicolonError("return")
+	}
 	for p.peekTokenIs(token.SEMICOLON) {
 		p.nextToken()
 	}
@@ -182,7 +341,35 @@ func (p *Parser) parseReturnStatement() *ast.ReturnStatement {
 	return stmt
 }
 
+func (p *Parser) parseDeferStatement() *ast.DeferStatement {
+	stmt := &ast.DeferStatement{Token: p.curToken}
+
+	p.nextToken()
+
+	exp := p.parseExpression(LOWEST)
+
+	if !p.peekTokenIs(token.SEMICOLON) {
+		p.strictSemicolonError("defer")
+	}
+	for p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	call, ok := exp.(*ast.CallExpression)
+	if !ok {
+		if exp != nil {
+			p.addError(exp.Pos(), "expression in defer must be a function call")
+		}
+		return nil
+	}
+	stmt.Call = call
+
+	return stmt
+}
+
 func (p *Parser) parseExpressionStatement() *ast.ExpressionStatement {
+	defer p.trace("parseExpressionStatement")()
+
 	stmt := &ast.ExpressionStatement{Token: p.curToken}
 
 	stmt.Expression = p.parseExpression(LOWEST)
@@ -195,9 +382,16 @@ func (p *Parser) parseExpressionStatement() *ast.ExpressionStatement {
 }
 
 func (p *Parser) parseExpression(precedence int) ast.Expression {
+	defer p.trace("parseExpression")()
+
+	defer func() { p.depth-- }()
+	if !p.nest() {
+		return nil
+	}
+
 	prefix := p.prefixParseFns[p.curToken.Type]
 	if prefix == nil {
-		p.noPrefixParseFnError(p.curToken.Type)
+		p.noPrefixParseFnError(p.curToken)
 		return nil
 	}
 	leftExp := prefix()
@@ -216,6 +410,26 @@ func (p *Parser) parseExpression(precedence int) ast.Expression {
 	return leftExp
 }
 
+// nest counts one more level of nesting and reports whether it is within
+// the limit. If it isn't, the parse stops with an error. Either way the
+// caller must decrement p.depth when it is done with the level.
+func (p *Parser) nest() bool {
+	p.depth++
+	if max := p.maxDepth(); p.depth > max {
+		p.addError(p.curToken.Pos, "expression too deeply nested (the limit is %d)", max)
+		p.halt()
+		return false
+	}
+	return true
+}
+
+func (p *Parser) maxDepth() int {
+	if p.opts.MaxDepth > 0 {
+		return p.opts.MaxDepth
+	}
+	return DefaultMaxDepth
+}
+
 func (p *Parser) registerPrefix(tokenType token.TokenType, fn prefixParseFn) {
 	p.prefixParseFns[tokenType] = fn
 }
@@ -233,7 +447,33 @@ func (p *Parser) parseIntegerLiteral() ast.Expression {
 
 	value, err := strconv.ParseInt(p.curToken.Literal, 10, 64)
 	if err != nil {
-		p.errors = append(p.errors, fmt.Sprintf("could not parse %q as integer", p.curToken.Literal))
+		p.addError(p.curToken.Pos, "could not parse %q as integer", p.curToken.Literal)
+		return nil
+	}
+
+	lit.Value = value
+	return lit
+}
+
+func (p *Parser) parseFloatLiteral() ast.Expression {
+	lit := &ast.FloatLiteral{Token: p.curToken}
+
+	value, err := strconv.ParseFloat(p.curToken.Literal, 64)
+	if err != nil {
+		p.addError(p.curToken.Pos, "could not parse %q as float", p.curToken.Literal)
+		return nil
+	}
+
+	lit.Value = value
+	return lit
+}
+
+func (p *Parser) parseDurationLiteral() ast.Expression {
+	lit := &ast.DurationLiteral{Token: p.curToken}
+
+	value, err := time.ParseDuration(p.curToken.Literal)
+	if err != nil {
+		p.addError(p.curToken.Pos, "could not parse %q as duration", p.curToken.Literal)
 		return nil
 	}
 
@@ -241,11 +481,52 @@ func (p *Parser) parseIntegerLiteral() ast.Expression {
 	return lit
 }
 
-func (p *Parser) noPrefixParseFnError(t token.TokenType) {
-	p.errors = append(p.errors, fmt.Sprintf("no prefix parse function for %s found", t))
+func (p *Parser) noPrefixParseFnError(t token.Token) {
+	if t.Type == token.ILLEGAL {
+		// already reported by the lexer
+		return
+	}
+
+	msg := fmt.Sprintf("unexpected %s, expected an expression", describeToken(t))
+	if hint := p.noPrefixParseFnHint(t); hint != "" {
+		msg += " (" + hint + ")"
+	}
+	p.addError(t.Pos, "%s", msg)
+}
+
+// noPrefixParseFnHint guesses the most likely cause of a missing expression
+// from the tokens around it.
+func (p *Parser) noPrefixParseFnHint(t token.Token) string {
+	prev := p.prevToken
+	switch {
+	case prev.Type == token.ASSIGN:
+		return "missing value after '='"
+	case prev.Type == token.COMMA:
+		return fmt.Sprintf("trailing ',' before %s", describeToken(t))
+	case prev.Type == token.BANG ||
+		prev.Type.Precedence() > LOWEST && prev.Type != token.LPAREN && prev.Type != token.LBRACKET:
+		return fmt.Sprintf("missing operand after '%s'", prev.Literal)
+	case t.Type == token.SEMICOLON:
+		return "stray ';'"
+	default:
+		return ""
+	}
+}
+
+func describeToken(t token.Token) string {
+	if t.Type == token.EOF {
+		return "end of input"
+	}
+	return "'" + t.Literal + "'"
+}
+
+func (p *Parser) reservedWordError(tok token.Token) {
+	p.addError(tok.Pos, "%q is a reserved word and cannot be used as an identifier", tok.Literal)
 }
 
 func (p *Parser) parsePrefixExpression() ast.Expression {
+	defer p.trace("parsePrefixExpression")()
+
 	expression := &ast.PrefixExpression{
 		Token:    p.curToken,
 		Operator: p.curToken.Literal,
@@ -258,20 +539,16 @@ func (p *Parser) parsePrefixExpression() ast.Expression {
 }
 
 func (p *Parser) peekPrecedence() int {
-	if p, ok := precedences[p.peekToken.Type]; ok {
-		return p
-	}
-	return LOWEST
+	return p.Precedence(p.peekToken.Type)
 }
 
 func (p *Parser) curPrecedence() int {
-	if p, ok := precedences[p.curToken.Type]; ok {
-		return p
-	}
-	return LOWEST
+	return p.Precedence(p.curToken.Type)
 }
 
 func (p *Parser) parseInfixExpression(left ast.Expression) ast.Expression {
+	defer p.trace("parseInfixExpression")()
+
 	expression := &ast.InfixExpression{
 		Token:    p.curToken,
 		Operator: p.curToken.Literal,
@@ -284,6 +561,25 @@ func (p *Parser) parseInfixExpression(left ast.Expression) ast.Expression {
 	return expression
 }
 
+// parseAssignExpression parses the right-hand side of "name = value".
+// Assignment is right-associative, so a = b = 1 assigns 1 to both.
+func (p *Parser) parseAssignExpression(left ast.Expression) ast.Expression {
+	expression := &ast.AssignExpression{Token: p.curToken}
+
+	p.nextToken()
+	expression.Value = p.parseExpression(ASSIGN - 1)
+
+	name, ok := left.(*ast.Identifier)
+	if !ok {
+		if left != nil {
+			p.addError(left.Pos(), "cannot assign to %s", left)
+		}
+		return nil
+	}
+	expression.Name = name
+	return expression
+}
+
 func (p *Parser) parseBoolean() ast.Expression {
 	return &ast.Boolean{Token: p.curToken, Value: p.curTokenIs(token.TRUE)}
 }
@@ -302,6 +598,11 @@ func (p *Parser) parseGroupedExpression() ast.Expression {
 func (p *Parser) parseIfExpression() ast.Expression {
 	expression := &ast.IfExpression{Token: p.curToken}
 
+	if p.peekTokenIs(token.SEMICOLON) || p.peekTokenIs(token.EOF) {
+		p.reservedWordError(p.curToken)
+		return nil
+	}
+
 	if !p.expectPeek(token.LPAREN) {
 		return nil
 	}
@@ -332,15 +633,108 @@ func (p *Parser) parseIfExpression() ast.Expression {
 	return expression
 }
 
+func (p *Parser) parseWhileExpression() ast.Expression {
+	expression := &ast.WhileExpression{Token: p.curToken}
+
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+
+	p.nextToken()
+	expression.Condition = p.parseExpression(LOWEST)
+
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	expression.Body = p.parseBlockStatement()
+	return expression
+}
+
+// parseForExpression parses both kinds of for loop. "in" isn't a keyword,
+// so a loop is a for-in loop if its first two tokens are identifiers and
+// the second one is "in".
+func (p *Parser) parseForExpression() ast.Expression {
+	tok := p.curToken
+
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+	p.nextToken()
+
+	if p.curTokenIs(token.IDENT) && p.peekTokenIs(token.IDENT) && p.peekToken.Literal == "in" {
+		expression := &ast.ForInExpression{Token: tok}
+		expression.Variable = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+		p.nextToken()
+		p.nextToken()
+		expression.Iterable = p.parseExpression(LOWEST)
+		if !p.expectPeek(token.RPAREN) || !p.expectPeek(token.LBRACE) {
+			return nil
+		}
+		expression.Body = p.parseBlockStatement()
+		return expression
+	}
+
+	expression := &ast.ForExpression{Token: tok}
+	switch p.curToken.Type {
+	case token.SEMICOLON:
+	case token.LET:
+		init := p.parseLetStatement()
+		if init == nil {
+			return nil
+		}
+		if !p.curTokenIs(token.SEMICOLON) {
+			p.peekError(token.SEMICOLON)
+			return nil
+		}
+		expression.Init = init
+	default:
+		init := &ast.ExpressionStatement{Token: p.curToken, Expression: p.parseExpression(LOWEST)}
+		if !p.expectPeek(token.SEMICOLON) {
+			return nil
+		}
+		expression.Init = init
+	}
+
+	if !p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+		expression.Condition = p.parseExpression(LOWEST)
+	}
+	if !p.expectPeek(token.SEMICOLON) {
+		return nil
+	}
+
+	if !p.peekTokenIs(token.RPAREN) {
+		p.nextToken()
+		expression.Update = p.parseExpression(LOWEST)
+	}
+	if !p.expectPeek(token.RPAREN) || !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	expression.Body = p.parseBlockStatement()
+	return expression
+}
+
 func (p *Parser) parseBlockStatement() *ast.BlockStatement {
 	block := &ast.BlockStatement{Token: p.curToken}
 	block.Statements = []ast.Statement{}
 
+	defer func() { p.depth-- }()
+	if !p.nest() {
+		return block
+	}
+
 	p.nextToken()
 
 	for !p.curTokenIs(token.RBRACE) && !p.curTokenIs(token.EOF) {
-		stmt := p.parseStatement()
-		block.Statements = append(block.Statements, stmt)
+		if stmt := p.parseStatement(); stmt != nil {
+			block.Statements = append(block.Statements, stmt)
+		}
 		p.nextToken()
 	}
 
@@ -350,6 +744,12 @@ func (p *Parser) parseBlockStatement() *ast.BlockStatement {
 func (p *Parser) parseFunctionLiteral() ast.Expression {
 	lit := &ast.FunctionLiteral{Token: p.curToken}
 
+	if p.peekTokenIs(token.SEMICOLON) || p.peekTokenIs(token.EOF) ||
+		p.peekToken.Type.Precedence() > LOWEST && !p.peekTokenIs(token.LPAREN) {
+		p.reservedWordError(p.curToken)
+		return nil
+	}
+
 	if !p.expectPeek(token.LPAREN) {
 		return nil
 	}
@@ -375,14 +775,12 @@ func (p *Parser) parseFunctionParameters() []*ast.Identifier {
 
 	p.nextToken()
 
-	ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
-	identifiers = append(identifiers, ident)
+	identifiers = append(identifiers, p.parseParameter())
 
 	for p.peekTokenIs(token.COMMA) {
 		p.nextToken()
 		p.nextToken()
-		ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
-		identifiers = append(identifiers, ident)
+		identifiers = append(identifiers, p.parseParameter())
 	}
 
 	if !p.expectPeek(token.RPAREN) {
@@ -391,6 +789,13 @@ func (p *Parser) parseFunctionParameters() []*ast.Identifier {
 	return identifiers
 }
 
+func (p *Parser) parseParameter() *ast.Identifier {
+	if p.curToken.Type.IsKeyword() {
+		p.reservedWordError(p.curToken)
+	}
+	return &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+}
+
 func (p *Parser) parseCallExpression(function ast.Expression) ast.Expression {
 	exp := &ast.CallExpression{Token: p.curToken, Function: function}
 	exp.Arguments = p.parseExpressionList(token.RPAREN)
@@ -423,6 +828,11 @@ func (p *Parser) parseExpressionList(end token.TokenType) []ast.Expression {
 	for p.peekTokenIs(token.COMMA) {
 		p.nextToken()
 		p.nextToken()
+		if p.curTokenIs(end) {
+			// trailing comma
+			p.noPrefixParseFnError(p.curToken)
+			return nil
+		}
 		list = append(list, p.parseExpression(LOWEST))
 	}
 
@@ -446,20 +856,51 @@ func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
 	return exp
 }
 
+func (p *Parser) parseMemberExpression(left ast.Expression) ast.Expression {
+	exp := &ast.MemberExpression{Token: p.curToken, Left: left}
+
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+	exp.Property = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+	return exp
+}
+
 func (p *Parser) parseHashLiteral() ast.Expression {
 	hash := &ast.HashLiteral{Token: p.curToken}
 	hash.Pairs = make(map[ast.Expression]ast.Expression)
+	seen := make(map[string]bool)
 
 	for !p.peekTokenIs(token.RBRACE) {
 		p.nextToken()
-		key := p.parseExpression(LOWEST)
 
-		if !p.expectPeek(token.COLON) {
-			return nil
+		var key, value ast.Expression
+		switch {
+		case p.curTokenIs(token.IDENT) && p.opts.HashShorthand &&
+			(p.peekTokenIs(token.COMMA) || p.peekTokenIs(token.RBRACE)):
+			key = p.nameKey()
+			value = p.parseIdentifier()
+		case p.curTokenIs(token.IDENT) && p.opts.BareKeys && p.peekTokenIs(token.COLON):
+			key = p.nameKey()
+		default:
+			key = p.parseExpression(LOWEST)
 		}
 
-		p.nextToken()
-		value := p.parseExpression(LOWEST)
+		if k, ok := constantKey(key); ok {
+			if seen[k] {
+				p.addError(key.Pos(), "duplicate key %s in hash literal", ast.Source(key))
+			}
+			seen[k] = true
+		}
+
+		if value == nil {
+			if !p.expectPeek(token.COLON) {
+				return nil
+			}
+			p.nextToken()
+			value = p.parseExpression(LOWEST)
+		}
 
 		hash.Pairs[key] = value
 
@@ -474,3 +915,74 @@ func (p *Parser) parseHashLiteral() ast.Expression {
 
 	return hash
 }
+
+// nameKey returns the identifier at the current token as a string key.
+func (p *Parser) nameKey() ast.Expression {
+	tok := p.curToken
+	tok.Type = token.STRING
+	return &ast.StringLiteral{Token: tok, Value: tok.Literal}
+}
+
+// constantKey returns a string identifying the value of a literal hash key,
+// or false if the key is only known at run time. A whole float is the same
+// key as the equal integer, as it is in a hash.
+func constantKey(key ast.Expression) (string, bool) {
+	switch key := key.(type) {
+	case *ast.StringLiteral:
+		return "string:" + key.Value, true
+	case *ast.IntegerLiteral:
+		return fmt.Sprintf("int:%d", key.Value), true
+	case *ast.FloatLiteral:
+		if key.Value == math.Trunc(key.Value) && key.Value < math.MaxInt64 {
+			return fmt.Sprintf("int:%d", int64(key.Value)), true
+		}
+		return fmt.Sprintf("float:%v", key.Value), true
+	case *ast.Boolean:
+		return fmt.Sprintf("bool:%t", key.Value), true
+	default:
+		return "", false
+	}
+}
+
+// ErrorList is a list of parse errors. It implements error.
+type ErrorList []*Error
+
+func (l ErrorList) Error() string {
+	switch len(l) {
+	case 0:
+		return "no errors"
+	case 1:
+		return l[0].Error()
+	default:
+		return fmt.Sprintf("%s (and %d more errors)", l[0], len(l)-1)
+	}
+}
+
+// ParseExpressionString parses src as a single expression, optionally
+// followed by a semicolon. The returned error is an ErrorList.
+func ParseExpressionString(src string) (ast.Expression, error) {
+	return New(lexer.New(src)).ParseSingleExpression()
+}
+
+// ParseSingleExpression parses the whole input of p as a single
+// expression, optionally followed by a semicolon. The returned error is an
+// ErrorList.
+func (p *Parser) ParseSingleExpression() (ast.Expression, error) {
+	if p.curTokenIs(token.EOF) {
+		p.addError(p.curToken.Pos, "expected an expression, got end of input")
+		return nil, ErrorList(p.ErrorList())
+	}
+
+	exp := p.parseExpression(LOWEST)
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+	if !p.peekTokenIs(token.EOF) {
+		p.addError(p.peekToken.Pos, "unexpected %s after expression", describeToken(p.peekToken))
+	}
+
+	if len(p.errors) != 0 {
+		return nil, ErrorList(p.ErrorList())
+	}
+	return exp, nil
+}
@@ -3,10 +3,12 @@ package parser
 import (
 	"fmt"
 	"strconv"
+	"strings"
 	"testing"
 
 	"github.com/rock619/monkey/ast"
 	"github.com/rock619/monkey/lexer"
+	"github.com/rock619/monkey/token"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -99,6 +101,24 @@ func TestIntegerLiteralExpression(t *testing.T) {
 	assert.Equal(t, "5", literal.TokenLiteral())
 }
 
+func TestFloatLiteralExpression(t *testing.T) {
+	input := "2.5e-1;"
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+	assert.Len(t, program.Statements, 1)
+
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	assert.True(t, ok)
+
+	literal, ok := stmt.Expression.(*ast.FloatLiteral)
+	assert.True(t, ok)
+	assert.Equal(t, 0.25, literal.Value)
+	assert.Equal(t, "2.5e-1", literal.TokenLiteral())
+}
+
 func testLetStatement(t *testing.T, s ast.Statement, name string) {
 	t.Helper()
 
@@ -125,6 +145,7 @@ func TestParsingPrefixExpressions(t *testing.T) {
 	}{
 		{"!5;", "!", 5},
 		{"-15;", "-", 15},
+		{"+15;", "+", 15},
 		{"!foobar;", "!", "foobar"},
 		{"-foobar;", "-", "foobar"},
 		{"!true;", "!", true},
@@ -170,6 +191,7 @@ func TestParsingInfixExpressions(t *testing.T) {
 		{"5 - 5;", 5, "-", 5},
 		{"5 * 5;", 5, "*", 5},
 		{"5 / 5;", 5, "/", 5},
+		{"5 % 5;", 5, "%", 5},
 		{"5 > 5;", 5, ">", 5},
 		{"5 < 5;", 5, "<", 5},
 		{"5 == 5;", 5, "==", 5},
@@ -226,6 +248,10 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 			"a * b * c",
 			"((a * b) * c)",
 		},
+		{
+			"a + b % c * d",
+			"(a + ((b % c) * d))",
+		},
 		{
 			"a * b / c",
 			"((a * b) / c)",
@@ -314,6 +340,10 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 			"add(a * b[2], b[1], 2 * [1, 2][1])",
 			"add((a * (b[2])), (b[1]), (2 * ([1, 2][1])))",
 		},
+		{
+			"-a.b.c(d)[e] * f.g",
+			"((-(((a.b).c)(d)[e])) * (f.g))",
+		},
 	}
 
 	for _, tt := range tests {
@@ -609,6 +639,149 @@ func TestParsingIndexExpressions(t *testing.T) {
 	testInfixExpression(t, indexExp.Index, 1, "+", 1)
 }
 
+func TestDeferStatements(t *testing.T) {
+	program := New(lexer.New("defer close(f, 1);")).ParseProgram()
+
+	stmt, ok := program.Statements[0].(*ast.DeferStatement)
+	if assert.True(t, ok, "stmt not *ast.DeferStatement. got=%T", program.Statements[0]) {
+		testIdentifier(t, stmt.Call.Function, "close")
+		assert.Len(t, stmt.Call.Arguments, 2)
+		assert.Equal(t, "defer close(f, 1);", stmt.String())
+	}
+
+	tests := []struct {
+		input    string
+		opts     Options
+		expected []string
+	}{
+		{"defer x;", Options{Defer: true}, []string{"1:7: expression in defer must be a function call"}},
+		{"defer f()", Options{Defer: true, Strict: true}, []string{"1:10: missing ';' after defer statement"}},
+		{"let defer = 1;", Options{Defer: true}, []string{`1:5: "defer" is a reserved word and cannot be used as an identifier`}},
+	}
+	for _, tt := range tests {
+		p := NewWithOptions(lexer.New(tt.input), tt.opts)
+		p.ParseProgram()
+
+		var got []string
+		for _, err := range p.ErrorList() {
+			got = append(got, err.Error())
+		}
+		assert.Equal(t, tt.expected, got, "input=%q", tt.input)
+	}
+}
+
+func TestWhileExpression(t *testing.T) {
+	program := New(lexer.New("while (x < 10) { x = x + 1 }")).ParseProgram()
+
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	if assert.True(t, ok, "stmt not *ast.ExpressionStatement. got=%T", program.Statements[0]) {
+		exp, ok := stmt.Expression.(*ast.WhileExpression)
+		if assert.True(t, ok, "exp not *ast.WhileExpression. got=%T", stmt.Expression) {
+			testInfixExpression(t, exp.Condition, "x", "<", 10)
+			assert.Len(t, exp.Body.Statements, 1)
+			assert.Equal(t, "while (x < 10) (x = (x + 1))", exp.String())
+		}
+	}
+
+	tests := []struct {
+		input    string
+		opts     Options
+		expected []string
+	}{
+		{"while (x) 1", Options{While: true}, []string{"1:11: expected next token to be {, got INT instead"}},
+		{"let while = 1;", Options{While: true}, []string{`1:5: "while" is a reserved word and cannot be used as an identifier`}},
+	}
+	for _, tt := range tests {
+		p := NewWithOptions(lexer.New(tt.input), tt.opts)
+		p.ParseProgram()
+		assert.Equal(t, tt.expected, errorStrings(p.ErrorList()), "input=%q", tt.input)
+	}
+}
+
+func TestForExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"for (let i = 0; i < 3; i = i + 1) { f(i) }", "for (let i = 0; (i < 3); (i = (i + 1))) f(i)"},
+		{"for (i = 0; i < 3;) {}", "for ((i = 0); (i < 3); ) "},
+		{"for (;;) { x }", "for (; ; ) x"},
+		{"for (x in [1, 2]) { puts(x) }", "for (x in [1, 2]) puts(x)"},
+		{"let in = 1; for (in in in) {}", "let in = 1;for (in in in) "},
+	}
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+		assert.Equal(t, tt.expected, program.String(), "input=%q", tt.input)
+	}
+
+	errors := []struct {
+		input    string
+		opts     Options
+		expected string
+	}{
+		{"for (let i = 0 i < 3;) {}", Options{For: true}, "1:16: expected next token to be ;, got IDENT instead"},
+		{"for (x in xs) 1", Options{For: true}, "1:15: expected next token to be {, got INT instead"},
+	}
+	for _, tt := range errors {
+		p := NewWithOptions(lexer.New(tt.input), tt.opts)
+		p.ParseProgram()
+		if errs := errorStrings(p.ErrorList()); assert.NotEmpty(t, errs, "input=%q", tt.input) {
+			assert.Equal(t, tt.expected, errs[0], "input=%q", tt.input)
+		}
+	}
+}
+
+func TestPostfixChainPrecedence(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`f(1)[0].name(2)["k"]`, "(((f(1)[0]).name)(2)[k])"},
+		{"a.b(c)(d)[e].f", "(((a.b)(c)(d)[e]).f)"},
+		{"a[0][1].c.d(e)[f]", "(((((a[0])[1]).c).d)(e)[f])"},
+		{"f()()()", "f()()()"},
+		{"[1][0].x", "(([1][0]).x)"},
+		{`{"a": 1}.a`, "({a:1}.a)"},
+		{`"s".len`, "(s.len)"},
+		{"(a + b).c", "((a + b).c)"},
+		{"-a.b[c](d)", "(-((a.b)[c])(d))"},
+		{"!a.b(c).d", "(!((a.b)(c).d))"},
+		{"a.b + c.d * e.f(g)[h]", "((a.b) + ((c.d) * ((e.f)(g)[h])))"},
+		{"x = a.b(c)[d]", "(x = ((a.b)(c)[d]))"},
+		{"f(a.b, c[d].e)[g.h]", "(f((a.b), ((c[d]).e))[(g.h)])"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		assert.Equal(t, tt.expected, program.String(), "input=%q", tt.input)
+	}
+}
+
+func TestParsingMemberExpressions(t *testing.T) {
+	program := New(lexer.New("user.name")).ParseProgram()
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	member, ok := stmt.Expression.(*ast.MemberExpression)
+	if assert.True(t, ok, "exp not *ast.MemberExpression. got=%T", stmt.Expression) {
+		testIdentifier(t, member.Left, "user")
+		testIdentifier(t, member.Property, "name")
+	}
+
+	p := New(lexer.New("user.1"))
+	p.ParseProgram()
+	assert.Equal(t, []string{"expected next token to be IDENT, got INT instead"}, p.Errors())
+
+	book, _ := LookupDialect(BookDialect)
+	p = NewWithOptions(lexer.New("user.name"), book)
+	p.ParseProgram()
+	assert.NotEmpty(t, p.Errors())
+}
+
 func TestParsingHashLiteralsStringKeys(t *testing.T) {
 	input := `{"one": 1, "two": 2, "three": 3}`
 
@@ -710,3 +883,228 @@ func TestParsingHashLiteralsWithExpressions(t *testing.T) {
 		testFunc(value)
 	}
 }
+
+func TestParsingHashLiteralsDuplicateKeys(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{`{"a": 1, "a": 2}`, []string{`1:10: duplicate key "a" in hash literal`}},
+		{`{1: 1, 2: 2, 1: 3, true: 4, true: 5}`, []string{
+			"1:14: duplicate key 1 in hash literal",
+			"1:29: duplicate key true in hash literal",
+		}},
+		{`{"1": 1, 1: 2, true: 3, "true": 4}`, nil},
+		{`{1: "a", 1.0: "b"}`, []string{"1:10: duplicate key 1.0 in hash literal"}},
+		{`{1.5: "a", 1.5: "b"}`, []string{"1:12: duplicate key 1.5 in hash literal"}},
+		{`{1.5: 1, 15e-1: 2, 2: 3, 2e0: 4}`, []string{
+			"1:10: duplicate key 15e-1 in hash literal",
+			"1:26: duplicate key 2e0 in hash literal",
+		}},
+		{`{1.5: 1, 1: 2, 2.5: 3}`, nil},
+		// Keys computed at run time can't be checked.
+		{`{a: 1, a: 2, "a" + "": 3}`, nil},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		var got []string
+		for _, err := range p.ErrorList() {
+			got = append(got, err.Error())
+		}
+		assert.Equal(t, tt.expected, got, "input=%q", tt.input)
+	}
+}
+
+func TestReservedWordAsIdentifier(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let let = 5;", `"let" is a reserved word and cannot be used as an identifier`},
+		{"let fn = 5;", `"fn" is a reserved word and cannot be used as an identifier`},
+		{"let x = if;", `"if" is a reserved word and cannot be used as an identifier`},
+		{"let x = fn;", `"fn" is a reserved word and cannot be used as an identifier`},
+		{"fn(x, return) { x };", `"return" is a reserved word and cannot be used as an identifier`},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		assert.Equal(t, []string{tt.expected}, p.Errors(), "input=%q", tt.input)
+	}
+}
+
+func TestNoPrefixParseFnErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"}", "unexpected '}', expected an expression"},
+		{"5 + ;", "unexpected ';', expected an expression (missing operand after '+')"},
+		{"!;", "unexpected ';', expected an expression (missing operand after '!')"},
+		{"let x = ;", "unexpected ';', expected an expression (missing value after '=')"},
+		{"add(1, )", "unexpected ')', expected an expression (trailing ',' before ')')"},
+		{"5;;", "unexpected ';', expected an expression (stray ';')"},
+		{"5 *", "unexpected end of input, expected an expression (missing operand after '*')"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		assert.Equal(t, []string{tt.expected}, p.Errors(), "input=%q", tt.input)
+	}
+}
+
+func TestErrorPositions(t *testing.T) {
+	input := "let x = 5;\nlet y = (1 + 2;"
+
+	l := lexer.New(input)
+	p := New(l)
+	p.ParseProgram()
+
+	errors := p.ErrorList()
+	assert.Len(t, errors, 1)
+	assert.Equal(t, token.Position{Line: 2, Column: 15}, errors[0].Pos)
+	assert.Equal(t, "2:15: expected next token to be ), got ; instead", errors[0].Error())
+}
+
+func TestLexerErrorsAreReported(t *testing.T) {
+	input := "let x = 5 @ 3;\nlet z = 1 + 99999999999999999999;\nlet y = \"abc"
+
+	l := lexer.New(input)
+	p := New(l)
+	p.ParseProgram()
+
+	assert.Equal(t, []string{
+		"invalid character '@'",
+		"integer literal too large: 99999999999999999999 (the maximum is 9223372036854775807)",
+		"unterminated string literal",
+	}, p.Errors())
+}
+
+func TestParseExpressionString(t *testing.T) {
+	tests := []struct {
+		input         string
+		expected      string
+		expectedError string
+	}{
+		{"1 + 2 * 3", "(1 + (2 * 3))", ""},
+		{"add(x, y);", "add(x, y)", ""},
+		{"", "", "1:1: expected an expression, got end of input"},
+		{"1 + 2; 3", "", "1:8: unexpected '3' after expression"},
+		{"let x = 1", "", "1:1: unexpected 'let', expected an expression (and 1 more errors)"},
+	}
+
+	for _, tt := range tests {
+		exp, err := ParseExpressionString(tt.input)
+		if tt.expectedError != "" {
+			assert.EqualError(t, err, tt.expectedError, "input=%q", tt.input)
+			assert.Nil(t, exp)
+			continue
+		}
+
+		assert.NoError(t, err, "input=%q", tt.input)
+		assert.Equal(t, tt.expected, exp.String())
+	}
+
+	// Errors come out in the order of ErrorList.
+	p := New(lexer.New("a b"))
+	p.Errorf(token.Position{Line: 1, Column: 3}, "second")
+	p.Errorf(token.Position{Line: 1, Column: 1}, "first")
+	p.Errorf(token.Position{Line: 1, Column: 3}, "second")
+	_, err := p.ParseSingleExpression()
+	if assert.IsType(t, ErrorList{}, err) {
+		assert.Equal(t, []string{"1:1: first", "1:3: second", "1:3: unexpected 'b' after expression"},
+			errorStrings(err.(ErrorList)))
+	}
+}
+
+func TestTrace(t *testing.T) {
+	var buf strings.Builder
+	p := New(lexer.New("-a * b"))
+	p.SetTrace(&buf)
+	p.ParseProgram()
+	assert.Empty(t, p.Errors())
+
+	expected := `BEGIN parseExpressionStatement
+	BEGIN parseExpression
+		BEGIN parsePrefixExpression
+			BEGIN parseExpression
+			END parseExpression
+		END parsePrefixExpression
+		BEGIN parseInfixExpression
+			BEGIN parseExpression
+			END parseExpression
+		END parseInfixExpression
+	END parseExpression
+END parseExpressionStatement
+`
+	assert.Equal(t, expected, buf.String())
+}
+
+func TestErrorOrder(t *testing.T) {
+	p := New(lexer.New("let = @"))
+	p.ParseProgram()
+	assert.Equal(t, []string{
+		"1:5: expected next token to be IDENT, got = instead",
+		"1:5: unexpected '=', expected an expression",
+		"1:7: invalid character '@'",
+	}, errorStrings(p.ErrorList()))
+
+	p = New(lexer.New("a b"))
+	p.Errorf(token.Position{Line: 1, Column: 3}, "second")
+	p.Errorf(token.Position{Line: 1, Column: 1}, "first")
+	p.Errorf(token.Position{Line: 1, Column: 3}, "second")
+	p.Errorf(token.Position{Line: 1, Column: 3}, "third")
+	assert.Equal(t, []string{"first", "second", "third"}, p.Errors())
+}
+
+func errorStrings(errors []*Error) []string {
+	strs := make([]string, len(errors))
+	for i, err := range errors {
+		strs[i] = err.Error()
+	}
+	return strs
+}
+
+func TestBlockOrHashAtStatementStart(t *testing.T) {
+	tests := []struct {
+		input    string
+		isBlock  []bool
+		expected string
+	}{
+		{`{"a": 1}`, []bool{false}, `{"a": 1};`},
+		{`{"a": 1}; {2: 3}`, []bool{false, false}, "{\"a\": 1};\n{2: 3};"},
+		{"{}", []bool{false}, "{};"},
+		{"{x}", []bool{false}, `{"x": x};`},
+		{"{f(x): 1}", []bool{false}, "{f(x): 1};"},
+		{"{ let x = 1; x }", []bool{true}, "{\n\tlet x = 1;\n\tx;\n}"},
+		{`{ let x = 1; x }; {"a": x}`, []bool{true, false}, "{\n\tlet x = 1;\n\tx;\n}\n{\"a\": x};"},
+		{"{ return 1; }", []bool{true}, "{\n\treturn 1;\n}"},
+		{"{ defer f(); }", []bool{true}, "{\n\tdefer f();\n}"},
+		{`fn() { {"a": 1} }`, []bool{false}, "fn() {\n\t{\"a\": 1};\n};"},
+		{"fn() { { let y = 2; } }", []bool{false}, "fn() {\n\t{\n\t\tlet y = 2;\n\t}\n};"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		var isBlock []bool
+		for _, stmt := range program.Statements {
+			_, ok := stmt.(*ast.BlockStatement)
+			isBlock = append(isBlock, ok)
+		}
+		assert.Equal(t, tt.isBlock, isBlock, "input=%q", tt.input)
+		assert.Equal(t, tt.expected, ast.Source(program), "input=%q", tt.input)
+	}
+}
@@ -2,33 +2,35 @@ package parser
 
 import (
 	"fmt"
-	"runtime"
+	"io"
 	"strings"
 )
 
-var traceLevel int = 0
-
 const traceIdentPlaceholder string = "\t"
 
-func identLevel() string {
-	return strings.Repeat(traceIdentPlaceholder, traceLevel-1)
+// SetTrace makes p write the parse functions it enters and leaves to w,
+// indented by nesting depth. A nil w turns tracing off. The state belongs
+// to p, so parsers in different goroutines can trace independently.
+func (p *Parser) SetTrace(w io.Writer) {
+	p.traceOut = w
 }
 
-func tracePrint(fs string) {
-	fmt.Printf("%s%s\n", identLevel(), fs)
+func (p *Parser) tracePrint(fs string) {
+	fmt.Fprintf(p.traceOut, "%s%s\n", strings.Repeat(traceIdentPlaceholder, p.traceLevel-1), fs)
 }
 
-func incIdent() { traceLevel = traceLevel + 1 }
-func decIdent() { traceLevel = traceLevel - 1 }
+// trace logs entering the parse function name and returns the function
+// logging its end, to be deferred.
+func (p *Parser) trace(name string) (untrace func()) {
+	if p.traceOut == nil {
+		return func() {}
+	}
 
-func trace() (untrace func()) {
-	incIdent()
-	pc, _, _, _ := runtime.Caller(1)
-	tracePrint("BEGIN " + runtime.FuncForPC(pc).Name())
+	p.traceLevel++
+	p.tracePrint("BEGIN " + name)
 
 	return func() {
-		pc, _, _, _ := runtime.Caller(1)
-		tracePrint("END " + runtime.FuncForPC(pc).Name())
-		decIdent()
+		p.tracePrint("END " + name)
+		p.traceLevel--
 	}
 }
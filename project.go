@@ -0,0 +1,89 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/rock619/monkey/evaluator"
+)
+
+// mainModule is the module run for a project directory.
+const mainModule = "main"
+
+const mainTemplate = `let greet = fn(name) { "Hello, " + name + "!" };
+
+puts(greet("Monkey"));
+`
+
+// initProject creates the layout of a project in the directory given in
+// args, or the current directory: a main module, a lib directory for the
+// modules it imports and a tests directory.
+func initProject(args []string) int {
+	if len(args) > 1 {
+		fmt.Fprintln(os.Stderr, "usage: monkey init [dir]")
+		return 2
+	}
+	dir := "."
+	if len(args) == 1 {
+		dir = args[0]
+	}
+
+	if err := createProject(dir); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
+
+func createProject(dir string) error {
+	for _, sub := range []string{"lib", "tests"} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0o755); err != nil {
+			return err
+		}
+	}
+
+	main := filepath.Join(dir, mainModule+".mky")
+	f, err := os.OpenFile(main, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if errors.Is(err, fs.ErrExist) {
+		return fmt.Errorf("%s already exists", main)
+	}
+	if err != nil {
+		return err
+	}
+	if _, err := f.WriteString(mainTemplate); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// projectPath returns the module search path of the project in dir: the
+// directory itself followed by its lib and vendor directories.
+func projectPath(dir string) []string {
+	return []string{dir, filepath.Join(dir, "lib"), filepath.Join(dir, vendorDir)}
+}
+
+// resolveFiles replaces the project directories among files by their main
+// modules and returns the module search path the projects need.
+func resolveFiles(files []string) ([]string, []string, error) {
+	var resolved, modulePath []string
+	for _, file := range files {
+		info, err := os.Stat(file)
+		if err != nil || !info.IsDir() {
+			resolved = append(resolved, file)
+			continue
+		}
+
+		path := projectPath(file)
+		main, err := evaluator.FindModule(path, mainModule)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: %w", file, err)
+		}
+		resolved = append(resolved, main)
+		modulePath = append(modulePath, path...)
+	}
+	return resolved, modulePath, nil
+}
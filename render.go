@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+
+	"github.com/rock619/monkey/evaluator"
+	"github.com/rock619/monkey/object"
+	"github.com/rock619/monkey/parser"
+	"github.com/rock619/monkey/template"
+)
+
+// render executes a template file and writes the result to stdout. Values
+// given with -set are available to the template as strings. It returns the
+// exit status.
+func render(args []string, syntax parser.Options, opts evaluator.Options) int {
+	flags := flag.NewFlagSet("template", flag.ContinueOnError)
+	vars := map[string]string{}
+	flags.Func("set", "define `name=value` as a string variable (repeatable)", func(s string) error {
+		name, value, ok := strings.Cut(s, "=")
+		if !ok || name == "" {
+			return fmt.Errorf("want name=value, got %q", s)
+		}
+		vars[name] = value
+		return nil
+	})
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "usage: monkey template [-set name=value]... file")
+		return 2
+	}
+
+	file := flags.Arg(0)
+	src, err := os.ReadFile(file)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	tmpl, err := template.Parse(file, string(src), syntax)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	eval := evaluator.New(opts)
+	env := eval.NewEnvironment()
+	for name, value := range vars {
+		env.Set(name, &object.String{Value: value})
+	}
+
+	if err := tmpl.Execute(ctx, os.Stdout, eval, env); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
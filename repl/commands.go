@@ -0,0 +1,158 @@
+package repl
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/rock619/monkey/ast"
+	"github.com/rock619/monkey/evaluator"
+	"github.com/rock619/monkey/lexer"
+	"github.com/rock619/monkey/object"
+	"github.com/rock619/monkey/parser"
+)
+
+// command runs a REPL meta command such as ":save session.mky".
+func (s *session) command(line string) {
+	fields := strings.Fields(line)
+	name, args := fields[0], fields[1:]
+
+	switch name {
+	case ":save":
+		if len(args) != 1 {
+			fmt.Fprintln(s.out, "usage: :save <file>")
+			return
+		}
+		s.save(args[0])
+	case ":replay":
+		if len(args) != 1 {
+			fmt.Fprintln(s.out, "usage: :replay <file>")
+			return
+		}
+		s.replay(args[0])
+	case ":snapshot":
+		if len(args) != 1 {
+			fmt.Fprintln(s.out, "usage: :snapshot <file>")
+			return
+		}
+		s.snapshot(args[0])
+	case ":restore":
+		if len(args) != 1 {
+			fmt.Fprintln(s.out, "usage: :restore <file>")
+			return
+		}
+		s.restore(args[0])
+	case ":type":
+		s.printType(strings.TrimSpace(strings.TrimPrefix(line, name)))
+	case ":ast":
+		s.printAST(strings.TrimSpace(strings.TrimPrefix(line, name)))
+	default:
+		fmt.Fprintf(s.out, "unknown command: %s\n", name)
+	}
+}
+
+// save writes every successfully evaluated input of the session to path,
+// one per line, so that it can be run again as a script.
+func (s *session) save(path string) {
+	var content strings.Builder
+	for _, line := range s.history {
+		content.WriteString(line + "\n")
+	}
+
+	if err := os.WriteFile(path, []byte(content.String()), 0o644); err != nil {
+		fmt.Fprintf(s.out, "could not save session: %s\n", err)
+		return
+	}
+	fmt.Fprintf(s.out, "saved %d inputs to %s\n", len(s.history), path)
+}
+
+// replay runs each line of path as if it had been typed at the prompt.
+func (s *session) replay(path string) {
+	f, err := os.Open(path)
+	if err != nil {
+		fmt.Fprintf(s.out, "could not replay session: %s\n", err)
+		return
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		fmt.Fprintln(s.out, PROMPT+line)
+		s.run(line)
+	}
+	if err := scanner.Err(); err != nil {
+		fmt.Fprintf(s.out, "could not replay session: %s\n", err)
+	}
+}
+
+// snapshot saves the values bound in the session to path, so that a later
+// session can restore them.
+func (s *session) snapshot(path string) {
+	var b bytes.Buffer
+	skipped, err := evaluator.Snapshot(&b, s.env)
+	if err == nil {
+		err = os.WriteFile(path, b.Bytes(), 0o644)
+	}
+	if err != nil {
+		fmt.Fprintf(s.out, "could not save snapshot: %s\n", err)
+		return
+	}
+	if len(skipped) != 0 {
+		fmt.Fprintf(s.out, "skipped values that can't be saved: %s\n", strings.Join(skipped, ", "))
+	}
+	fmt.Fprintf(s.out, "saved snapshot to %s\n", path)
+}
+
+// restore binds the values saved by snapshot in the session.
+func (s *session) restore(path string) {
+	f, err := os.Open(path)
+	if err == nil {
+		err = evaluator.Restore(f, s.env)
+		f.Close()
+	}
+	if err != nil {
+		fmt.Fprintf(s.out, "could not restore snapshot: %s\n", err)
+		return
+	}
+	fmt.Fprintf(s.out, "restored snapshot from %s\n", path)
+}
+
+// printType evaluates a single expression and prints the type of its value.
+// It evaluates in a copy of the environment, so that ":type x = 1" leaves x
+// alone; functions it calls may still assign the names they closed over.
+func (s *session) printType(src string) {
+	exp, err := parser.NewWithOptions(lexer.New(src), s.opts.Syntax).ParseSingleExpression()
+	if err != nil {
+		fmt.Fprintln(s.out, err)
+		return
+	}
+
+	evaluated := s.evaluate(exp, s.env.Copy())
+
+	if evaluated == nil {
+		fmt.Fprintln(s.out, object.NULL_OBJ)
+		return
+	}
+	if evaluated.Type() == object.ERROR_OBJ {
+		fmt.Fprintln(s.out, evaluated.Inspect())
+		return
+	}
+	fmt.Fprintln(s.out, evaluated.Type())
+}
+
+// printAST parses src and prints its syntax tree without evaluating it.
+func (s *session) printAST(src string) {
+	p := parser.NewWithOptions(lexer.New(src), s.opts.Syntax)
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		printParserErrors(s.out, src, p.ErrorList(), s.opts.Quiet)
+		return
+	}
+	ast.Fprint(s.out, program)
+}
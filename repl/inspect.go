@@ -0,0 +1,100 @@
+package repl
+
+import (
+	"fmt"
+	"io"
+	"os/exec"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/rock619/monkey/object"
+)
+
+// inspect renders obj like obj.Inspect, but cuts arrays, hashes and strings
+// down to the limits in opts so that huge values don't flood the terminal.
+func inspect(obj object.Object, opts Options) string {
+	if str, ok := obj.(*object.String); ok {
+		s, rest := truncateString(str.Value, opts.MaxStringLen)
+		return s + more(rest)
+	}
+	return inspectElement(obj, opts, object.Visited{})
+}
+
+// inspectElement is inspect for values nested in an array or hash, where
+// strings are quoted as object.InspectElement does.
+func inspectElement(obj object.Object, opts Options, visited object.Visited) string {
+	switch obj := obj.(type) {
+	case *object.String:
+		s, rest := truncateString(obj.Value, opts.MaxStringLen)
+		return strconv.Quote(s) + more(rest)
+	case *object.Array:
+		if visited.Enter(obj) {
+			return "[...]"
+		}
+		defer visited.Leave(obj)
+
+		elements := []string{}
+		for i, el := range obj.Elements {
+			if opts.MaxElements > 0 && i == opts.MaxElements {
+				elements = append(elements, more(len(obj.Elements)-i))
+				break
+			}
+			elements = append(elements, inspectElement(el, opts, visited))
+		}
+		return "[" + strings.Join(elements, ", ") + "]"
+	case *object.Hash:
+		if visited.Enter(obj) {
+			return "{...}"
+		}
+		defer visited.Leave(obj)
+
+		pairs := []string{}
+		for _, pair := range obj.SortedPairs() {
+			if opts.MaxElements > 0 && len(pairs) == opts.MaxElements {
+				pairs = append(pairs, more(len(obj.Pairs)-len(pairs)))
+				break
+			}
+			pairs = append(pairs, inspectElement(pair.Key, opts, visited)+": "+inspectElement(pair.Value, opts, visited))
+		}
+		return "{" + strings.Join(pairs, ", ") + "}"
+	default:
+		return obj.Inspect()
+	}
+}
+
+// truncateString cuts s down to at most max bytes without splitting a rune
+// and returns the result and the number of bytes cut off.
+func truncateString(s string, max int) (string, int) {
+	if max <= 0 || len(s) <= max {
+		return s, 0
+	}
+	for max > 0 && !utf8.RuneStart(s[max]) {
+		max--
+	}
+	return s[:max], len(s) - max
+}
+
+// more returns the marker for n elided elements or bytes, or "" if n is 0.
+func more(n int) string {
+	if n == 0 {
+		return ""
+	}
+	return fmt.Sprintf("... (%d more)", n)
+}
+
+// page shows text through the configured pager when it is longer than
+// opts.PagerThreshold bytes. It reports whether the pager was used.
+func page(out io.Writer, text string, opts Options) bool {
+	args := strings.Fields(opts.Pager)
+	if len(args) == 0 || opts.PagerThreshold <= 0 || len(text) <= opts.PagerThreshold {
+		return false
+	}
+
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdin = strings.NewReader(text)
+	cmd.Stdout = out
+	cmd.Stderr = out
+
+	return cmd.Run() == nil
+}
@@ -0,0 +1,61 @@
+package repl
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sync"
+)
+
+// interrupter turns Ctrl-C into cancellation of the evaluation in flight
+// instead of letting it kill the whole session.
+type interrupter struct {
+	out     io.Writer
+	signals chan os.Signal
+
+	mu     sync.Mutex
+	cancel context.CancelFunc
+}
+
+func newInterrupter(out io.Writer) *interrupter {
+	i := &interrupter{out: out, signals: make(chan os.Signal, 1)}
+	signal.Notify(i.signals, os.Interrupt)
+	go i.loop()
+	return i
+}
+
+func (i *interrupter) loop() {
+	for range i.signals {
+		i.mu.Lock()
+		if i.cancel != nil {
+			i.cancel()
+		} else {
+			fmt.Fprint(i.out, "\n(To exit, press Ctrl-D)\n"+PROMPT)
+		}
+		i.mu.Unlock()
+	}
+}
+
+// context returns a context that is canceled by the next interrupt. The
+// returned release func must be called once the evaluation is over.
+func (i *interrupter) context() (context.Context, func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	i.mu.Lock()
+	i.cancel = cancel
+	i.mu.Unlock()
+
+	return ctx, func() {
+		i.mu.Lock()
+		i.cancel = nil
+		i.mu.Unlock()
+		cancel()
+	}
+}
+
+func (i *interrupter) stop() {
+	signal.Stop(i.signals)
+	close(i.signals)
+}
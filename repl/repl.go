@@ -2,43 +2,160 @@ package repl
 
 import (
 	"bufio"
+	"context"
 	"fmt"
 	"io"
+	"strings"
+	"sync"
 
+	"github.com/rock619/monkey/ast"
 	"github.com/rock619/monkey/evaluator"
 	"github.com/rock619/monkey/lexer"
 	"github.com/rock619/monkey/object"
 	"github.com/rock619/monkey/parser"
+	"github.com/rock619/monkey/token"
 )
 
 const PROMPT = ">> "
 
+// Options configures a REPL session.
+type Options struct {
+	// MaxElements limits how many elements of an array or pairs of a hash
+	// are printed. Zero means no limit.
+	MaxElements int
+	// MaxStringLen limits how many bytes of a string are printed. Zero
+	// means no limit.
+	MaxStringLen int
+	// Pager is a command, such as "less -R", that results longer than
+	// PagerThreshold bytes are piped through. Empty disables paging.
+	Pager          string
+	PagerThreshold int
+
+	// Syntax selects the dialect of the language that is accepted.
+	Syntax parser.Options
+	// Eval configures the semantics of evaluation.
+	Eval evaluator.Options
+
+	// Quiet prints parser errors plainly, without the monkey face, for
+	// non-interactive use.
+	Quiet bool
+}
+
 func Start(in io.Reader, out io.Writer) {
-	scanner := bufio.NewScanner(in)
-	env := object.NewEnvironment()
+	syntax, _ := parser.LookupDialect(parser.DefaultDialect)
+	StartWithOptions(in, out, Options{Syntax: syntax})
+}
+
+func StartWithOptions(in io.Reader, out io.Writer, opts Options) {
+	// Imported modules are written in the dialect of the session.
+	opts.Eval.Syntax = opts.Syntax
+	// Output of puts goes where results go, and readLine reads the lines
+	// after the input that called it.
+	if opts.Eval.IO.Out == nil {
+		opts.Eval.IO.Out = out
+	}
+	lines := bufio.NewReader(in)
+	if opts.Eval.IO.In == nil {
+		opts.Eval.IO.In = lines
+	}
+	eval := evaluator.New(opts.Eval)
+	s := &session{
+		out:        out,
+		opts:       opts,
+		env:        eval.NewEnvironment(),
+		eval:       eval,
+		interrupts: newInterrupter(out),
+	}
+	defer s.interrupts.stop()
+
+	s.loop(lines)
+}
+
+// loop reads and runs inputs from in until it is exhausted. It reads no
+// further than the end of each input, so that an Evaluator given in as its
+// input reads what follows. (bufio.NewReader returns in itself.)
+func (s *session) loop(in *bufio.Reader) {
+	out := s.out
 	for {
-		fmt.Print(PROMPT)
-		scanned := scanner.Scan()
-		if !scanned {
+		fmt.Fprint(out, PROMPT)
+		line, err := in.ReadString('\n')
+		if err != nil && line == "" {
+			fmt.Fprintln(out, "\nGoodbye!")
 			return
 		}
 
-		line := scanner.Text()
-		l := lexer.New(line)
-		p := parser.New(l)
-
-		program := p.ParseProgram()
-		if len(p.Errors()) != 0 {
-			printParserErrors(out, p.Errors())
+		line = strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r")
+		if strings.HasPrefix(line, ":") {
+			s.command(line)
 			continue
 		}
 
-		evaluated := evaluator.Eval(program, env)
-		if evaluated != nil {
-			io.WriteString(out, evaluated.Inspect())
-			io.WriteString(out, "\n")
+		s.run(line)
+	}
+}
+
+// session is the state shared by all inputs of one REPL run.
+type session struct {
+	out        io.Writer
+	opts       Options
+	env        *object.Environment
+	eval       *evaluator.Evaluator
+	interrupts *interrupter
+
+	// ctx and lock are used instead of interrupts by remote sessions: ctx
+	// stops evaluations and lock, if not nil, is held during them.
+	ctx  context.Context
+	lock sync.Locker
+
+	// history holds the inputs that were evaluated without errors.
+	history []string
+}
+
+// run evaluates a single line of input, prints its result and returns it.
+// It returns nil when the line couldn't be parsed.
+func (s *session) run(line string) object.Object {
+	l := lexer.New(line)
+	p := parser.NewWithOptions(l, s.opts.Syntax)
+
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		printParserErrors(s.out, line, p.ErrorList(), s.opts.Quiet)
+		return nil
+	}
+
+	evaluated := s.evaluate(program, s.env)
+
+	if evaluated == nil || evaluated.Type() != object.ERROR_OBJ {
+		s.history = append(s.history, line)
+	}
+
+	if evaluated != nil {
+		result := inspect(evaluated, s.opts) + "\n"
+		if !page(s.out, result, s.opts) {
+			io.WriteString(s.out, result)
+		}
+		if err, ok := evaluated.(*object.Error); ok {
+			printCaret(s.out, line, err.Pos)
 		}
 	}
+
+	return evaluated
+}
+
+// evaluate evaluates node in env, which is the environment of the session
+// or a copy of it.
+func (s *session) evaluate(node ast.Node, env *object.Environment) object.Object {
+	if s.interrupts == nil {
+		if s.lock != nil {
+			s.lock.Lock()
+			defer s.lock.Unlock()
+		}
+		return s.eval.Eval(s.ctx, node, env)
+	}
+
+	ctx, release := s.interrupts.context()
+	defer release()
+	return s.eval.Eval(ctx, node, env)
 }
 
 const MONKEY_FACE = `            __,__
@@ -54,11 +171,42 @@ const MONKEY_FACE = `            __,__
            '-----'
 `
 
-func printParserErrors(out io.Writer, errors []string) {
+// printParserErrors reports errors in line. Unless plain is set they are
+// introduced by the monkey face.
+func printParserErrors(out io.Writer, line string, errors []*parser.Error, plain bool) {
+	if plain {
+		for _, err := range errors {
+			fmt.Fprintln(out, err)
+			printCaret(out, line, err.Pos)
+		}
+		return
+	}
+
 	fmt.Fprint(out, MONKEY_FACE)
 	fmt.Fprintln(out, "Woops! We ran into some monkey business here!")
 	fmt.Fprintln(out, " parser errors:")
-	for _, msg := range errors {
-		fmt.Fprintln(out, "\t"+msg)
+	for _, err := range errors {
+		fmt.Fprintln(out, "\t"+err.Msg)
+		printCaret(out, line, err.Pos)
+	}
+}
+
+// printCaret echoes line and marks the column of pos beneath it. Nothing is
+// printed when pos doesn't point into line.
+func printCaret(out io.Writer, line string, pos token.Position) {
+	if pos.Line != 1 || pos.Column < 1 || pos.Column > len(line)+1 {
+		return
 	}
+
+	var indent strings.Builder
+	for _, ch := range []byte(line[:pos.Column-1]) {
+		if ch == '\t' {
+			indent.WriteByte('\t')
+		} else {
+			indent.WriteByte(' ')
+		}
+	}
+
+	fmt.Fprintln(out, "\t\t"+line)
+	fmt.Fprintln(out, "\t\t"+indent.String()+"^")
 }
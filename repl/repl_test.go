@@ -0,0 +1,221 @@
+package repl
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/rock619/monkey/parser"
+)
+
+// runSession runs a session reading input and returns everything it wrote.
+func runSession(opts Options, input string) string {
+	if opts.Syntax == (parser.Options{}) {
+		opts.Syntax, _ = parser.LookupDialect(parser.DefaultDialect)
+	}
+	var out bytes.Buffer
+	StartWithOptions(strings.NewReader(input), &out, opts)
+	return out.String()
+}
+
+func TestErrorCaret(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{
+			"1 + true\n",
+			"ERROR: type mismatch: INTEGER + BOOLEAN at 1:3\n\t\t1 + true\n\t\t  ^\n",
+		},
+		{
+			"let = 1\n",
+			"1:5: expected next token to be IDENT, got = instead\n\t\tlet = 1\n\t\t    ^\n",
+		},
+		{
+			"\tfoo\n",
+			"ERROR: identifier not found: foo at 1:2\n\t\t\tfoo\n\t\t\t^\n",
+		},
+	}
+
+	for _, tt := range tests {
+		out := runSession(Options{Quiet: true}, tt.input)
+		if !strings.Contains(out, tt.expected) {
+			t.Errorf("output for %q is %q, want it to contain %q", tt.input, out, tt.expected)
+		}
+	}
+}
+
+func TestQuiet(t *testing.T) {
+	out := runSession(Options{}, "let = 1\n")
+	if !strings.Contains(out, MONKEY_FACE) || !strings.Contains(out, "\texpected next token to be IDENT") {
+		t.Errorf("parser errors aren't introduced by the monkey face: %q", out)
+	}
+
+	out = runSession(Options{Quiet: true}, "let = 1\n")
+	if strings.Contains(out, MONKEY_FACE) || strings.Contains(out, "monkey business") {
+		t.Errorf("quiet session printed the monkey face: %q", out)
+	}
+	if !strings.Contains(out, "1:5: expected next token to be IDENT, got = instead\n") {
+		t.Errorf("quiet session didn't print the error with its position: %q", out)
+	}
+}
+
+func TestTruncation(t *testing.T) {
+	tests := []struct {
+		opts     Options
+		input    string
+		expected string
+	}{
+		{Options{MaxElements: 2}, "[1, 2, 3, 4]", "[1, 2, ... (2 more)]"},
+		{Options{MaxElements: 2}, "[[1, 2, 3], 4, 5]", "[[1, 2, ... (1 more)], 4, ... (1 more)]"},
+		{Options{MaxElements: 4}, "[1, 2]", "[1, 2]"},
+		{Options{MaxStringLen: 3}, `"abcdefgh"`, "abc... (5 more)"},
+		{Options{MaxStringLen: 2}, `"héllo"`, "h... (5 more)"},
+		{Options{}, `"abcdefgh"`, "abcdefgh"},
+	}
+
+	for _, tt := range tests {
+		out := runSession(tt.opts, tt.input+"\n")
+		expected := PROMPT + tt.expected + "\n" + PROMPT
+		if !strings.HasPrefix(out, expected) {
+			t.Errorf("output for %q is %q, want it to start with %q", tt.input, out, expected)
+		}
+	}
+}
+
+func TestSaveAndReplay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "session.mky")
+
+	out := runSession(Options{Quiet: true}, `let x = 2;
+let y = x * 3;
+oops
+let = 1
+puts(y)
+:save `+path+"\n")
+	if !strings.Contains(out, "saved 3 inputs to "+path) {
+		t.Errorf(":save didn't report saving 3 inputs: %q", out)
+	}
+
+	saved, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Inputs that failed to parse or evaluate aren't saved.
+	expected := "let x = 2;\nlet y = x * 3;\nputs(y)\n"
+	if string(saved) != expected {
+		t.Errorf("saved session is %q, want %q", saved, expected)
+	}
+
+	out = runSession(Options{Quiet: true}, ":replay "+path+"\ny\n")
+	if !strings.Contains(out, PROMPT+"puts(y)\n6\n") {
+		t.Errorf(":replay didn't echo and run the inputs: %q", out)
+	}
+	if !strings.Contains(out, PROMPT+"6\n") {
+		t.Errorf("bindings made by :replay aren't visible afterwards: %q", out)
+	}
+
+	for _, input := range []string{":save\n", ":replay a b\n"} {
+		if out := runSession(Options{}, input); !strings.Contains(out, "usage: ") {
+			t.Errorf("output for %q has no usage message: %q", input, out)
+		}
+	}
+	out = runSession(Options{}, ":replay "+filepath.Join(t.TempDir(), "missing.mky")+"\n")
+	if !strings.Contains(out, "could not replay session: ") {
+		t.Errorf(":replay of a missing file reported no error: %q", out)
+	}
+}
+
+func TestType(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{":type 1\n", "INTEGER\n"},
+		{`:type "a" + "b"` + "\n", "STRING\n"},
+		{":type fn(x) { x }\n", "FUNCTION\n"},
+		{":type if (false) { 1 }\n", "NULL\n"},
+		{":type foo\n", "ERROR: identifier not found: foo"},
+		{":type 1 +\n", "1:4: unexpected end of input"},
+		{":type\n", "1:1: expected an expression"},
+	}
+
+	for _, tt := range tests {
+		out := runSession(Options{}, tt.input)
+		if !strings.HasPrefix(out, PROMPT+tt.expected) {
+			t.Errorf("output for %q is %q, want it to start with %q", tt.input, out, PROMPT+tt.expected)
+		}
+	}
+}
+
+func TestTypeSyntax(t *testing.T) {
+	// :type parses in the dialect of the session, as evaluation does.
+	out := runSession(Options{Syntax: parser.Options{SchemeIdentifiers: true}},
+		"let empty? = fn(xs) { len(xs) == 0 }\n:type empty?([])\n")
+	expected := PROMPT + PROMPT + "BOOLEAN\n"
+	if !strings.HasPrefix(out, expected) {
+		t.Errorf("output is %q, want it to start with %q", out, expected)
+	}
+}
+
+func TestTypeLeavesEnvironmentAlone(t *testing.T) {
+	out := runSession(Options{}, "let x = 5\n:type x = \"a\"\nx\n")
+	expected := PROMPT + PROMPT + "STRING\n" + PROMPT + "5\n"
+	if !strings.HasPrefix(out, expected) {
+		t.Errorf("output is %q, want it to start with %q", out, expected)
+	}
+}
+
+func TestTutor(t *testing.T) {
+	input := `2 * (3 + 4)
+:hint
+let answer = 41
+let answer = 42;
+:skip
+:quit
+`
+	var out bytes.Buffer
+	Tutor(strings.NewReader(input), &out)
+
+	expected := []string{
+		"Lesson 1/6: Expressions\n",
+		PROMPT + "14\nWell done!\n",
+		"Lesson 2/6: Let\n",
+		PROMPT + "let answer = 42;\n",
+		PROMPT + "Not quite. Try again or type :hint.\n",
+		PROMPT + "Well done!\n",
+		"Lesson 3/6: Functions\n",
+		"Lesson 4/6: Closures\n",
+	}
+	rest := out.String()
+	for _, e := range expected {
+		i := strings.Index(rest, e)
+		if i < 0 {
+			t.Fatalf("tutor output %q doesn't contain %q after the previous lines", out.String(), e)
+		}
+		rest = rest[i+len(e):]
+	}
+	if strings.Contains(rest, "Lesson 5/6") || strings.Contains(rest, "Goodbye!") {
+		t.Errorf(":quit didn't end the tutorial at once: %q", rest)
+	}
+
+	out.Reset()
+	answers := []string{"2 * (3 + 4)"}
+	for _, l := range lessons[1:] {
+		answers = append(answers, l.hint)
+	}
+	Tutor(strings.NewReader(strings.Join(answers, "\n")+"\n"), &out)
+	if n := strings.Count(out.String(), "Well done!"); n != len(lessons) {
+		t.Errorf("tutor passed %d of %d lessons: %q", n, len(lessons), out.String())
+	}
+	if !strings.HasSuffix(out.String(), "That's all the lessons. Happy hacking!\n") {
+		t.Errorf("tutor didn't finish the lessons: %q", out.String())
+	}
+
+	out.Reset()
+	Tutor(strings.NewReader(""), &out)
+	if !strings.HasSuffix(out.String(), PROMPT+"\nGoodbye!\n") {
+		t.Errorf("tutor didn't say goodbye at the end of its input: %q", out.String())
+	}
+}
@@ -0,0 +1,83 @@
+package repl
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"sync"
+
+	"github.com/rock619/monkey/evaluator"
+	"github.com/rock619/monkey/object"
+)
+
+// A Server exposes REPL sessions over a network, typically on a local
+// socket, so that the environment of a long-running embedded interpreter
+// can be inspected and changed while it runs.
+type Server struct {
+	// Env is the environment every session evaluates in. If nil, each
+	// session gets an environment of its own.
+	Env *object.Environment
+	// Options configures the sessions. Their standard streams are the
+	// connection, and results are never paged.
+	Options Options
+	// Lock, if not nil, is held while a session evaluates an input, so
+	// that the embedder can keep sessions from overlapping with its own use
+	// of Env.
+	Lock sync.Locker
+}
+
+// Serve accepts connections on l and runs a session on each until ctx is
+// done, when it closes l and stops the evaluations in progress. It returns
+// nil in that case and the error of l otherwise.
+func (srv *Server) Serve(ctx context.Context, l net.Listener) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		<-ctx.Done()
+		l.Close()
+	}()
+
+	// Sessions must not evaluate at the same time, if only because they
+	// share Env.
+	lock := srv.Lock
+	if lock == nil {
+		lock = new(sync.Mutex)
+	}
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if ctx.Err() != nil || errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer conn.Close()
+			in := bufio.NewReader(conn)
+			srv.session(ctx, conn, in, lock).loop(in)
+		}()
+	}
+}
+
+func (srv *Server) session(ctx context.Context, conn net.Conn, in io.Reader, lock sync.Locker) *session {
+	opts := srv.Options
+	opts.Pager = ""
+	opts.Eval.Syntax = opts.Syntax
+	opts.Eval.IO = evaluator.IOStreams{In: in, Out: conn, Err: conn}
+	opts.Eval.BufferOutput = true
+	eval := evaluator.New(opts.Eval)
+
+	env := srv.Env
+	if env == nil {
+		env = eval.NewEnvironment()
+	}
+	return &session{out: conn, opts: opts, env: env, eval: eval, ctx: ctx, lock: lock}
+}
@@ -0,0 +1,64 @@
+package repl
+
+import (
+	"context"
+	"io"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/rock619/monkey/object"
+	"github.com/rock619/monkey/parser"
+)
+
+func TestServe(t *testing.T) {
+	l, err := net.Listen("unix", filepath.Join(t.TempDir(), "repl.sock"))
+	if err != nil {
+		t.Skipf("no unix sockets: %s", err)
+	}
+
+	syntax, _ := parser.LookupDialect(parser.DefaultDialect)
+	env := object.NewEnvironment()
+	srv := &Server{
+		Env:     env,
+		Options: Options{Syntax: syntax, Quiet: true},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- srv.Serve(ctx, l) }()
+
+	conn, err := net.Dial("unix", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	input := `let name = readLine();
+monkey
+puts("hello " + name); log("to the log");
+`
+	if _, err := io.WriteString(conn, input); err != nil {
+		t.Fatal(err)
+	}
+	conn.(*net.UnixConn).CloseWrite()
+
+	out, err := io.ReadAll(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.Close()
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("Serve: %s", err)
+	}
+
+	// Everything the session reads and writes goes through the connection.
+	for _, expected := range []string{"hello monkey\n", "to the log\n", "Goodbye!"} {
+		if !strings.Contains(string(out), expected) {
+			t.Errorf("output %q doesn't contain %q", out, expected)
+		}
+	}
+	if name, ok := env.Get("name"); !ok || name.Inspect() != "monkey" {
+		t.Errorf("wrong name in the shared environment: %v", name)
+	}
+}
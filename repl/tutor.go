@@ -0,0 +1,119 @@
+package repl
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/rock619/monkey/evaluator"
+	"github.com/rock619/monkey/lexer"
+	"github.com/rock619/monkey/object"
+	"github.com/rock619/monkey/parser"
+)
+
+type lesson struct {
+	title string
+	text  string
+	// check is a Monkey expression that must evaluate to true once the
+	// lesson is solved. The result of the last input is bound to _.
+	check string
+	hint  string
+}
+
+var lessons = []lesson{
+	{
+		title: "Expressions",
+		text:  "Monkey evaluates arithmetic the way you would expect.\nCompute two times the sum of three and four.",
+		check: "_ == 14",
+		hint:  "try 2 * (3 + 4)",
+	},
+	{
+		title: "Let",
+		text:  "let binds a value to a name.\nBind the name answer to 42.",
+		check: "answer == 42",
+		hint:  "let answer = 42;",
+	},
+	{
+		title: "Functions",
+		text:  "Functions are values created with fn and bound with let like any other.\nDefine a function double that multiplies its argument by two.",
+		check: "double(21) == 42",
+		hint:  "let double = fn(x) { x * 2 };",
+	},
+	{
+		title: "Closures",
+		text:  "A function remembers the environment it was created in.\nDefine makeAdder(x) returning a function that adds x to its argument.",
+		check: "makeAdder(2)(3) == 5",
+		hint:  "let makeAdder = fn(x) { fn(y) { x + y } };",
+	},
+	{
+		title: "Arrays",
+		text:  "Arrays hold any values and are indexed from zero.\nBind nums to an array of 1, 2 and 3.",
+		check: "if (len(nums) == 3) { nums[0] + nums[1] * nums[2] == 7 } else { false }",
+		hint:  "let nums = [1, 2, 3];",
+	},
+	{
+		title: "Hashes",
+		text:  "Hashes map strings, integers and booleans to values.\nBind person to a hash whose \"name\" is \"Monkey\".",
+		check: `person["name"] == "Monkey"`,
+		hint:  `let person = {"name": "Monkey"};`,
+	},
+}
+
+// Tutor walks the user through the lessons, checking each answer before
+// moving on. ":hint" shows a possible answer, ":skip" skips a lesson and
+// ":quit" ends the tutorial.
+func Tutor(in io.Reader, out io.Writer) {
+	scanner := bufio.NewScanner(in)
+
+	s := &session{
+		out:        out,
+		env:        object.NewEnvironment(),
+		eval:       evaluator.New(evaluator.Options{}),
+		interrupts: newInterrupter(out),
+	}
+	defer s.interrupts.stop()
+
+	for i, l := range lessons {
+		fmt.Fprintf(out, "\nLesson %d/%d: %s\n%s\n", i+1, len(lessons), l.title, l.text)
+
+	attempt:
+		for {
+			fmt.Fprint(out, PROMPT)
+			if !scanner.Scan() {
+				fmt.Fprintln(out, "\nGoodbye!")
+				return
+			}
+
+			switch line := strings.TrimSpace(scanner.Text()); line {
+			case ":quit":
+				return
+			case ":skip":
+				break attempt
+			case ":hint":
+				fmt.Fprintln(out, l.hint)
+			default:
+				if result := s.run(line); result != nil {
+					s.env.Set("_", result)
+				}
+				if s.passes(l) {
+					fmt.Fprintln(out, "Well done!")
+					break attempt
+				}
+				fmt.Fprintln(out, "Not quite. Try again or type :hint.")
+			}
+		}
+	}
+
+	fmt.Fprintln(out, "\nThat's all the lessons. Happy hacking!")
+}
+
+func (s *session) passes(l lesson) bool {
+	p := parser.New(lexer.New(l.check))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return false
+	}
+
+	return evaluator.Eval(program, s.env) == object.TrueValue
+}
@@ -0,0 +1,129 @@
+// Package rules runs Monkey scripts as rules engines. A rule script
+// defines a function
+//
+//	let evaluate = fn(input) { ... };
+//
+// that is called with a hash of input values for every request and returns
+// a hash of results. Inputs and results can be checked against schemas.
+package rules
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/rock619/monkey/ast"
+	"github.com/rock619/monkey/evaluator"
+	"github.com/rock619/monkey/lexer"
+	"github.com/rock619/monkey/object"
+	"github.com/rock619/monkey/parser"
+)
+
+// EntryPoint is the name of the function a rule script must define.
+const EntryPoint = "evaluate"
+
+// Config configures an Engine.
+type Config struct {
+	// Syntax selects the dialect the script is written in.
+	Syntax parser.Options
+	// Eval configures the semantics of evaluation.
+	Eval evaluator.Options
+	// Input and Output, if not nil, are the schemas the input and the
+	// result of every call must conform to.
+	Input  Schema
+	Output Schema
+}
+
+// An Engine runs a rule script. It is safe for concurrent use: the script is
+// parsed once, and every goroutine gets an instance of its own, drawn from a
+// pool of instances that have already run the script's top level. State
+// the script keeps in top-level variables may therefore survive from one
+// call to another on the same instance and shouldn't be relied upon.
+type Engine struct {
+	name    string
+	config  Config
+	program *ast.Program
+	pool    sync.Pool
+}
+
+// An instance is the script loaded into an evaluator of its own.
+type instance struct {
+	eval *evaluator.Evaluator
+	fn   object.Object
+}
+
+// Load parses src, runs its top level and checks that it defines the entry
+// point. name is used as the file name in the positions of errors.
+func Load(name, src string, config Config) (*Engine, error) {
+	p := parser.NewWithOptions(lexer.NewFile(name, src), config.Syntax)
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return nil, parser.ErrorList(p.ErrorList())
+	}
+
+	config.Eval.Syntax = config.Syntax
+	e := &Engine{name: name, config: config, program: program}
+	inst, err := e.instance(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	e.pool.Put(inst)
+	return e, nil
+}
+
+// Evaluate calls the entry point of the script with input and returns its
+// result, converting both with object.FromGo and object.ToGo. Evaluation
+// stops with an error as soon as ctx is done.
+func (e *Engine) Evaluate(ctx context.Context, input map[string]any) (map[string]any, error) {
+	arg, err := object.FromGo(input)
+	if err != nil {
+		return nil, fmt.Errorf("input: %w", err)
+	}
+	if e.config.Input != nil {
+		if err := e.config.Input.Validate(arg); err != nil {
+			return nil, fmt.Errorf("input: %w", err)
+		}
+	}
+
+	inst, _ := e.pool.Get().(*instance)
+	if inst == nil {
+		if inst, err = e.instance(ctx); err != nil {
+			return nil, err
+		}
+	}
+	result := inst.eval.Call(ctx, inst.fn, arg)
+	e.pool.Put(inst)
+
+	if err, ok := result.(*object.Error); ok {
+		return nil, &parser.Error{Pos: err.Pos, Msg: err.Message}
+	}
+	if e.config.Output != nil {
+		if err := e.config.Output.Validate(result); err != nil {
+			return nil, fmt.Errorf("result: %w", err)
+		}
+	}
+
+	output, err := object.ToGo[map[string]any](result)
+	if err != nil {
+		return nil, fmt.Errorf("result: %w", err)
+	}
+	return output, nil
+}
+
+// instance runs the top level of the script in a new evaluator.
+func (e *Engine) instance(ctx context.Context) (*instance, error) {
+	eval := evaluator.New(e.config.Eval)
+	env := eval.NewEnvironment()
+	if err, ok := eval.Eval(ctx, e.program, env).(*object.Error); ok {
+		return nil, &parser.Error{Pos: err.Pos, Msg: err.Message}
+	}
+
+	fn, ok := env.Get(EntryPoint)
+	if !ok {
+		return nil, fmt.Errorf("%s: script doesn't define %s", e.name, EntryPoint)
+	}
+	if fn, ok := fn.(*object.Function); !ok || len(fn.Parameters) != 1 {
+		return nil, fmt.Errorf("%s: %s must be a function of one parameter", e.name, EntryPoint)
+	}
+	return &instance{eval: eval, fn: fn}, nil
+}
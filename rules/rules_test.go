@@ -0,0 +1,149 @@
+package rules
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/rock619/monkey/object"
+)
+
+const discountRule = `
+let rates = {"gold": 20, "silver": 10};
+
+let evaluate = fn(input) {
+	let percent = rates[input["tier"]];
+	{
+		"discount": input["total"] * percent / 100,
+		"approved": input["total"] < 1000,
+		"tags": [input["tier"], percent],
+	}
+};
+`
+
+var discountConfig = Config{
+	Input:  Schema{"tier": object.STRING_OBJ, "total": object.INTEGER_OBJ},
+	Output: Schema{"discount": object.INTEGER_OBJ, "approved": object.BOOLEAN_OBJ, "tags": Any},
+}
+
+func TestEvaluate(t *testing.T) {
+	engine, err := Load("discount.mky", discountRule, discountConfig)
+	if err != nil {
+		t.Fatalf("Load: %s", err)
+	}
+
+	got, err := engine.Evaluate(context.Background(), map[string]any{"tier": "gold", "total": 500})
+	if err != nil {
+		t.Fatalf("Evaluate: %s", err)
+	}
+	expected := map[string]any{
+		"discount": int64(100),
+		"approved": true,
+		"tags":     []any{"gold", int64(20)},
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("wrong result. got=%v, want=%v", got, expected)
+	}
+}
+
+func TestEvaluateConcurrently(t *testing.T) {
+	engine, err := Load("discount.mky", discountRule, discountConfig)
+	if err != nil {
+		t.Fatalf("Load: %s", err)
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 16)
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func(total int) {
+			defer wg.Done()
+			got, err := engine.Evaluate(context.Background(), map[string]any{"tier": "silver", "total": total})
+			if err != nil {
+				errs <- err
+				return
+			}
+			if got["discount"] != int64(total/10) {
+				errs <- fmt.Errorf("total %d: wrong discount %v", total, got["discount"])
+			}
+		}(i * 100)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Error(err)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let evaluate = ;", "rule.mky:1:16: unexpected ';', expected an expression (missing value after '=')"},
+		{"let x = 1;", "rule.mky: script doesn't define evaluate"},
+		{"let evaluate = 1;", "rule.mky: evaluate must be a function of one parameter"},
+		{"let evaluate = fn(a, b) { a };", "rule.mky: evaluate must be a function of one parameter"},
+		{"let evaluate = fn(input) { input }; missing;", "rule.mky:1:37: identifier not found: missing"},
+	}
+
+	for _, tt := range tests {
+		_, err := Load("rule.mky", tt.input, Config{})
+		if err == nil {
+			t.Errorf("input=%q: expected an error", tt.input)
+			continue
+		}
+		if err.Error() != tt.expected {
+			t.Errorf("input=%q: wrong error. got=%q, want=%q", tt.input, err, tt.expected)
+		}
+	}
+}
+
+func TestEvaluateErrors(t *testing.T) {
+	tests := []struct {
+		script   string
+		input    map[string]any
+		expected string
+	}{
+		{discountRule, map[string]any{"tier": "gold"}, `input: missing field "total"`},
+		{discountRule, map[string]any{"tier": 1, "total": 2, "x": true},
+			"input: field \"tier\" must be STRING, got INTEGER\nunexpected field \"x\""},
+		{discountRule, map[string]any{"tier": 1.5, "total": 2}, "input: field \"tier\" must be STRING, got FLOAT"},
+		{discountRule, map[string]any{"tier": 1i, "total": 2}, "input: cannot convert complex128 to a Monkey value"},
+		{"let evaluate = fn(input) { {\"discount\": true} };", map[string]any{"tier": "gold", "total": 1},
+			"result: field \"discount\" must be INTEGER, got BOOLEAN\nmissing field \"approved\"\nmissing field \"tags\""},
+		{"let evaluate = fn(input) { input[\"total\"] + \"x\" };", map[string]any{"tier": "gold", "total": 1},
+			"rule.mky:1:43: type mismatch: INTEGER + STRING"},
+	}
+
+	for _, tt := range tests {
+		engine, err := Load("rule.mky", tt.script, discountConfig)
+		if err != nil {
+			t.Fatalf("Load: %s", err)
+		}
+		_, err = engine.Evaluate(context.Background(), tt.input)
+		if err == nil {
+			t.Errorf("input=%v: expected an error", tt.input)
+			continue
+		}
+		if err.Error() != tt.expected {
+			t.Errorf("input=%v: wrong error. got=%q, want=%q", tt.input, err, tt.expected)
+		}
+	}
+}
+
+func TestResultMustBeHash(t *testing.T) {
+	engine, err := Load("rule.mky", "let evaluate = fn(input) { 1 };", Config{})
+	if err != nil {
+		t.Fatalf("Load: %s", err)
+	}
+
+	_, err = engine.Evaluate(context.Background(), nil)
+	expected := "result: cannot use INTEGER as map[string]interface {}"
+	if err == nil || err.Error() != expected {
+		t.Errorf("wrong error. got=%v, want=%q", err, expected)
+	}
+}
@@ -0,0 +1,54 @@
+package rules
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/rock619/monkey/object"
+)
+
+// Any accepts a value of any type in a Schema.
+const Any object.ObjectType = ""
+
+// A Schema declares the fields of a hash: every field must be present,
+// with a string key and a value of the given type, and no other fields are
+// allowed.
+type Schema map[string]object.ObjectType
+
+// Validate reports every way in which obj doesn't conform to s.
+func (s Schema) Validate(obj object.Object) error {
+	hash, ok := obj.(*object.Hash)
+	if !ok {
+		return fmt.Errorf("want a HASH, got %s", obj.Type())
+	}
+
+	var errs []error
+	seen := make(map[string]bool, len(hash.Pairs))
+	for _, pair := range hash.Pairs {
+		key, ok := pair.Key.(*object.String)
+		if !ok {
+			errs = append(errs, fmt.Errorf("key %s is not a STRING", pair.Key.Inspect()))
+			continue
+		}
+		seen[key.Value] = true
+
+		want, declared := s[key.Value]
+		switch {
+		case !declared:
+			errs = append(errs, fmt.Errorf("unexpected field %q", key.Value))
+		case want != Any && pair.Value.Type() != want:
+			errs = append(errs, fmt.Errorf("field %q must be %s, got %s",
+				key.Value, want, pair.Value.Type()))
+		}
+	}
+
+	for name := range s {
+		if !seen[name] {
+			errs = append(errs, fmt.Errorf("missing field %q", name))
+		}
+	}
+
+	sort.Slice(errs, func(i, j int) bool { return errs[i].Error() < errs[j].Error() })
+	return errors.Join(errs...)
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/rock619/monkey/ast"
+	"github.com/rock619/monkey/evaluator"
+	"github.com/rock619/monkey/lexer"
+	"github.com/rock619/monkey/object"
+	"github.com/rock619/monkey/parser"
+)
+
+// run evaluates the files in order in a shared environment, so that later
+// files can use what earlier ones define. A directory stands for the main
+// module of the project in it, whose modules can then be imported. Errors
+// are reported to stderr with the name of the file they occurred in. It
+// returns the exit status. With -watch it runs the files again whenever one
+// of them changes. With -trace-out it writes a trace of the calls made by
+// each run for chrome://tracing.
+func run(args []string, syntax parser.Options, opts evaluator.Options) int {
+	flags := flag.NewFlagSet("run", flag.ContinueOnError)
+	asJSON := flags.Bool("json", false, "report errors as JSON diagnostics")
+	watchFiles := flags.Bool("watch", false, "run again whenever one of the files changes")
+	flags.BoolVar(&opts.Stats, "stats", false, "print the resources used to stderr after the run")
+	traceOut := flags.String("trace-out", "", "write a trace of the calls in the Chrome tracing format to `file`")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() == 0 {
+		fmt.Fprintln(os.Stderr, "usage: monkey run [-json] [-watch] [-stats] [-trace-out file] file|dir...")
+		return 2
+	}
+
+	files, modulePath, err := resolveFiles(flags.Args())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	opts.ModulePath = append(opts.ModulePath, modulePath...)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	runOnce := func() int {
+		if *traceOut == "" {
+			return runFiles(ctx, files, syntax, opts, *asJSON)
+		}
+		opts := opts
+		opts.Trace = &evaluator.Trace{}
+		status := runFiles(ctx, files, syntax, opts, *asJSON)
+		if err := writeTrace(*traceOut, opts.Trace); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		return status
+	}
+	if *watchFiles {
+		return watch(ctx, files, runOnce)
+	}
+	return runOnce()
+}
+
+func runFiles(ctx context.Context, files []string, syntax parser.Options, opts evaluator.Options, asJSON bool) int {
+	eval := evaluator.New(opts)
+	env := eval.NewEnvironment()
+	if opts.Stats {
+		defer func() { printStats(os.Stderr, eval.Stats()) }()
+	}
+
+	for _, file := range files {
+		program, diags, err := parseFile(file, syntax)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		if len(diags) != 0 {
+			printDiagnostics(os.Stderr, diags, asJSON)
+			return 1
+		}
+
+		if err, ok := eval.Eval(ctx, program, env).(*object.Error); ok {
+			if asJSON {
+				printDiagnostics(os.Stderr, []diagnostic{runtimeDiagnostic(err)}, true)
+			} else {
+				fmt.Fprintln(os.Stderr, err.Inspect())
+			}
+			return 1
+		}
+	}
+
+	return 0
+}
+
+// vet parses the files without running them and reports every syntax
+// error. It returns the exit status.
+func vet(args []string, syntax parser.Options) int {
+	flags := flag.NewFlagSet("vet", flag.ContinueOnError)
+	asJSON := flags.Bool("json", false, "report errors as JSON diagnostics")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() == 0 {
+		fmt.Fprintln(os.Stderr, "usage: monkey vet [-json] file...")
+		return 2
+	}
+
+	status := 0
+	for _, file := range flags.Args() {
+		_, diags, err := parseFile(file, syntax)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			status = 1
+			continue
+		}
+		if len(diags) != 0 {
+			printDiagnostics(os.Stderr, diags, *asJSON)
+			status = 1
+		}
+	}
+	return status
+}
+
+func parseFile(file string, syntax parser.Options) (*ast.Program, []diagnostic, error) {
+	src, err := os.ReadFile(file)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	p := parser.NewWithOptions(lexer.NewFile(file, string(src)), syntax)
+	program := p.ParseProgram()
+	return program, syntaxDiagnostics(p.ErrorList()), nil
+}
+
+func writeTrace(file string, trace *evaluator.Trace) error {
+	f, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	if err := trace.WriteJSON(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func printStats(w io.Writer, stats evaluator.Stats) {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "nodes evaluated:\t%d\n", stats.Nodes)
+	fmt.Fprintf(tw, "max call depth:\t%d\n", stats.MaxDepth)
+	fmt.Fprintf(tw, "script time:\t%s\n", stats.ScriptTime)
+	fmt.Fprintf(tw, "builtin time:\t%s\n", stats.BuiltinTime)
+
+	types := make([]string, 0, len(stats.Allocations))
+	for typ := range stats.Allocations {
+		types = append(types, string(typ))
+	}
+	sort.Strings(types)
+	for _, typ := range types {
+		fmt.Fprintf(tw, "%s values:\t%d\n", typ, stats.Allocations[object.ObjectType(typ)])
+	}
+	tw.Flush()
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sync"
+
+	"github.com/rock619/monkey/evaluator"
+	"github.com/rock619/monkey/object"
+	"github.com/rock619/monkey/parser"
+	"github.com/rock619/monkey/repl"
+)
+
+// defaultSocket is the socket serve listens on and attach connects to.
+var defaultSocket = filepath.Join(os.TempDir(), "monkey-repl.sock")
+
+// serve runs the files like run and then, with -repl, keeps their
+// environment open to REPL sessions on a Unix socket until interrupted.
+func serve(args []string, syntax parser.Options, opts evaluator.Options) int {
+	flags := flag.NewFlagSet("serve", flag.ContinueOnError)
+	withREPL := flags.Bool("repl", false, "serve REPL sessions that monkey attach connects to")
+	socket := flags.String("socket", defaultSocket, "path of the Unix socket to listen on")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if !*withREPL {
+		fmt.Fprintln(os.Stderr, "usage: monkey serve -repl [-socket path] [file...]")
+		return 2
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	eval := evaluator.New(opts)
+	env := eval.NewEnvironment()
+	var mu sync.Mutex
+	for _, file := range flags.Args() {
+		program, diags, err := parseFile(file, syntax)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		if len(diags) != 0 {
+			printDiagnostics(os.Stderr, diags, false)
+			return 1
+		}
+
+		mu.Lock()
+		result := eval.Eval(ctx, program, env)
+		mu.Unlock()
+		if err, ok := result.(*object.Error); ok {
+			fmt.Fprintln(os.Stderr, err.Inspect())
+			return 1
+		}
+	}
+
+	l, err := net.Listen("unix", *socket)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	fmt.Fprintf(os.Stderr, "serving REPL sessions on %s\n", *socket)
+
+	srv := &repl.Server{
+		Env:     env,
+		Options: repl.Options{Syntax: syntax, Eval: opts, Quiet: true},
+		Lock:    &mu,
+	}
+	if err := srv.Serve(ctx, l); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
+
+// attach connects the terminal to a REPL session of monkey serve.
+func attach(args []string) int {
+	flags := flag.NewFlagSet("attach", flag.ContinueOnError)
+	socket := flags.String("socket", defaultSocket, "path of the Unix socket to connect to")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	conn, err := net.Dial("unix", *socket)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer conn.Close()
+
+	go func() {
+		io.Copy(conn, os.Stdin)
+		// Ending the input ends the session, which closes the connection.
+		conn.(*net.UnixConn).CloseWrite()
+	}()
+	if _, err := io.Copy(os.Stdout, conn); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,350 @@
+// Package template renders text with embedded Monkey expressions, for
+// generating configuration files and the like.
+//
+// A template is plain text containing actions:
+//
+//	{{ expr }}                              the value of expr
+//	{% if expr %} ... {% end %}             the text between if expr is truthy
+//	{% if expr %} ... {% else %} ... {% end %}
+//
+// Strings are inserted without quotes and null as nothing; other values
+// appear as puts prints them, using the toString functions of hashes. An
+// action ends at the first }} or %} after
+// it starts, so a hash literal closing inside {{ }} needs a space: {{ {"a": 1} }}.
+// A line holding nothing but a {% %} tag is dropped entirely, so that
+// control tags don't leave blank lines behind.
+package template
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/rock619/monkey/ast"
+	"github.com/rock619/monkey/evaluator"
+	"github.com/rock619/monkey/lexer"
+	"github.com/rock619/monkey/object"
+	"github.com/rock619/monkey/parser"
+	"github.com/rock619/monkey/token"
+)
+
+// Template is a parsed template, ready to be executed any number of times.
+type Template struct {
+	name  string
+	nodes []node
+}
+
+type node interface{}
+
+type textNode string
+
+type exprNode struct {
+	exp ast.Expression
+}
+
+type ifNode struct {
+	cond        ast.Expression
+	consequence []node
+	alternative []node
+}
+
+// Parse parses text as a template. name is used as the file name in the
+// positions of errors. Expressions are parsed in the dialect given by
+// syntax. The returned error is a *parser.Error or a parser.ErrorList.
+func Parse(name, text string, syntax parser.Options) (*Template, error) {
+	s := &state{name: name, text: text, syntax: syntax}
+	if err := s.scan(); err != nil {
+		return nil, err
+	}
+
+	nodes, stop, err := s.list()
+	if err != nil {
+		return nil, err
+	}
+	if stop != nil {
+		return nil, s.errorf(stop.off, "unexpected {%% %s %%}", stop.keyword)
+	}
+	return &Template{name: name, nodes: nodes}, nil
+}
+
+// Name returns the name the template was parsed with.
+func (t *Template) Name() string { return t.name }
+
+// Execute renders t to w, evaluating its expressions with e in env. ctx
+// cancels the evaluation like it does for Evaluator.Eval. Evaluation errors
+// are returned as a *parser.Error locating the failure in the template.
+func (t *Template) Execute(ctx context.Context, w io.Writer, e *evaluator.Evaluator, env *object.Environment) error {
+	return execute(ctx, w, e, env, t.nodes)
+}
+
+func execute(ctx context.Context, w io.Writer, e *evaluator.Evaluator, env *object.Environment, nodes []node) error {
+	for _, n := range nodes {
+		switch n := n.(type) {
+		case textNode:
+			if _, err := io.WriteString(w, string(n)); err != nil {
+				return err
+			}
+		case exprNode:
+			val, err := evaluate(ctx, e, n.exp, env)
+			if err != nil {
+				return err
+			}
+			s, err := text(ctx, e, n.exp, val)
+			if err != nil {
+				return err
+			}
+			if _, err := io.WriteString(w, s); err != nil {
+				return err
+			}
+		case *ifNode:
+			cond, err := evaluate(ctx, e, n.cond, env)
+			if err != nil {
+				return err
+			}
+			branch := n.alternative
+			if e.Truthy(cond) {
+				branch = n.consequence
+			}
+			if err := execute(ctx, w, e, env, branch); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func evaluate(ctx context.Context, e *evaluator.Evaluator, exp ast.Expression, env *object.Environment) (object.Object, error) {
+	val := e.Eval(ctx, exp, env)
+	if err, ok := val.(*object.Error); ok {
+		return nil, &parser.Error{Pos: err.Pos, Msg: err.Message}
+	}
+	return val, nil
+}
+
+// text returns val, the value of exp, as it is inserted into the output.
+func text(ctx context.Context, e *evaluator.Evaluator, exp ast.Expression, val object.Object) (string, error) {
+	switch val := val.(type) {
+	case nil, *object.Null:
+		return "", nil
+	case *object.String:
+		return val.Value, nil
+	}
+
+	s, err := e.ToString(ctx, val)
+	if err != nil {
+		pos := err.Pos
+		if !pos.IsValid() {
+			pos = exp.Pos()
+		}
+		return "", &parser.Error{Pos: pos, Msg: err.Message}
+	}
+	return s, nil
+}
+
+type itemType int
+
+const (
+	itemText itemType = iota
+	itemExpr
+	itemTag
+)
+
+// An item is a run of text or an action of the template.
+type item struct {
+	typ itemType
+	// val is the text, or the inside of the action without its delimiters.
+	val string
+	// off is the offset of val in the template.
+	off int
+}
+
+// A tag is a {% %} action split into its keyword and argument.
+type tag struct {
+	keyword string
+	arg     string
+	argOff  int
+	off     int
+}
+
+type state struct {
+	name   string
+	text   string
+	syntax parser.Options
+
+	items []item
+	next  int
+}
+
+// scan splits the template into items.
+func (s *state) scan() error {
+	off := 0
+	for off < len(s.text) {
+		i := indexAction(s.text[off:])
+		if i < 0 {
+			s.items = append(s.items, item{typ: itemText, val: s.text[off:], off: off})
+			break
+		}
+		i += off
+
+		typ, closing := itemExpr, "}}"
+		if s.text[i+1] == '%' {
+			typ, closing = itemTag, "%}"
+		}
+		j := strings.Index(s.text[i+2:], closing)
+		if j < 0 {
+			return s.errorf(i, "unterminated action, expected %s", closing)
+		}
+		inner := s.text[i+2 : i+2+j]
+		start, end := i, i+2+j+2
+
+		if typ == itemTag {
+			start, end = s.trimLine(off, start, end)
+		}
+		if start > off {
+			s.items = append(s.items, item{typ: itemText, val: s.text[off:start], off: off})
+		}
+		s.items = append(s.items, item{typ: typ, val: inner, off: i + 2})
+		off = end
+	}
+	return nil
+}
+
+// indexAction returns the index of the first {{ or {% in text, or -1.
+func indexAction(text string) int {
+	for i := 0; i+1 < len(text); i++ {
+		if text[i] == '{' && (text[i+1] == '{' || text[i+1] == '%') {
+			return i
+		}
+	}
+	return -1
+}
+
+// trimLine widens the tag at text[start:end] to its whole line, newline
+// included, if nothing else is on that line. Text before from, which
+// belongs to an earlier action, is never taken.
+func (s *state) trimLine(from, start, end int) (int, int) {
+	lineStart := strings.LastIndexByte(s.text[:start], '\n') + 1
+	if lineStart < from || strings.TrimSpace(s.text[lineStart:start]) != "" {
+		return start, end
+	}
+
+	lineEnd := len(s.text)
+	if nl := strings.IndexByte(s.text[end:], '\n'); nl >= 0 {
+		lineEnd = end + nl + 1
+	}
+	if strings.TrimSpace(s.text[end:lineEnd]) != "" {
+		return start, end
+	}
+	return lineStart, lineEnd
+}
+
+// list parses items up to the end of the template or an else or end tag,
+// which it returns.
+func (s *state) list() ([]node, *tag, error) {
+	var nodes []node
+	for s.next < len(s.items) {
+		it := s.items[s.next]
+		s.next++
+
+		switch it.typ {
+		case itemText:
+			nodes = append(nodes, textNode(it.val))
+		case itemExpr:
+			exp, err := s.expression(it.val, it.off)
+			if err != nil {
+				return nil, nil, err
+			}
+			nodes = append(nodes, exprNode{exp: exp})
+		case itemTag:
+			t := splitTag(it)
+			switch t.keyword {
+			case "if":
+				n, err := s.ifNode(t)
+				if err != nil {
+					return nil, nil, err
+				}
+				nodes = append(nodes, n)
+			case "else", "end":
+				if t.arg != "" {
+					return nil, nil, s.errorf(t.argOff, "unexpected %q after %s", t.arg, t.keyword)
+				}
+				return nodes, &t, nil
+			case "":
+				return nil, nil, s.errorf(t.off, "empty {%% %%} tag")
+			default:
+				return nil, nil, s.errorf(t.off, "unknown tag %q", t.keyword)
+			}
+		}
+	}
+	return nodes, nil, nil
+}
+
+func (s *state) ifNode(t tag) (*ifNode, error) {
+	cond, err := s.expression(t.arg, t.argOff)
+	if err != nil {
+		return nil, err
+	}
+	n := &ifNode{cond: cond}
+
+	var stop *tag
+	n.consequence, stop, err = s.list()
+	if err != nil {
+		return nil, err
+	}
+	if stop != nil && stop.keyword == "else" {
+		n.alternative, stop, err = s.list()
+		if err != nil {
+			return nil, err
+		}
+		if stop != nil && stop.keyword == "else" {
+			return nil, s.errorf(stop.off, "unexpected {%% else %%}, expected {%% end %%}")
+		}
+	}
+	if stop == nil {
+		return nil, s.errorf(t.off, "{%% if %%} without {%% end %%}")
+	}
+	return n, nil
+}
+
+// splitTag splits a tag item into its keyword and the rest.
+func splitTag(it item) tag {
+	trimmed := strings.TrimLeft(it.val, " \t\r\n")
+	off := it.off + len(it.val) - len(trimmed)
+
+	keyword := trimmed
+	if i := strings.IndexAny(trimmed, " \t\r\n"); i >= 0 {
+		keyword = trimmed[:i]
+	}
+	arg := trimmed[len(keyword):]
+	argOff := off + len(keyword)
+
+	trimmedArg := strings.TrimLeft(arg, " \t\r\n")
+	argOff += len(arg) - len(trimmedArg)
+
+	return tag{
+		keyword: keyword,
+		arg:     strings.TrimRight(trimmedArg, " \t\r\n"),
+		argOff:  argOff,
+		off:     off,
+	}
+}
+
+// expression parses src, found at offset off of the template, as a single
+// expression.
+func (s *state) expression(src string, off int) (ast.Expression, error) {
+	l := lexer.NewAt(s.text[:off+len(src)], s.position(off))
+	return parser.NewWithOptions(l, s.syntax).ParseSingleExpression()
+}
+
+func (s *state) errorf(off int, format string, a ...any) error {
+	return &parser.Error{Pos: s.position(off), Msg: fmt.Sprintf(format, a...)}
+}
+
+// position returns the position of offset off in the template.
+func (s *state) position(off int) token.Position {
+	before := s.text[:off]
+	line := strings.Count(before, "\n") + 1
+	col := off - strings.LastIndexByte(before, '\n')
+	return token.Position{Filename: s.name, Line: line, Column: col}
+}
@@ -0,0 +1,106 @@
+package template
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/rock619/monkey/evaluator"
+	"github.com/rock619/monkey/object"
+	"github.com/rock619/monkey/parser"
+)
+
+func render(t *testing.T, text string, vars map[string]object.Object) (string, error) {
+	t.Helper()
+
+	syntax, _ := parser.LookupDialect(parser.DefaultDialect)
+	tmpl, err := Parse("test.tmpl", text, syntax)
+	if err != nil {
+		return "", err
+	}
+
+	e := evaluator.New(evaluator.Options{Prelude: true})
+	env := e.NewEnvironment()
+	for name, val := range vars {
+		env.Set(name, val)
+	}
+
+	var out strings.Builder
+	err = tmpl.Execute(context.Background(), &out, e, env)
+	return out.String(), err
+}
+
+func TestExecute(t *testing.T) {
+	vars := map[string]object.Object{
+		"host":  &object.String{Value: "example.com"},
+		"port":  &object.Integer{Value: 8080},
+		"debug": object.FalseValue,
+	}
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"plain text", "plain text"},
+		{"", ""},
+		{"{{ host }}:{{ port + 1 }}", "example.com:8081"},
+		{`{{ [1, "a"] }} {{ {"k": true} }}`, `[1, "a"] {"k": true}`},
+		{"[{{ if (false) { 1 } }}]", "[]"},
+		{"{% if debug %}on{% else %}off{% end %}", "off"},
+		{"{% if port > 80 %}high{% end %}", "high"},
+		{"{{ len(map([1, 2, 3], fn(x) { x })) }}", "3"},
+		{"a { b } c", "a { b } c"},
+		{
+			"server {\n  listen {{ port }};\n  {% if debug %}\n  debug on;\n  {% else %}\n  debug off;\n  {% end %}\n}\n",
+			"server {\n  listen 8080;\n  debug off;\n}\n",
+		},
+		{
+			"{% if true %}{% if false %}x{% else %}y{% end %}{% end %}",
+			"y",
+		},
+		{"x {% if true %}y{% end %} z\n", "x y z\n"},
+		{`{{ {"toString": fn(self) { "point" } } }}`, "point"},
+		{`{{ [{"toString": fn(self) { "point" } }, "s"] }}`, `[point, "s"]`},
+	}
+
+	for _, tt := range tests {
+		got, err := render(t, tt.input, vars)
+		if err != nil {
+			t.Errorf("input=%q: unexpected error: %s", tt.input, err)
+			continue
+		}
+		if got != tt.expected {
+			t.Errorf("input=%q: wrong output. got=%q, want=%q", tt.input, got, tt.expected)
+		}
+	}
+}
+
+func TestErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"a {{ 1 + ", "test.tmpl:1:3: unterminated action, expected }}"},
+		{"{% if true %}x", "test.tmpl:1:4: {% if %} without {% end %}"},
+		{"x\n{% end %}", "test.tmpl:2:4: unexpected {% end %}"},
+		{"{% if true %}{% else %}{% else %}{% end %}", "test.tmpl:1:27: unexpected {% else %}, expected {% end %}"},
+		{"{% for x %}", `test.tmpl:1:4: unknown tag "for"`},
+		{"{% end x %}", `test.tmpl:1:8: unexpected "x" after end`},
+		{"{% %}", "test.tmpl:1:4: empty {% %} tag"},
+		{"line\n  {{ 1 + }}", "test.tmpl:2:10: unexpected end of input, expected an expression (missing operand after '+')"},
+		{"{{ 1 2 }}", "test.tmpl:1:6: unexpected '2' after expression"},
+		{"ok\n{{ missing }}", "test.tmpl:2:4: identifier not found: missing"},
+		{`{{ [{"toString": fn(self) { 1 } }] }}`, "test.tmpl:1:4: toString must return STRING, got INTEGER"},
+	}
+
+	for _, tt := range tests {
+		_, err := render(t, tt.input, nil)
+		if err == nil {
+			t.Errorf("input=%q: expected an error", tt.input)
+			continue
+		}
+		if err.Error() != tt.expected {
+			t.Errorf("input=%q: wrong error. got=%q, want=%q", tt.input, err, tt.expected)
+		}
+	}
+}
@@ -1,57 +1,229 @@
 package token
 
-type TokenType string
+import (
+	"fmt"
+	"sort"
+	"strconv"
+)
+
+// TokenType identifies the kind of a token. The zero value is ILLEGAL.
+type TokenType int
 
 type Token struct {
 	Type    TokenType
 	Literal string
+	Pos     Position
+}
+
+// Position is a 1-based line and column in the source text. Columns count
+// bytes, not runes. Filename is empty for input that doesn't come from a
+// file.
+type Position struct {
+	Filename string
+	Line     int
+	Column   int
+}
+
+func (p Position) IsValid() bool { return p.Line > 0 }
+
+// Before reports whether p comes before q, ordering by filename, then by
+// line and column.
+func (p Position) Before(q Position) bool {
+	if p.Filename != q.Filename {
+		return p.Filename < q.Filename
+	}
+	if p.Line != q.Line {
+		return p.Line < q.Line
+	}
+	return p.Column < q.Column
+}
+
+// String returns the position as "file:line:col", "line:col" without a
+// filename, or "-" if it is invalid.
+func (p Position) String() string {
+	if !p.IsValid() {
+		if p.Filename != "" {
+			return p.Filename
+		}
+		return "-"
+	}
+	if p.Filename != "" {
+		return fmt.Sprintf("%s:%d:%d", p.Filename, p.Line, p.Column)
+	}
+	return fmt.Sprintf("%d:%d", p.Line, p.Column)
 }
 
 const (
-	ILLEGAL = "ILLEGAL"
-	EOF     = "EOF"
+	ILLEGAL TokenType = iota
+	EOF
+	// WHITESPACE is a run of spaces, tabs and newlines, which the lexer
+	// only returns with Lexer.KeepTrivia.
+	WHITESPACE
+	// COMMENT is a comment from // to the end of its line or a block
+	// comment /* ... */, which the lexer also only returns with
+	// Lexer.KeepTrivia.
+	COMMENT
 
+	literalBeg
 	// Identifiers + literals
-	IDENT  = "IDENT" // add, foobar, x, y, ...
-	INT    = "INT"   // 1343456
-	STRING = "STRING"
+	IDENT    // add, foobar, x, y, ...
+	INT      // 1343456
+	FLOAT    // 3.14, 1e-9
+	DURATION // 5s, 100ms, 1h30m
+	STRING   // "foobar"
+	literalEnd
 
+	operatorBeg
 	// Operators
-	ASSIGN   = "="
-	PLUS     = "+"
-	MINUS    = "-"
-	BANG     = "!"
-	ASTERISK = "*"
-	SLASH    = "/"
+	ASSIGN
+	PLUS
+	MINUS
+	BANG
+	ASTERISK
+	SLASH
+	PERCENT
 
-	LT = "<"
-	GT = ">"
+	LT
+	GT
 
-	EQ     = "=="
-	NOT_EQ = "!="
+	EQ
+	NOT_EQ
+	operatorEnd
 
 	// Delimiters
-	COMMA     = ","
-	SEMICOLON = ";"
-	COLON     = ":"
+	COMMA
+	SEMICOLON
+	COLON
+	DOT
 
-	LPAREN   = "("
-	RPAREN   = ")"
-	LBRACE   = "{"
-	RBRACE   = "}"
-	LBRACKET = "["
-	RBRACKET = "]"
+	LPAREN
+	RPAREN
+	LBRACE
+	RBRACE
+	LBRACKET
+	RBRACKET
 
+	keywordBeg
 	// Keywords
-	FUNCTION = "FUNCTION"
-	LET      = "LET"
-	TRUE     = "TRUE"
-	FALSE    = "FALSE"
-	IF       = "IF"
-	ELSE     = "ELSE"
-	RETURN   = "RETURN"
+	FUNCTION
+	LET
+	TRUE
+	FALSE
+	IF
+	ELSE
+	RETURN
+	DEFER
+	WHILE
+	FOR
+	keywordEnd
+)
+
+// CUSTOM is the first token type free for extensions of the language. Use
+// CUSTOM, CUSTOM+1, ... together with Lexer.RegisterOperator and
+// Parser.RegisterInfix or Parser.RegisterPrefix.
+const CUSTOM TokenType = 1000
+
+var tokens = [...]string{
+	ILLEGAL:    "ILLEGAL",
+	EOF:        "EOF",
+	WHITESPACE: "WHITESPACE",
+	COMMENT:    "COMMENT",
+
+	IDENT:    "IDENT",
+	INT:      "INT",
+	FLOAT:    "FLOAT",
+	DURATION: "DURATION",
+	STRING:   "STRING",
+
+	ASSIGN:   "=",
+	PLUS:     "+",
+	MINUS:    "-",
+	BANG:     "!",
+	ASTERISK: "*",
+	SLASH:    "/",
+	PERCENT:  "%",
+
+	LT: "<",
+	GT: ">",
+
+	EQ:     "==",
+	NOT_EQ: "!=",
+
+	COMMA:     ",",
+	SEMICOLON: ";",
+	COLON:     ":",
+	DOT:       ".",
+
+	LPAREN:   "(",
+	RPAREN:   ")",
+	LBRACE:   "{",
+	RBRACE:   "}",
+	LBRACKET: "[",
+	RBRACKET: "]",
+
+	FUNCTION: "FUNCTION",
+	LET:      "LET",
+	TRUE:     "TRUE",
+	FALSE:    "FALSE",
+	IF:       "IF",
+	ELSE:     "ELSE",
+	RETURN:   "RETURN",
+	DEFER:    "DEFER",
+	WHILE:    "WHILE",
+	FOR:      "FOR",
+}
+
+// String returns the name the token type had when TokenType was a string:
+// the operator or delimiter itself, or an upper-case name otherwise.
+func (t TokenType) String() string {
+	if 0 <= t && int(t) < len(tokens) && tokens[t] != "" {
+		return tokens[t]
+	}
+	return "TokenType(" + strconv.Itoa(int(t)) + ")"
+}
+
+func (t TokenType) IsLiteral() bool  { return literalBeg < t && t < literalEnd }
+func (t TokenType) IsOperator() bool { return operatorBeg < t && t < operatorEnd }
+func (t TokenType) IsKeyword() bool  { return keywordBeg < t && t < keywordEnd }
+
+// Operator precedences, from loosest to tightest binding.
+const (
+	_ int = iota
+	LowestPrec
+	AssignPrec      // x = y
+	EqualsPrec      // ==
+	LessGreaterPrec // > or <
+	SumPrec         // +
+	ProductPrec     // * / %
+	PrefixPrec      // -X or +X
+	CallPrec        // myFunction(X)
+	IndexPrec       // array[index] or value.member
 )
 
+var precedences = map[TokenType]int{
+	EQ:       EqualsPrec,
+	NOT_EQ:   EqualsPrec,
+	LT:       LessGreaterPrec,
+	GT:       LessGreaterPrec,
+	PLUS:     SumPrec,
+	MINUS:    SumPrec,
+	SLASH:    ProductPrec,
+	ASTERISK: ProductPrec,
+	PERCENT:  ProductPrec,
+	LPAREN:   CallPrec,
+	LBRACKET: IndexPrec,
+	DOT:      IndexPrec,
+}
+
+// Precedence returns the binding power of t used as an infix operator, or
+// LowestPrec if t is not one.
+func (t TokenType) Precedence() int {
+	if p, ok := precedences[t]; ok {
+		return p
+	}
+	return LowestPrec
+}
+
 var keywords = map[string]TokenType{
 	"fn":     FUNCTION,
 	"let":    LET,
@@ -60,6 +232,33 @@ var keywords = map[string]TokenType{
 	"if":     IF,
 	"else":   ELSE,
 	"return": RETURN,
+	"defer":  DEFER,
+	"while":  WHILE,
+	"for":    FOR,
+}
+
+// Keywords returns the keywords of the language in lexical order.
+func Keywords() []string {
+	words := make([]string, 0, len(keywords))
+	for word := range keywords {
+		words = append(words, word)
+	}
+	sort.Strings(words)
+	return words
+}
+
+// Types returns every token type of the language, without the types of
+// extensions, in the order they are declared.
+func Types() []TokenType {
+	var types []TokenType
+	for t := ILLEGAL; t < keywordEnd; t++ {
+		switch t {
+		case literalBeg, literalEnd, operatorBeg, operatorEnd, keywordBeg:
+			continue
+		}
+		types = append(types, t)
+	}
+	return types
 }
 
 func LookupIdent(ident string) TokenType {
@@ -68,3 +267,14 @@ func LookupIdent(ident string) TokenType {
 	}
 	return IDENT
 }
+
+// Lookup returns the token type whose String is name, or ILLEGAL if there
+// is none. It eases migrating code that stored token types as strings.
+func Lookup(name string) TokenType {
+	for t, s := range tokens {
+		if s == name {
+			return TokenType(t)
+		}
+	}
+	return ILLEGAL
+}
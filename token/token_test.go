@@ -0,0 +1,79 @@
+package token
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTokenTypeString(t *testing.T) {
+	for _, tt := range Types() {
+		assert.Equal(t, tt, Lookup(tt.String()), "round trip of %s", tt)
+	}
+
+	assert.Equal(t, "==", EQ.String())
+	assert.Equal(t, "FUNCTION", FUNCTION.String())
+	assert.Equal(t, ILLEGAL, Lookup("no such token"))
+}
+
+func TestClassification(t *testing.T) {
+	tests := []struct {
+		tokenType  TokenType
+		isLiteral  bool
+		isOperator bool
+		isKeyword  bool
+		precedence int
+	}{
+		{IDENT, true, false, false, LowestPrec},
+		{STRING, true, false, false, LowestPrec},
+		{PLUS, false, true, false, SumPrec},
+		{ASTERISK, false, true, false, ProductPrec},
+		{NOT_EQ, false, true, false, EqualsPrec},
+		{LPAREN, false, false, false, CallPrec},
+		{SEMICOLON, false, false, false, LowestPrec},
+		{LET, false, false, true, LowestPrec},
+		{RETURN, false, false, true, LowestPrec},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.isLiteral, tt.tokenType.IsLiteral(), "%s.IsLiteral()", tt.tokenType)
+		assert.Equal(t, tt.isOperator, tt.tokenType.IsOperator(), "%s.IsOperator()", tt.tokenType)
+		assert.Equal(t, tt.isKeyword, tt.tokenType.IsKeyword(), "%s.IsKeyword()", tt.tokenType)
+		assert.Equal(t, tt.precedence, tt.tokenType.Precedence(), "%s.Precedence()", tt.tokenType)
+	}
+}
+
+func TestPositionString(t *testing.T) {
+	assert.Equal(t, "-", Position{}.String())
+	assert.Equal(t, "a.mky", Position{Filename: "a.mky"}.String())
+	assert.Equal(t, "3:4", Position{Line: 3, Column: 4}.String())
+	assert.Equal(t, "a.mky:3:4", Position{Filename: "a.mky", Line: 3, Column: 4}.String())
+}
+
+func TestPositionBefore(t *testing.T) {
+	positions := []Position{
+		{Line: 1, Column: 9},
+		{Line: 2, Column: 1},
+		{Line: 2, Column: 3},
+		{Filename: "a.mky", Line: 1, Column: 1},
+		{Filename: "b.mky", Line: 1, Column: 1},
+	}
+	for i, p := range positions {
+		for j, q := range positions {
+			assert.Equal(t, i < j, p.Before(q), "%s before %s", p, q)
+		}
+	}
+}
+
+func TestKeywordsAndTypes(t *testing.T) {
+	assert.Equal(t, []string{"defer", "else", "false", "fn", "for", "if", "let", "return", "true", "while"}, Keywords())
+	for _, word := range Keywords() {
+		assert.True(t, LookupIdent(word).IsKeyword(), word)
+	}
+
+	types := Types()
+	assert.Equal(t, ILLEGAL, types[0])
+	assert.Contains(t, types, DOT)
+	assert.Contains(t, types, DEFER)
+	assert.NotContains(t, types, keywordBeg)
+}
@@ -0,0 +1,176 @@
+// Command crossbench runs a corpus of Monkey programs with this
+// interpreter and, optionally, with another Monkey implementation, and
+// compares their output and speed:
+//
+//	crossbench [-n runs] [-other binary [-stdin]] [dir|file...]
+//
+// The other implementation is run as "binary file", or with the program on
+// its standard input with -stdin. Outputs match if they are equal apart
+// from trailing white space. The corpus defaults to the programs next to
+// this command, which stick to the language of the book so that any
+// implementation of it can run them. The exit status is 1 if any output
+// differs or a program fails.
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/rock619/monkey/evaluator"
+	"github.com/rock619/monkey/lexer"
+	"github.com/rock619/monkey/object"
+	"github.com/rock619/monkey/parser"
+)
+
+var (
+	runs  = flag.Int("n", 3, "run each program `n` times and report the fastest")
+	other = flag.String("other", "", "path of another Monkey `binary` to compare with")
+	stdin = flag.Bool("stdin", false, "pass programs to the other binary on its standard input")
+)
+
+func main() {
+	flag.Parse()
+	paths := flag.Args()
+	if len(paths) == 0 {
+		paths = []string{"tools/crossbench/corpus"}
+	}
+	files, err := corpus(paths)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprint(tw, "program\tmonkey")
+	if *other != "" {
+		fmt.Fprint(tw, "\tother\tratio\toutput")
+	}
+	fmt.Fprintln(tw)
+
+	status := 0
+	for _, file := range files {
+		src, err := os.ReadFile(file)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+
+		ours, ourTime, err := best(func() (string, error) { return runHere(file, string(src)) })
+		if err != nil {
+			fmt.Fprintf(tw, "%s\tfailed: %s\n", file, err)
+			status = 1
+			continue
+		}
+		fmt.Fprintf(tw, "%s\t%s", file, ourTime.Round(time.Microsecond))
+
+		if *other != "" {
+			theirs, theirTime, err := best(func() (string, error) { return runOther(file, src) })
+			switch {
+			case err != nil:
+				fmt.Fprintf(tw, "\tfailed: %s", err)
+				status = 1
+			case !sameOutput(ours, theirs):
+				fmt.Fprintf(tw, "\t%s\t%.2fx\tdiffers", theirTime.Round(time.Microsecond), ratio(theirTime, ourTime))
+				status = 1
+			default:
+				fmt.Fprintf(tw, "\t%s\t%.2fx\tsame", theirTime.Round(time.Microsecond), ratio(theirTime, ourTime))
+			}
+		}
+		fmt.Fprintln(tw)
+	}
+	tw.Flush()
+	os.Exit(status)
+}
+
+// corpus returns the .mky files among paths and in the directories among
+// them, sorted.
+func corpus(paths []string) ([]string, error) {
+	var files []string
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+		matches, err := filepath.Glob(filepath.Join(path, "*.mky"))
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, matches...)
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// best runs fn *runs times and returns its output and its fastest time.
+func best(fn func() (string, error)) (string, time.Duration, error) {
+	var out string
+	var fastest time.Duration
+	for i := 0; i < max(*runs, 1); i++ {
+		start := time.Now()
+		o, err := fn()
+		elapsed := time.Since(start)
+		if err != nil {
+			return "", 0, err
+		}
+		if i == 0 || elapsed < fastest {
+			fastest = elapsed
+		}
+		out = o
+	}
+	return out, fastest, nil
+}
+
+// runHere evaluates src with this interpreter and returns what it printed.
+func runHere(file, src string) (string, error) {
+	p := parser.New(lexer.NewFile(file, src))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return "", parser.ErrorList(p.ErrorList())
+	}
+
+	var out bytes.Buffer
+	eval := evaluator.New(evaluator.Options{IO: evaluator.IOStreams{Out: &out}})
+	if err, ok := eval.Eval(context.Background(), program, eval.NewEnvironment()).(*object.Error); ok {
+		return "", fmt.Errorf("%s", err.Inspect())
+	}
+	return out.String(), nil
+}
+
+func runOther(file string, src []byte) (string, error) {
+	cmd := exec.Command(*other, file)
+	if *stdin {
+		cmd = exec.Command(*other)
+		cmd.Stdin = bytes.NewReader(src)
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("%s: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return string(out), nil
+}
+
+func sameOutput(a, b string) bool {
+	return strings.TrimRight(a, " \t\r\n") == strings.TrimRight(b, " \t\r\n")
+}
+
+func ratio(a, b time.Duration) float64 {
+	if b == 0 {
+		return 0
+	}
+	return float64(a) / float64(b)
+}
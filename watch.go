@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// pollInterval is how often watch looks for changed files.
+const pollInterval = 300 * time.Millisecond
+
+const clearScreen = "\x1b[H\x1b[2J"
+
+// watch calls run, then again each time one of files changes, until ctx is
+// done. Each run starts on a cleared screen. Changes are found by polling
+// the modification times and sizes of the files.
+func watch(ctx context.Context, files []string, run func() int) int {
+	for {
+		fmt.Print(clearScreen)
+		status := run()
+		fmt.Fprintf(os.Stderr, "\n[exit status %d, watching %s for changes]\n",
+			status, strings.Join(files, ", "))
+
+		if !waitForChange(ctx, files) {
+			return 0
+		}
+	}
+}
+
+type fileState struct {
+	modTime time.Time
+	size    int64
+	exists  bool
+}
+
+func statFiles(files []string) []fileState {
+	states := make([]fileState, len(files))
+	for i, file := range files {
+		if info, err := os.Stat(file); err == nil {
+			states[i] = fileState{modTime: info.ModTime(), size: info.Size(), exists: true}
+		}
+	}
+	return states
+}
+
+// waitForChange blocks until one of files is modified, created or removed,
+// and reports whether that happened before ctx was done.
+func waitForChange(ctx context.Context, files []string) bool {
+	before := statFiles(files)
+
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return false
+		case <-ticker.C:
+			after := statFiles(files)
+			for i := range before {
+				if after[i] != before[i] {
+					return true
+				}
+			}
+		}
+	}
+}